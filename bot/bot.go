@@ -0,0 +1,342 @@
+// Package bot plays a culture without a person at the controls. A Bot only
+// ever looks at the game through the GameStatus its culture is allowed to
+// see, and only ever acts on it by giving orders, so the same bot can play
+// inside of a game.GameLoop with Run, or over a websocket like any other
+// player with Play.
+package bot
+
+import (
+	"fmt"
+
+	"github.com/joeatwork/world-of-strategery/game"
+)
+
+// Config describes how a Bot plays.
+type Config struct {
+	HouseType   string // the type of house to plan
+	HouseWidth  int    // the size of HouseType, which isn't in the status
+	HouseHeight int
+	Plans       int    // how many unfinished houses to keep at once
+	Spacing     int    // tiles of space to leave around planned houses
+	WorkerType  string // only characters of this type work, or all of them if empty
+	Raiders     int    // workers that mine enemy houses even when there are deposits
+}
+
+// DefaultConfig plans the "house" type from catalogs/default.json.
+var DefaultConfig = Config{
+	HouseType:   "house",
+	HouseWidth:  2,
+	HouseHeight: 2,
+	Plans:       1,
+	Spacing:     1,
+	WorkerType:  "worker",
+}
+
+// Check returns an error if config names types that g doesn't have, or gets
+// the size of its house type wrong. Like everything else that touches a game,
+// it must be called between Ticks.
+func (config Config) Check(g *game.Game) error {
+	houseType, ok := game.HouseTypeNamed(g, config.HouseType)
+	if !ok {
+		return fmt.Errorf("game has no house type %q", config.HouseType)
+	}
+	if houseType.Width != config.HouseWidth || houseType.Height != config.HouseHeight {
+		return fmt.Errorf("house type %q is %dx%d, not %dx%d", config.HouseType,
+			houseType.Width, houseType.Height, config.HouseWidth, config.HouseHeight)
+	}
+	if config.WorkerType != "" {
+		if _, ok := game.CharacterTypeNamed(g, config.WorkerType); !ok {
+			return fmt.Errorf("game has no character type %q", config.WorkerType)
+		}
+	}
+	return nil
+}
+
+// planInterval is the fewest ticks a bot waits between plans. Orders take
+// a while to show up in the status when a bot plays over a websocket, and
+// waiting keeps it from planning the same house twice.
+const planInterval = 10
+
+// Bot decides on orders for the culture named Culture.
+type Bot struct {
+	Culture  string
+	config   Config
+	planned  bool
+	lastPlan int // the tick of the most recent plan
+}
+
+// New creates a bot for the named culture.
+func New(culture string, config Config) *Bot {
+	return &Bot{Culture: culture, config: config}
+}
+
+// view is everything a bot can see at one tick, arranged for making
+// decisions.
+type view struct {
+	status  game.GameStatus
+	own     game.CultureStatus
+	blocked [][]bool // [x][y], true where houses can't go
+}
+
+func newView(status game.GameStatus, own game.CultureStatus) *view {
+	v := &view{status: status, own: own}
+	v.blocked = make([][]bool, status.Width)
+	for x := range v.blocked {
+		v.blocked[x] = make([]bool, status.Height)
+	}
+
+	for y, row := range status.Tiles {
+		for x := 0; x < len(row) && x < status.Width; x++ {
+			kind, ok := game.TileKindForSymbol(row[x])
+			if !ok || !kind.Passable() {
+				v.blocked[x][y] = true
+			}
+		}
+	}
+
+	block := func(loc game.Location, width, height int) {
+		for x := loc.X; x < loc.X+width && x < status.Width; x++ {
+			for y := loc.Y; y < loc.Y+height && y < status.Height; y++ {
+				if x >= 0 && y >= 0 {
+					v.blocked[x][y] = true
+				}
+			}
+		}
+	}
+	blockHouses := func(houses []game.HouseStatus) {
+		for _, house := range houses {
+			block(house.Location, house.Width, house.Height)
+		}
+	}
+	for _, culture := range status.Cultures {
+		for _, who := range culture.Characters {
+			block(who.Location, who.Width, who.Height)
+		}
+		blockHouses(culture.PlannedHouses)
+		blockHouses(culture.BuiltHouses)
+	}
+	blockHouses(status.Deposits)
+
+	return v
+}
+
+// isClear reports whether a width by height footprint at x, y, along with
+// spacing tiles around it, is in bounds and free.
+func (v *view) isClear(x, y, width, height, spacing int) bool {
+	if x < 0 || y < 0 || x+width > v.status.Width || y+height > v.status.Height {
+		return false
+	}
+	for cx := x - spacing; cx < x+width+spacing; cx++ {
+		for cy := y - spacing; cy < y+height+spacing; cy++ {
+			if cx < 0 || cy < 0 || cx >= v.status.Width || cy >= v.status.Height {
+				continue
+			}
+			if v.blocked[cx][cy] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func distSquared(a, b game.Location) int {
+	dx := a.X - b.X
+	dy := a.Y - b.Y
+	return dx*dx + dy*dy
+}
+
+// nearest returns the house closest to loc, or nil if there are none. Ties go
+// to the house that comes first.
+func nearest(loc game.Location, houses []game.HouseStatus) *game.HouseStatus {
+	var ret *game.HouseStatus
+	closest := 0
+	for i := range houses {
+		dist := distSquared(loc, houses[i].Location)
+		if ret == nil || dist < closest {
+			ret = &houses[i]
+			closest = dist
+		}
+	}
+	return ret
+}
+
+// unfinished returns the bot's houses that still need resources.
+func (v *view) unfinished() []game.HouseStatus {
+	var ret []game.HouseStatus
+	for _, houses := range [][]game.HouseStatus{v.own.PlannedHouses, v.own.BuiltHouses} {
+		for _, house := range houses {
+			if house.ResourcesLeft < house.MaxResources {
+				ret = append(ret, house)
+			}
+		}
+	}
+	return ret
+}
+
+// depots returns the bot's finished depots.
+func (v *view) depots() []game.HouseStatus {
+	var ret []game.HouseStatus
+	for _, house := range v.own.BuiltHouses {
+		if house.Depot {
+			ret = append(ret, house)
+		}
+	}
+	return ret
+}
+
+// deposits returns the deposits that still have resources in them.
+func (v *view) deposits() []game.HouseStatus {
+	var ret []game.HouseStatus
+	for _, deposit := range v.status.Deposits {
+		if deposit.ResourcesLeft > 0 {
+			ret = append(ret, deposit)
+		}
+	}
+	return ret
+}
+
+// enemyHouses returns the built houses of every other culture that the bot
+// can see or remembers, which are the houses it can raid.
+func (v *view) enemyHouses() []game.HouseStatus {
+	var ret []game.HouseStatus
+	for _, culture := range v.status.Cultures {
+		if culture.Name == v.own.Name {
+			continue
+		}
+		for _, house := range culture.BuiltHouses {
+			if house.ResourcesLeft > 0 {
+				ret = append(ret, house)
+			}
+		}
+	}
+	return ret
+}
+
+// anchor is where the bot builds outward from: one of its depots, or any of
+// its houses if it has no depot, or its first character if it has no houses.
+func (v *view) anchor() (game.Location, bool) {
+	if depots := v.depots(); len(depots) > 0 {
+		return depots[0].Location, true
+	}
+	if len(v.own.BuiltHouses) > 0 {
+		return v.own.BuiltHouses[0].Location, true
+	}
+	if len(v.own.Characters) > 0 {
+		return v.own.Characters[0].Location, true
+	}
+	return game.Location{}, false
+}
+
+// planSite finds a clear place for a new house, searching in rings around the
+// bot's anchor.
+func (b *Bot) planSite(v *view) (game.Location, bool) {
+	center, ok := v.anchor()
+	if !ok {
+		return game.Location{}, false
+	}
+
+	width, height := b.config.HouseWidth, b.config.HouseHeight
+	limit := v.status.Width + v.status.Height
+	for r := 1; r < limit; r++ {
+		for dy := -r; dy <= r; dy++ {
+			for dx := -r; dx <= r; dx++ {
+				if dx != -r && dx != r && dy != -r && dy != r {
+					continue // inside of the ring, already searched
+				}
+				x, y := center.X+dx, center.Y+dy
+				if v.isClear(x, y, width, height, b.config.Spacing) {
+					return game.Location{X: x, Y: y}, true
+				}
+			}
+		}
+	}
+	return game.Location{}, false
+}
+
+// isWorker reports whether who is a character the bot sends to work.
+func (b *Bot) isWorker(who game.CharacterStatus) bool {
+	return b.config.WorkerType == "" || who.Type == b.config.WorkerType
+}
+
+// job picks a house for an idle worker to target. Workers carrying resources
+// build with them, or drop them off if there's nothing to build. Empty handed
+// workers fetch from the bank to build if they can, and otherwise mine
+// deposits, or raid enemy houses if there are no deposits or they're raiders.
+func (b *Bot) job(v *view, who game.CharacterStatus, raider bool) *game.HouseStatus {
+	unfinished := v.unfinished()
+	depots := v.depots()
+
+	if who.Carrying > 0 {
+		if house := nearest(who.Location, unfinished); house != nil {
+			return house
+		}
+		if depot := nearest(who.Location, depots); depot != nil {
+			return depot
+		}
+	} else if len(depots) > 0 && v.own.Resources > 0 {
+		// Targeting the house sends the worker to the depot for
+		// resources first.
+		if house := nearest(who.Location, unfinished); house != nil {
+			return house
+		}
+	}
+
+	if !raider {
+		if deposit := nearest(who.Location, v.deposits()); deposit != nil {
+			return deposit
+		}
+	}
+	return nearest(who.Location, v.enemyHouses())
+}
+
+// Orders decides what the bot's culture should do next, given a status read
+// for that culture. It returns no orders if the status doesn't include the
+// bot's culture.
+func (b *Bot) Orders(status game.GameStatus) []game.Order {
+	var own *game.CultureStatus
+	for i := range status.Cultures {
+		if status.Cultures[i].Name == b.Culture {
+			own = &status.Cultures[i]
+		}
+	}
+	if own == nil {
+		return nil
+	}
+
+	v := newView(status, *own)
+	var orders []game.Order
+
+	waited := !b.planned || status.Tick-b.lastPlan >= planInterval
+	if b.config.HouseType != "" && waited && len(v.unfinished()) < b.config.Plans {
+		if site, ok := b.planSite(v); ok {
+			b.planned = true
+			b.lastPlan = status.Tick
+			orders = append(orders, &game.PlanOrder{
+				Culture:   b.Culture,
+				HouseType: b.config.HouseType,
+				X:         site.X,
+				Y:         site.Y,
+			})
+		}
+	}
+
+	workers := 0
+	for _, who := range own.Characters {
+		if !b.isWorker(who) {
+			continue
+		}
+		raider := workers < b.config.Raiders
+		workers++
+		if who.Target != "" || who.Marching {
+			continue
+		}
+		if house := b.job(v, who, raider); house != nil {
+			orders = append(orders, &game.TargetOrder{
+				Character: who.Name,
+				Target:    house.Name,
+			})
+		}
+	}
+
+	return orders
+}
@@ -0,0 +1,216 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joeatwork/world-of-strategery/game"
+)
+
+// testMap has a start for the bot in the top left, with a depot and a
+// deposit nearby, and an enemy start in the bottom right.
+const testMap = `{
+	"tiles": [
+		"------------------------",
+		"------------------------",
+		"------------------------",
+		"------------------------",
+		"------------------------",
+		"------------------------",
+		"------------------------",
+		"------------------------",
+		"------------------------",
+		"------------------------",
+		"------------------------",
+		"------------------------"
+	],
+	"deposits": [{"type": "house", "x": 8, "y": 2, "resources": 300}],
+	"starts": [
+		{"characters": [
+			{"type": "worker", "x": 1, "y": 1},
+			{"type": "worker", "x": 3, "y": 1},
+			{"type": "scout", "x": 5, "y": 1}
+		],
+		 "houses": [{"type": "depot", "x": 1, "y": 8}]},
+		{"characters": [{"type": "worker", "x": 20, "y": 10}],
+		 "houses": [{"type": "tower", "x": 22, "y": 10}]}
+	]
+}`
+
+func newTestGame(t *testing.T, m string) *game.Game {
+	catalog, err := game.ReadCatalogFile("../catalogs/default.json")
+	if err != nil {
+		t.Fatalf("Can't read catalog: %v", err)
+	}
+	parsed, err := game.ReadMap(strings.NewReader(m))
+	if err != nil {
+		t.Fatalf("Can't read map: %v", err)
+	}
+	g, err := game.NewGameFromMap(parsed, catalog)
+	if err != nil {
+		t.Fatalf("Can't create game: %v", err)
+	}
+	return g
+}
+
+// play runs the bot for ticks ticks, failing if it gives any orders the game
+// won't accept.
+func play(t *testing.T, g *game.Game, b *Bot, ticks int) {
+	culture := g.Cultures[0]
+	for i := 0; i < ticks; i++ {
+		var owned []game.Order
+		for _, order := range b.Orders(game.ReadStatusFor(g, culture)) {
+			owned = append(owned, &game.PlayerOrder{Culture: b.Culture, Order: order})
+		}
+		for _, result := range game.ApplyOrders(g, owned) {
+			if result.Err != nil {
+				t.Fatalf("Bot gave a bad order %v: %v", result.Order, result.Err)
+			}
+		}
+		game.Tick(g, game.TickDuration)
+	}
+}
+
+func finishedHouses(g *game.Game, culture *game.Culture, houseType string) int {
+	count := 0
+	for _, house := range game.ReadStatusFor(g, culture).Cultures[0].BuiltHouses {
+		if house.Type == houseType && house.ResourcesLeft >= house.MaxResources {
+			count++
+		}
+	}
+	return count
+}
+
+func TestBotExpands(t *testing.T) {
+	g := newTestGame(t, testMap)
+	culture := g.Cultures[0]
+	b := New(culture.Name, DefaultConfig)
+
+	play(t, g, b, 800)
+
+	if finishedHouses(g, culture, "house") == 0 {
+		t.Errorf("Expected the bot to finish a house, has %v", game.ReadStatus(g).Cultures[0])
+	}
+	for _, who := range culture.Characters {
+		if who.Type.MaxCarry == 0 && who.Target != nil {
+			t.Errorf("Expected the bot to leave its scout alone")
+		}
+	}
+}
+
+// raidMap has an enemy tower close enough for the bot to see, and a deposit.
+const raidMap = `{
+	"tiles": ["----------------", "----------------", "----------------",
+		"----------------", "----------------", "----------------"],
+	"deposits": [{"type": "house", "x": 1, "y": 4}],
+	"starts": [
+		{"characters": [
+			{"type": "worker", "x": 1, "y": 1},
+			{"type": "worker", "x": 2, "y": 1}
+		]},
+		{"characters": [{"type": "worker", "x": 14, "y": 4}],
+		 "houses": [{"type": "tower", "x": 8, "y": 2}]}
+	]
+}`
+
+func TestBotRaids(t *testing.T) {
+	for _, raiders := range []int{0, 1} {
+		g := newTestGame(t, raidMap)
+		culture := g.Cultures[0]
+		config := DefaultConfig
+		config.HouseType = ""
+		config.Raiders = raiders
+		b := New(culture.Name, config)
+
+		play(t, g, b, 1)
+
+		status := game.ReadStatus(g)
+		tower := status.Cultures[1].BuiltHouses[0].Name
+		deposit := status.Deposits[0].Name
+		first := status.Cultures[0].Characters[0].Target
+		second := status.Cultures[0].Characters[1].Target
+		if raiders == 0 && (first != deposit || second != deposit) {
+			t.Errorf("Expected workers to mine the deposit rather than raid")
+		}
+		if raiders == 1 && (first != tower || second != deposit) {
+			t.Errorf("Expected one raider, got targets %q and %q", first, second)
+		}
+	}
+
+	// Once the deposit is gone, everyone raids.
+	g := newTestGame(t, raidMap)
+	game.Deposits(g)[0].ResourcesLeft = 0
+	b := New(g.Cultures[0].Name, DefaultConfig)
+	orders := b.Orders(game.ReadStatusFor(g, g.Cultures[0]))
+	tower := game.ReadStatus(g).Cultures[1].BuiltHouses[0].Name
+	for _, order := range orders {
+		if target, ok := order.(*game.TargetOrder); ok && target.Target != tower {
+			t.Errorf("Expected workers to raid with no deposits left, got %v", target)
+		}
+	}
+}
+
+func TestBotIgnoresOtherCultures(t *testing.T) {
+	g := newTestGame(t, testMap)
+	b := New("nobody", DefaultConfig)
+	if orders := b.Orders(game.ReadStatus(g)); orders != nil {
+		t.Errorf("Expected no orders for a culture that isn't playing, got %v", orders)
+	}
+}
+
+func TestConfigCheck(t *testing.T) {
+	g := newTestGame(t, testMap)
+	if err := DefaultConfig.Check(g); err != nil {
+		t.Errorf("Expected the default config to fit the default catalog: %v", err)
+	}
+
+	unknownHouse := DefaultConfig
+	unknownHouse.HouseType = "castle"
+	wrongSize := DefaultConfig
+	wrongSize.HouseWidth = 3
+	unknownWorker := DefaultConfig
+	unknownWorker.WorkerType = "ghost"
+	for _, config := range []Config{unknownHouse, wrongSize, unknownWorker} {
+		if err := config.Check(g); err == nil {
+			t.Errorf("Expected an error checking %v", config)
+		}
+	}
+
+	if err := DefaultConfig.Check(game.NewGame(8, 8)); err == nil {
+		t.Errorf("Expected an error checking a game without a catalog")
+	}
+}
+
+func TestRun(t *testing.T) {
+	g := newTestGame(t, testMap)
+	culture := g.Cultures[0].Name
+	loop := game.RunGameLoop(g, game.LoopConfig{TicksPerSecond: 200, MaxCatchUpTicks: 5})
+
+	finished := make(chan struct{})
+	go func() {
+		Run(loop, New(culture, DefaultConfig), time.Millisecond)
+		close(finished)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		status, _ := loop.ReadLatestStatusFor(culture)
+		if len(status.Cultures[0].PlannedHouses)+len(status.Cultures[0].BuiltHouses) > 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	loop.Stop()
+
+	select {
+	case <-finished:
+	case <-time.After(time.Second):
+		t.Fatalf("Expected Run to return once the loop stopped")
+	}
+
+	status := loop.ReadLatestStatus()
+	if len(status.Cultures[0].PlannedHouses)+len(status.Cultures[0].BuiltHouses) < 2 {
+		t.Errorf("Expected the bot to plan a house")
+	}
+}
@@ -0,0 +1,107 @@
+package bot
+
+import (
+	"fmt"
+	"time"
+
+	"golang.org/x/net/websocket"
+
+	"github.com/joeatwork/world-of-strategery/game"
+	"github.com/joeatwork/world-of-strategery/protocol"
+)
+
+// Run plays b's culture in loop until the loop stops. It checks for a new
+// status every poll, and gives orders once for every tick it sees. Orders are
+// given as the culture's player, so they're held to the same rules as orders
+// from a person.
+func Run(loop *game.GameLoop, b *Bot, poll time.Duration) {
+	last := -1
+	for !loop.IsStopped() {
+		status, ok := loop.ReadLatestStatusFor(b.Culture)
+		if ok && status.Tick != last {
+			last = status.Tick
+			orders := b.Orders(status)
+			if len(orders) > 0 {
+				owned := make([]game.Order, len(orders))
+				for i, order := range orders {
+					owned[i] = &game.PlayerOrder{Culture: b.Culture, Order: order}
+				}
+				loop.WriteOrders(owned)
+			}
+		}
+		time.Sleep(poll)
+	}
+}
+
+// Play joins the game on the other end of ws as a player, and plays the
+// culture it's given with a Bot until the game is over, when it returns nil,
+// or until the connection closes. Any other returned error explains why play
+// stopped. The bot asks to be sent status deltas, and acknowledges every
+// status it rebuilds from them.
+func Play(ws *websocket.Conn, config Config) error {
+	send := func(v interface{}) error {
+		encoded, err := protocol.Encode(v)
+		if err != nil {
+			return err
+		}
+		return websocket.Message.Send(ws, encoded)
+	}
+	if err := send(protocol.Join{Role: protocol.RolePlayer, Deltas: true}); err != nil {
+		return err
+	}
+
+	var b *Bot
+	var tracker protocol.StatusTracker
+	resyncing := false
+	last := -1
+	for {
+		var data []byte
+		if err := websocket.Message.Receive(ws, &data); err != nil {
+			return err
+		}
+		msg, err := protocol.DecodeFromServer(data)
+		if err != nil {
+			return err
+		}
+
+		switch m := msg.(type) {
+		case protocol.Joined:
+			b = New(m.Culture, config)
+		case *protocol.Error:
+			if b == nil {
+				return fmt.Errorf("can't join game: %v", m)
+			}
+		case game.GameResult:
+			return nil
+		case protocol.StatusDelta:
+			if resyncing && !m.Keyframe {
+				continue
+			}
+			status, err := tracker.Apply(m)
+			if err != nil {
+				resyncing = true
+				if err := send(protocol.Resync{}); err != nil {
+					return err
+				}
+				continue
+			}
+			resyncing = false
+			if err := send(protocol.Ack{Seq: m.Seq}); err != nil {
+				return err
+			}
+			if b == nil || status.Tick == last {
+				continue
+			}
+			last = status.Tick
+			for _, order := range b.Orders(status) {
+				encoded, err := protocol.EncodeOrder(order)
+				if err != nil {
+					return err
+				}
+				if err := websocket.Message.Send(ws, encoded); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package game
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// Catalog is a set of named character and house types, usually read from a
+// file, so that game balance can be tuned without rebuilding anything.
+// Catalog files are JSON, like
+//
+//	{
+//	  "characterTypes": {
+//	    "worker": {"movePerTick": 1, "workPerTick": 4, "maxCarry": 10,
+//	               "width": 1, "height": 1}
+//	  },
+//	  "houseTypes": {
+//	    "house": {"maxResources": 100, "width": 2, "height": 2}
+//	  }
+//	}
+type Catalog struct {
+	CharacterTypes map[string]*CharacterType `json:"characterTypes"`
+	HouseTypes     map[string]*HouseType     `json:"houseTypes"`
+}
+
+func validateCharacterType(name string, t *CharacterType) error {
+	switch {
+	case t == nil:
+		return fmt.Errorf("character type %q is missing", name)
+	case t.Width <= 0 || t.Height <= 0:
+		return fmt.Errorf("character type %q has size %dx%d, sizes must be positive",
+			name, t.Width, t.Height)
+	case t.MovePerTick < 0 || t.WorkPerTick < 0 || t.MaxCarry < 0:
+		return fmt.Errorf("character type %q has a negative rate", name)
+	case t.Sight < 0:
+		return fmt.Errorf("character type %q has negative sight", name)
+	case t.Health < 0 || t.AttackDamage < 0 || t.AttackRange < 0 || t.AttackCooldown < 0:
+		return fmt.Errorf("character type %q has negative combat stats", name)
+	}
+	return nil
+}
+
+func validateHouseType(name string, t *HouseType) error {
+	switch {
+	case t == nil:
+		return fmt.Errorf("house type %q is missing", name)
+	case t.Width <= 0 || t.Height <= 0:
+		return fmt.Errorf("house type %q has size %dx%d, sizes must be positive",
+			name, t.Width, t.Height)
+	case t.MaxResources < 1:
+		return fmt.Errorf("house type %q has %v resources, it needs at least 1",
+			name, t.MaxResources)
+	case t.Sight < 0:
+		return fmt.Errorf("house type %q has negative sight", name)
+	case t.TrainCost < 0 || t.TrainTime < 0:
+		return fmt.Errorf("house type %q has negative training cost or time", name)
+	}
+	return nil
+}
+
+// ReadCatalog reads and validates a catalog.
+func ReadCatalog(r io.Reader) (*Catalog, error) {
+	var catalog Catalog
+	if err := json.NewDecoder(r).Decode(&catalog); err != nil {
+		return nil, fmt.Errorf("can't read catalog: %v", err)
+	}
+	for name, t := range catalog.CharacterTypes {
+		if err := validateCharacterType(name, t); err != nil {
+			return nil, err
+		}
+	}
+	for name, t := range catalog.HouseTypes {
+		if err := validateHouseType(name, t); err != nil {
+			return nil, err
+		}
+	}
+	return &catalog, nil
+}
+
+// ReadCatalogFile reads and validates the catalog in the named file.
+func ReadCatalogFile(path string) (*Catalog, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadCatalog(f)
+}
+
+// AddCatalog adds every type in catalog to game. It fails, without changing
+// the game, if any of the names are already in use or any of the types are
+// invalid.
+func AddCatalog(game *Game, catalog *Catalog) error {
+	for name, t := range catalog.CharacterTypes {
+		if _, exists := game.characterTypes[name]; exists {
+			return fmt.Errorf("character type %q already exists", name)
+		}
+		if err := validateCharacterType(name, t); err != nil {
+			return err
+		}
+	}
+	for name, t := range catalog.HouseTypes {
+		if _, exists := game.houseTypes[name]; exists {
+			return fmt.Errorf("house type %q already exists", name)
+		}
+		if err := validateHouseType(name, t); err != nil {
+			return err
+		}
+		_, inCatalog := catalog.CharacterTypes[t.Trains]
+		_, inGame := game.characterTypes[t.Trains]
+		if t.Trains != "" && !inCatalog && !inGame {
+			return fmt.Errorf("house type %q trains unknown character type %q",
+				name, t.Trains)
+		}
+	}
+
+	for _, name := range sortedKeys(catalog.CharacterTypes) {
+		if err := AddCharacterType(game, name, catalog.CharacterTypes[name]); err != nil {
+			return err
+		}
+	}
+	for _, name := range sortedKeys(catalog.HouseTypes) {
+		if err := AddHouseType(game, name, catalog.HouseTypes[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sortedKeys returns the names in a map of CharacterTypes or HouseTypes in
+// order.
+func sortedKeys(types interface{}) []string {
+	var ret []string
+	switch types := types.(type) {
+	case map[string]*CharacterType:
+		for name := range types {
+			ret = append(ret, name)
+		}
+	case map[string]*HouseType:
+		for name := range types {
+			ret = append(ret, name)
+		}
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// CharacterTypeNamed finds a CharacterType added to game under the given
+// name.
+func CharacterTypeNamed(game *Game, name string) (*CharacterType, bool) {
+	t, ok := game.characterTypes[name]
+	return t, ok
+}
+
+// HouseTypeNamed finds a HouseType added to game under the given name.
+func HouseTypeNamed(game *Game, name string) (*HouseType, bool) {
+	t, ok := game.houseTypes[name]
+	return t, ok
+}
@@ -0,0 +1,116 @@
+package game
+
+import (
+	"strings"
+	"testing"
+)
+
+const testCatalog = `{
+	"characterTypes": {
+		"worker": {"movePerTick": 1, "workPerTick": 4, "maxCarry": 10,
+			"width": 2, "height": 2},
+		"scout": {"movePerTick": 2, "width": 1, "height": 1, "sight": 12}
+	},
+	"houseTypes": {
+		"house": {"maxResources": 100, "width": 1, "height": 1}
+	}
+}`
+
+func TestReadCatalog(t *testing.T) {
+	catalog, err := ReadCatalog(strings.NewReader(testCatalog))
+	if err != nil {
+		t.Fatalf("Unexpected error reading catalog: %v", err)
+	}
+
+	if *catalog.CharacterTypes["worker"] != *workerType {
+		t.Errorf("Expected worker %v, got %v", workerType, catalog.CharacterTypes["worker"])
+	}
+	if catalog.CharacterTypes["scout"].Sight != 12 {
+		t.Errorf("Expected scout sight 12, got %d", catalog.CharacterTypes["scout"].Sight)
+	}
+	if *catalog.HouseTypes["house"] != *houseType {
+		t.Errorf("Expected house %v, got %v", houseType, catalog.HouseTypes["house"])
+	}
+}
+
+func TestReadCatalogInvalid(t *testing.T) {
+	invalid := []string{
+		`{"characterTypes": {"flat": {"width": 0, "height": 1}}}`,
+		`{"characterTypes": {"lazy": {"width": 1, "height": 1, "workPerTick": -1}}}`,
+		`{"houseTypes": {"hole": {"maxResources": -5, "width": 1, "height": 1}}}`,
+		`{"houseTypes": {"empty": {"width": 1, "height": 1}}}`,
+		`{"houseTypes": {"sliver": {"maxResources": 0.5, "width": 1, "height": 1}}}`,
+		`{"houseTypes": {"tiny": {"maxResources": 1, "width": 1, "height": -1}}}`,
+		`{"houseTypes": {"slow": {"maxResources": 1, "width": 1, "height": 1, "trainTime": -1}}}`,
+		`{"houseTypes": {"nothing": null}}`,
+		`{"houseTypes": [`,
+	}
+	for _, doc := range invalid {
+		if _, err := ReadCatalog(strings.NewReader(doc)); err == nil {
+			t.Errorf("Expected an error reading catalog %s", doc)
+		}
+	}
+}
+
+func TestAddCatalog(t *testing.T) {
+	game := NewGame(16, 16)
+	catalog, _ := ReadCatalog(strings.NewReader(testCatalog))
+	if err := AddCatalog(game, catalog); err != nil {
+		t.Fatalf("Unexpected error adding catalog: %v", err)
+	}
+
+	if worker, ok := CharacterTypeNamed(game, "worker"); !ok || worker != catalog.CharacterTypes["worker"] {
+		t.Errorf("Expected worker type in game, got %v", worker)
+	}
+	culture := AddCulture(game)
+	order := &PlanOrder{Culture: culture.Name, HouseType: "house", X: 4, Y: 4}
+	if err := order.Apply(game); err != nil {
+		t.Errorf("Unexpected error planning a catalog house: %v", err)
+	}
+
+	again := NewGame(16, 16)
+	AddCharacterType(again, "scout", workerType)
+	if err := AddCatalog(again, catalog); err == nil {
+		t.Errorf("Expected an error adding a catalog with a duplicate name")
+	}
+	if _, ok := HouseTypeNamed(again, "house"); ok {
+		t.Errorf("Expected failed catalog not to change the game")
+	}
+
+	ghosts := &Catalog{HouseTypes: map[string]*HouseType{
+		"graveyard": {MaxResources: 10, Width: 1, Height: 1, Trains: "ghost"},
+	}}
+	if err := AddCatalog(NewGame(16, 16), ghosts); err == nil {
+		t.Errorf("Expected an error adding a house that trains an unknown type")
+	}
+}
+
+func TestSaveKeepsCatalog(t *testing.T) {
+	game := NewGame(16, 16)
+	catalog, _ := ReadCatalog(strings.NewReader(testCatalog))
+	AddCatalog(game, catalog)
+	worker, _ := CharacterTypeNamed(game, "worker")
+	AddCharacter(game.terrain, AddCulture(game), worker, loc0x0)
+
+	loaded := saveAndLoad(t, game)
+	loadedWorker, ok := CharacterTypeNamed(loaded, "worker")
+	if !ok {
+		t.Fatalf("Expected worker type to be loaded")
+	}
+	if loaded.Cultures[0].Characters[0].Type != loadedWorker {
+		t.Errorf("Expected loaded character to use the catalog worker type")
+	}
+	if _, ok := CharacterTypeNamed(loaded, "scout"); !ok {
+		t.Errorf("Expected unused scout type to be loaded")
+	}
+}
+
+func TestDefaultCatalogIsValid(t *testing.T) {
+	catalog, err := ReadCatalogFile("../catalogs/default.json")
+	if err != nil {
+		t.Fatalf("Default catalog is broken: %v", err)
+	}
+	if len(catalog.CharacterTypes) == 0 || len(catalog.HouseTypes) == 0 {
+		t.Errorf("Default catalog is missing types")
+	}
+}
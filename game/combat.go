@@ -0,0 +1,169 @@
+package game
+
+// defaultHealth is the health of characters whose type doesn't specify any.
+const defaultHealth = 10
+
+func healthOf(characterType *CharacterType) float64 {
+	if characterType.Health <= 0 {
+		return defaultHealth
+	}
+	return characterType.Health
+}
+
+// AttackOrder instructs the named character to attack the named character of
+// another culture. Attackers chase their victims until they're in range, and
+// keep attacking until their victim is dead.
+type AttackOrder struct {
+	Character string `json:"character"`
+	Target    string `json:"target"`
+}
+
+// Apply points the attacker at its victim.
+func (o *AttackOrder) Apply(game *Game) error {
+	who, err := findCharacter(game, o.Character)
+	if err != nil {
+		return err
+	}
+	if who.Type.AttackDamage <= 0 {
+		return &OrderError{RejectCantAttack, o.Character}
+	}
+
+	switch victim := lookup(game, o.Target).(type) {
+	case *Character:
+		if victim.Culture == who.Culture {
+			return &OrderError{RejectOwnCulture, o.Target}
+		}
+		who.Target = victim
+		who.work = nil
+	case nil:
+		return &OrderError{RejectUnknownTarget, o.Target}
+	default:
+		return &OrderError{RejectNotCharacter, o.Target}
+	}
+
+	return nil
+}
+
+// Issuer is the culture that owns the attacker.
+func (o *AttackOrder) Issuer(game *Game) (*Culture, error) {
+	who, err := findCharacter(game, o.Character)
+	if err != nil {
+		return nil, err
+	}
+	return who.Culture, nil
+}
+
+// gap is the number of tiles between the footprints of a and b, counting
+// diagonally, so characters that are touching have a gap of zero.
+func gap(a, b *Character) int {
+	return footprintGap(a.Location.X, a.Location.Y, a.Type.Width, a.Type.Height,
+		b.Location.X, b.Location.Y, b.Type.Width, b.Type.Height)
+}
+
+// footprintGap is the gap between any two footprints, given as the top left
+// corner and size of each.
+func footprintGap(ax, ay, aWidth, aHeight, bx, by, bWidth, bHeight int) int {
+	span := func(aMin, aSize, bMin, bSize int) int {
+		if aMin+aSize <= bMin {
+			return bMin - (aMin + aSize)
+		}
+		if bMin+bSize <= aMin {
+			return aMin - (bMin + bSize)
+		}
+		return 0
+	}
+	dx := span(ax, aWidth, bx, bWidth)
+	dy := span(ay, aHeight, by, bHeight)
+	if dx > dy {
+		return dx
+	}
+	return dy
+}
+
+// inRange reports whether who is close enough to hit victim.
+func inRange(who, victim *Character) bool {
+	return gap(who, victim) <= who.Type.AttackRange
+}
+
+// pileType is the house type of the piles of resources dropped by characters
+// when they die. Piles are deposits, and are as big as whatever was dropped.
+func pileType(game *Game) *HouseType {
+	if game.pileType == nil {
+		game.pileType = &HouseType{MaxResources: 0, Width: 1, Height: 1}
+	}
+	return game.pileType
+}
+
+// killCharacter removes a dead character from the game, leaving whatever it
+// was carrying behind as a pile that anyone can mine.
+func killCharacter(game *Game, who *Character) {
+	emitCharacterEvent(who, EventCharacterKilled, "")
+	fillFootprint(game.terrain, who.Location.X, who.Location.Y,
+		who.Type.Width, who.Type.Height, nil)
+	unregister(game, who.Name, who)
+	who.Target = nil
+	who.work = nil
+	who.route = nil
+
+	if who.Carrying > 0 {
+		loc := Location{X: who.Location.X, Y: who.Location.Y}
+		AddDeposit(game, pileType(game), loc, who.Carrying)
+		who.Carrying = 0
+	}
+}
+
+// resolveCombat carries out every attack due this tick. All of the attacks
+// in a tick land at once, so the order characters are updated in never
+// decides who survives a fight. Dead characters are removed afterwards, and
+// anyone attacking them stops.
+func resolveCombat(game *Game, dt float64) {
+	type hit struct {
+		victim *Character
+		damage float64
+	}
+	var hits []hit
+
+	for _, culture := range game.Cultures {
+		for _, who := range culture.Characters {
+			if who.cooldown > 0 {
+				who.cooldown = who.cooldown - dt
+			}
+			victim, ok := who.Target.(*Character)
+			if !ok || who.cooldown > 0 || !inRange(who, victim) {
+				continue
+			}
+			hits = append(hits, hit{victim, who.Type.AttackDamage})
+			who.cooldown = who.Type.AttackCooldown
+		}
+	}
+	if len(hits) == 0 {
+		return
+	}
+
+	for _, h := range hits {
+		h.victim.Health = h.victim.Health - h.damage
+	}
+
+	for _, culture := range game.Cultures {
+		living := culture.Characters[:0]
+		for _, who := range culture.Characters {
+			if who.Health > 0 {
+				living = append(living, who)
+			} else {
+				killCharacter(game, who)
+			}
+		}
+		for i := len(living); i < len(culture.Characters); i++ {
+			culture.Characters[i] = nil
+		}
+		culture.Characters = living
+	}
+
+	for _, culture := range game.Cultures {
+		for _, who := range culture.Characters {
+			if victim, ok := who.Target.(*Character); ok && victim.Health <= 0 {
+				who.Target = nil
+			}
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package game
+
+import "testing"
+
+var soldierType = &CharacterType{
+	MovePerTick:    1,
+	Width:          1,
+	Height:         1,
+	Health:         20,
+	AttackDamage:   5,
+	AttackCooldown: 2,
+}
+
+var archerType = &CharacterType{
+	MovePerTick:    1,
+	Width:          1,
+	Height:         1,
+	AttackDamage:   2,
+	AttackRange:    4,
+	AttackCooldown: 1,
+}
+
+func TestAttackOrder(t *testing.T) {
+	game := NewGame(16, 16)
+	red := AddCulture(game)
+	green := AddCulture(game)
+	soldier, _ := AddCharacter(game.terrain, red, soldierType, loc0x0)
+	friend, _ := AddCharacter(game.terrain, red, workerType, loc3x3)
+	enemy, _ := AddCharacter(game.terrain, green, workerType, Location{10, 10, 0.0})
+	house := PlanHouse(green, houseType, Location{6, 0, 0.0})
+
+	rejected := []struct {
+		order  *AttackOrder
+		reason string
+	}{
+		{&AttackOrder{"nobody", enemy.Name}, RejectUnknownCharacter},
+		{&AttackOrder{friend.Name, enemy.Name}, RejectCantAttack},
+		{&AttackOrder{soldier.Name, friend.Name}, RejectOwnCulture},
+		{&AttackOrder{soldier.Name, house.Name}, RejectNotCharacter},
+		{&AttackOrder{soldier.Name, "nobody"}, RejectUnknownTarget},
+	}
+	for _, r := range rejected {
+		err, ok := r.order.Apply(game).(*OrderError)
+		if !ok || err.Reason != r.reason {
+			t.Errorf("Expected %v to be rejected with %q, got %v", r.order, r.reason, err)
+		}
+	}
+
+	order := &AttackOrder{soldier.Name, enemy.Name}
+	if err := order.Apply(game); err != nil || soldier.Target != enemy {
+		t.Errorf("Expected soldier to attack enemy, got %v", err)
+	}
+	status := ReadStatus(game).Cultures[0].Characters[0]
+	if !status.Attacking || status.Target != enemy.Name {
+		t.Errorf("Expected status to show the attack, got %v", status)
+	}
+}
+
+func TestGap(t *testing.T) {
+	a := &Character{Type: workerType, Location: loc0x0}
+	cases := []struct {
+		loc Location
+		gap int
+	}{
+		{Location{2, 0, 0.0}, 0},
+		{Location{2, 2, 0.0}, 0},
+		{Location{3, 1, 0.0}, 1},
+		{Location{1, 6, 0.0}, 4},
+	}
+	for _, c := range cases {
+		b := &Character{Type: workerType, Location: c.loc}
+		if got := gap(a, b); got != c.gap {
+			t.Errorf("Expected gap %d to %v, got %d", c.gap, c.loc, got)
+		}
+		if got := gap(b, a); got != c.gap {
+			t.Errorf("Expected gap to be symmetric for %v", c.loc)
+		}
+	}
+}
+
+func TestChaseAndKill(t *testing.T) {
+	for _, scheduling := range []Scheduling{InOrderScheduling, FairScheduling} {
+		game := NewGame(16, 16)
+		SetScheduling(game, scheduling, 1)
+		red := AddCulture(game)
+		green := AddCulture(game)
+		soldier, _ := AddCharacter(game.terrain, red, soldierType, loc0x0)
+		victim, _ := AddCharacter(game.terrain, green, workerType, Location{8, 8, 0.0})
+		victim.Carrying = 7
+		soldier.Target = victim
+
+		for i := 0; i < 40 && len(green.Characters) > 0; i++ {
+			Tick(game, 1.0)
+		}
+
+		if len(green.Characters) != 0 || victim.Health > 0 {
+			t.Fatalf("Expected victim to die, has %v health", victim.Health)
+		}
+		if lookup(game, victim.Name) != nil {
+			t.Errorf("Expected dead character to be forgotten")
+		}
+		if soldier.Target != nil {
+			t.Errorf("Expected soldier to stop attacking the dead")
+		}
+
+		piles := Deposits(game)
+		if len(piles) != 1 || piles[0].ResourcesLeft != 7 {
+			t.Fatalf("Expected a pile of 7 resources, got %v", piles)
+		}
+		pile := piles[0]
+		for x := victim.Location.X; x < victim.Location.X+2; x++ {
+			for y := victim.Location.Y; y < victim.Location.Y+2; y++ {
+				if occupant := game.terrain.Board[x][y]; occupant != nil && occupant != pile {
+					t.Errorf("Expected dead character to leave the board, found %v", occupant)
+				}
+			}
+		}
+	}
+}
+
+func TestFightsAreSimultaneous(t *testing.T) {
+	for _, scheduling := range []Scheduling{InOrderScheduling, FairScheduling} {
+		game := NewGame(8, 8)
+		SetScheduling(game, scheduling, 1)
+		red := AddCulture(game)
+		green := AddCulture(game)
+		a, _ := AddCharacter(game.terrain, red, soldierType, loc0x0)
+		b, _ := AddCharacter(game.terrain, green, soldierType, loc1x0)
+		a.Target = b
+		b.Target = a
+
+		for i := 0; i < 20 && len(red.Characters)+len(green.Characters) > 0; i++ {
+			Tick(game, 1.0)
+		}
+		if len(red.Characters) != 0 || len(green.Characters) != 0 {
+			t.Errorf("Expected evenly matched soldiers to kill each other")
+		}
+		if killed := eventsOfType(TakeEvents(game), EventCharacterKilled); len(killed) != 2 {
+			t.Errorf("Expected both deaths to be reported together, got %v", killed)
+		}
+		if len(Deposits(game)) != 0 {
+			t.Errorf("Expected soldiers with nothing to drop to leave no piles")
+		}
+	}
+}
+
+func TestRangedAttack(t *testing.T) {
+	game := NewGame(16, 16)
+	red := AddCulture(game)
+	green := AddCulture(game)
+	archer, _ := AddCharacter(game.terrain, red, archerType, loc0x0)
+	victim, _ := AddCharacter(game.terrain, green, workerType, Location{5, 0, 0.0})
+	archer.Target = victim
+
+	Tick(game, 1.0)
+	Tick(game, 1.0)
+	if archer.Location != loc0x0 {
+		t.Errorf("Expected archer to attack from where it stands, moved to %v", archer.Location)
+	}
+	if victim.Health != defaultHealth-2*archerType.AttackDamage {
+		t.Errorf("Expected two arrows to hit, victim has %v health", victim.Health)
+	}
+}
+
+func TestSaveKeepsCombat(t *testing.T) {
+	game := NewGame(16, 16)
+	red := AddCulture(game)
+	green := AddCulture(game)
+	soldier, _ := AddCharacter(game.terrain, red, soldierType, loc0x0)
+	victim, _ := AddCharacter(game.terrain, green, workerType, loc3x3)
+	soldier.Target = victim
+	soldier.cooldown = 1.5
+	victim.Health = 4
+
+	loaded := saveAndLoad(t, game)
+	loadedSoldier := loaded.Cultures[0].Characters[0]
+	loadedVictim := loaded.Cultures[1].Characters[0]
+	if loadedSoldier.Target != loadedVictim {
+		t.Errorf("Expected soldier to keep attacking, got %v", loadedSoldier.Target)
+	}
+	if loadedSoldier.cooldown != 1.5 || loadedVictim.Health != 4 {
+		t.Errorf("Unexpected cooldown %v and health %v",
+			loadedSoldier.cooldown, loadedVictim.Health)
+	}
+	if *loadedSoldier.Type != *soldierType {
+		t.Errorf("Expected combat stats to be saved, got %v", loadedSoldier.Type)
+	}
+}
@@ -0,0 +1,53 @@
+package game
+
+import "fmt"
+
+// NeutralCulture is the name shown for the owner of resource deposits.
+// The neutral culture isn't one of a game's Cultures, has no characters, and
+// can't be named in orders, so its deposits can be mined by anyone but never
+// built or rebuilt.
+const NeutralCulture = "neutral"
+
+// neutralCulture returns the culture that owns game's deposits, creating it if
+// it doesn't exist yet.
+func neutralCulture(game *Game) *Culture {
+	if game.neutral == nil {
+		game.neutral = &Culture{
+			PlannedHouses: make(map[*House]bool),
+			BuiltHouses:   make(map[*House]bool),
+			Name:          NeutralCulture,
+			game:          game,
+		}
+	}
+	return game.neutral
+}
+
+// AddDeposit places a pile of resources that any culture's characters can
+// mine. Deposits disappear once they've been mined out. A deposit with no
+// resources starts with houseType.MaxResources.
+func AddDeposit(game *Game, houseType *HouseType, loc Location, resources float64) (*House, error) {
+	if !isTerrainClear(nil, game.terrain, loc.X, loc.Y, houseType.Width, houseType.Height) {
+		return nil, fmt.Errorf("deposit at %d, %d is obstructed", loc.X, loc.Y)
+	}
+	if resources <= 0 {
+		resources = houseType.MaxResources
+	}
+
+	deposit := PlanHouse(neutralCulture(game), houseType, loc)
+	deposit.ResourcesLeft = resources
+	rerankHouse(game.terrain, deposit)
+	return deposit, nil
+}
+
+// IsDeposit reports whether house is a neutral resource deposit.
+func IsDeposit(house *House) bool {
+	return house.Culture.game != nil && house.Culture == house.Culture.game.neutral
+}
+
+// Deposits returns every deposit in game that hasn't been mined out.
+func Deposits(game *Game) []*House {
+	if game.neutral == nil {
+		return nil
+	}
+	return sortedHouses(game.neutral.BuiltHouses)
+}
@@ -0,0 +1,111 @@
+package game
+
+import "testing"
+
+var depositType = &HouseType{
+	MaxResources: 12,
+	Width:        2,
+	Height:       2,
+}
+
+func TestAddDeposit(t *testing.T) {
+	game := NewGame(16, 16)
+	AddCulture(game)
+
+	deposit, err := AddDeposit(game, depositType, loc3x3, 0)
+	if err != nil {
+		t.Fatalf("Unexpected error adding deposit: %v", err)
+	}
+	if deposit.ResourcesLeft != depositType.MaxResources {
+		t.Errorf("Expected deposit to start full, got %v", deposit.ResourcesLeft)
+	}
+	if !IsDeposit(deposit) || game.terrain.Board[4][4] != deposit {
+		t.Errorf("Expected a deposit on the board")
+	}
+	if _, err := AddDeposit(game, depositType, Location{4, 3, 0.0}, 0); err == nil {
+		t.Errorf("Expected an error adding overlapping deposits")
+	}
+
+	status := ReadStatus(game)
+	if len(status.Cultures) != 1 || len(status.Deposits) != 1 {
+		t.Fatalf("Expected one culture and one deposit, got %v", status)
+	}
+	if status.Deposits[0].Name != deposit.Name {
+		t.Errorf("Unexpected deposit status %v", status.Deposits[0])
+	}
+	far := ReadStatusFor(game, game.Cultures[0])
+	if len(far.Deposits) != 1 {
+		t.Errorf("Expected every culture to see deposits")
+	}
+
+	plan := &PlanOrder{Culture: NeutralCulture, HouseType: "house", X: 10, Y: 10}
+	AddHouseType(game, "house", houseType)
+	if err := plan.Apply(game); err == nil {
+		t.Errorf("Expected an error planning a house for the neutral culture")
+	}
+}
+
+func TestMineDepositUntilGone(t *testing.T) {
+	for _, scheduling := range []Scheduling{InOrderScheduling, FairScheduling} {
+		game := NewGame(16, 16)
+		SetScheduling(game, scheduling, 1)
+		AddCulture(game)
+		AddCulture(game)
+		deposit, _ := AddDeposit(game, depositType, Location{6, 6, 0.0}, 0)
+
+		red, _ := AddCharacter(game.terrain, game.Cultures[0], workerType, loc0x0)
+		green, _ := AddCharacter(game.terrain, game.Cultures[1], workerType, Location{12, 12, 0.0})
+		for _, who := range []*Character{red, green} {
+			order := &TargetOrder{Character: who.Name, Target: deposit.Name}
+			if err := order.Apply(game); err != nil {
+				t.Fatalf("Unexpected error targeting deposit: %v", err)
+			}
+		}
+
+		for i := 0; i < 40 && len(Deposits(game)) > 0; i++ {
+			Tick(game, 1.0)
+		}
+
+		if len(Deposits(game)) != 0 {
+			t.Fatalf("Expected deposit to be mined out, %v left", deposit.ResourcesLeft)
+		}
+		if red.Carrying+green.Carrying != depositType.MaxResources {
+			t.Errorf("Expected all %v resources to be carried off, got %v and %v",
+				depositType.MaxResources, red.Carrying, green.Carrying)
+		}
+		if red.Carrying == 0 || green.Carrying == 0 {
+			t.Errorf("Expected both cultures to mine the deposit")
+		}
+		if lookup(game, deposit.Name) != nil || game.terrain.Board[6][6] != nil {
+			t.Errorf("Expected mined out deposit to disappear")
+		}
+		if red.Target != nil || green.Target != nil {
+			t.Errorf("Expected miners to abandon the mined out deposit")
+		}
+	}
+}
+
+func TestSaveKeepsDeposits(t *testing.T) {
+	game := NewGame(16, 16)
+	culture := AddCulture(game)
+	deposit, _ := AddDeposit(game, depositType, loc3x3, 5)
+	who, _ := AddCharacter(game.terrain, culture, workerType, Location{10, 10, 0.0})
+	who.Target = deposit
+
+	loaded := saveAndLoad(t, game)
+	deposits := Deposits(loaded)
+	if len(deposits) != 1 || deposits[0].ResourcesLeft != 5 || !IsDeposit(deposits[0]) {
+		t.Fatalf("Expected deposit to be loaded, got %v", deposits)
+	}
+	if loaded.Cultures[0].Characters[0].Target != deposits[0] {
+		t.Errorf("Expected miner to target the loaded deposit")
+	}
+	if loaded.terrain.Board[3][3] != deposits[0] {
+		t.Errorf("Expected loaded deposit on the board")
+	}
+
+	more, _ := AddDeposit(loaded, depositType, Location{12, 2, 0.0}, 0)
+	if more.planSeq <= deposits[0].planSeq {
+		t.Errorf("Expected new deposits to come after loaded ones")
+	}
+}
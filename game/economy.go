@@ -0,0 +1,111 @@
+package game
+
+// houseExists reports whether house is still planned or built.
+func houseExists(house *House) bool {
+	return house.Culture.PlannedHouses[house] || house.Culture.BuiltHouses[house]
+}
+
+// usingDepot reports whether who is at house to use it as a depot, rather than
+// to build it or mine it. Depots only bank resources once they're finished.
+func usingDepot(who *Character, house *House) bool {
+	return house.Type.Depot && house.Culture == who.Culture && isFinished(house)
+}
+
+// fetching reports whether who is on a trip to a depot to pick up resources
+// for building, rather than to drop resources off.
+func fetching(who *Character) bool {
+	return who.work != nil && who.work.Culture == who.Culture
+}
+
+// nearestDepot finds the closest depot who can use, or nil if its culture
+// hasn't finished one.
+func nearestDepot(who *Character) *House {
+	var ret *House
+	closest := 0
+	here := tile{who.Location.X, who.Location.Y}
+	for _, house := range sortedHouses(who.Culture.BuiltHouses) {
+		if !house.Type.Depot || !isFinished(house) {
+			continue
+		}
+		loc := workLocation(house)
+		dist := distSquared(here, tile{loc.X, loc.Y})
+		if ret == nil || dist < closest {
+			ret = house
+			closest = dist
+		}
+	}
+	return ret
+}
+
+// exchangeAmount is how much who would like to put into its culture's bank
+// this tick, or take out of it if the amount is negative. Characters fetching
+// resources only take what they'll need for the house they're building or
+// stocking.
+func exchangeAmount(who *Character, dt float64) float64 {
+	rate := who.Type.WorkPerTick * dt
+	if fetching(who) {
+		want := who.Type.MaxCarry - who.Carrying
+		needed := resourcesNeeded(who.work) - who.Carrying
+		if needed < want {
+			want = needed
+		}
+		if rate < want {
+			want = rate
+		}
+		if want < 0 {
+			want = 0
+		}
+		return -want
+	}
+
+	if who.Carrying < rate {
+		return who.Carrying
+	}
+	return rate
+}
+
+// exchange moves resources between who and its culture's bank at depot.
+func exchange(who *Character, depot *House, dt float64) {
+	amount := exchangeAmount(who, dt)
+	if -amount > who.Culture.Resources {
+		amount = -who.Culture.Resources
+	}
+	who.Carrying = who.Carrying - amount
+	who.Culture.Resources = who.Culture.Resources + amount
+}
+
+// reevaluateDepotTrip decides whether who is finished at depot, and sends it
+// back to its work if it is.
+func reevaluateDepotTrip(who *Character, depot *House) {
+	work := who.work
+	if work != nil && !houseExists(work) {
+		// Nothing to go back to, so just drop off what we have
+		who.work = nil
+		work = nil
+	}
+
+	if work != nil && work.Culture == who.Culture {
+		needed := resourcesNeeded(work)
+		full := who.Carrying >= who.Type.MaxCarry || who.Carrying >= needed
+		if full || who.Culture.Resources <= 0 {
+			if who.Carrying > 0 {
+				who.Target = work
+			} else {
+				who.Target = nil
+			}
+			who.work = nil
+		}
+		return
+	}
+
+	if who.Carrying <= 0 {
+		// Cultures with AutoJobs reconsider what to do after every trip,
+		// so that they can stop mining when there's building to do.
+		if work != nil && !who.Culture.AutoJobs {
+			who.Target = work
+		} else {
+			who.Target = nil
+		}
+		who.work = nil
+	}
+}
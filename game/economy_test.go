@@ -0,0 +1,163 @@
+package game
+
+import "testing"
+
+var depotType = &HouseType{
+	MaxResources: 20,
+	Width:        2,
+	Height:       2,
+	Depot:        true,
+}
+
+// addDepot adds an already built depot for culture at loc.
+func addDepot(game *Game, culture *Culture, loc Location) *House {
+	depot := PlanHouse(culture, depotType, loc)
+	depot.ResourcesLeft = depotType.MaxResources
+	rerankHouse(game.terrain, depot)
+	return depot
+}
+
+func TestMineAndDeliver(t *testing.T) {
+	for _, scheduling := range []Scheduling{InOrderScheduling, FairScheduling} {
+		game := NewGame(16, 16)
+		SetScheduling(game, scheduling, 1)
+		culture := AddCulture(game)
+		depot := addDepot(game, culture, Location{0, 8, 0.0})
+		deposit, _ := AddDeposit(game, depositType, Location{6, 8, 0.0}, 0)
+		who, _ := AddCharacter(game.terrain, culture, workerType, loc0x0)
+		who.Target = deposit
+
+		for i := 0; i < 100 && len(Deposits(game)) > 0; i++ {
+			Tick(game, 1.0)
+		}
+		if len(Deposits(game)) != 0 {
+			t.Fatalf("Expected deposit to be mined out, %v left", deposit.ResourcesLeft)
+		}
+		for i := 0; i < 20 && who.Target != nil; i++ {
+			Tick(game, 1.0)
+		}
+
+		if culture.Resources != depositType.MaxResources || who.Carrying != 0 {
+			t.Errorf("Expected all %v resources in the bank, got %v banked and %v carried",
+				depositType.MaxResources, culture.Resources, who.Carrying)
+		}
+		if !culture.BuiltHouses[depot] || depot.ResourcesLeft != depotType.MaxResources {
+			t.Errorf("Expected depot to be left alone")
+		}
+		if who.Target != nil || who.work != nil {
+			t.Errorf("Expected miner to go idle once it's delivered everything")
+		}
+	}
+}
+
+func TestBuildFromBank(t *testing.T) {
+	for _, scheduling := range []Scheduling{InOrderScheduling, FairScheduling} {
+		game := NewGame(16, 16)
+		SetScheduling(game, scheduling, 1)
+		culture := AddCulture(game)
+		culture.Resources = 150
+		addDepot(game, culture, Location{0, 8, 0.0})
+		house := PlanHouse(culture, houseType, Location{8, 8, 0.0})
+		who, _ := AddCharacter(game.terrain, culture, workerType, loc0x0)
+		who.Target = house
+
+		for i := 0; i < 400 && house.ResourcesLeft < houseType.MaxResources; i++ {
+			Tick(game, 1.0)
+		}
+
+		if house.ResourcesLeft < houseType.MaxResources {
+			t.Fatalf("Expected house to be built from the bank, %v of %v resources",
+				house.ResourcesLeft, houseType.MaxResources)
+		}
+		if culture.Resources+who.Carrying != 50 {
+			t.Errorf("Expected builder to take only what it needed, %v left in the bank",
+				culture.Resources)
+		}
+	}
+}
+
+func TestBuildDepotThenBank(t *testing.T) {
+	for _, scheduling := range []Scheduling{InOrderScheduling, FairScheduling} {
+		game := NewGame(16, 16)
+		SetScheduling(game, scheduling, 1)
+		culture := AddCulture(game)
+		depot := PlanHouse(culture, depotType, Location{8, 8, 0.0})
+		first, _ := AddCharacter(game.terrain, culture, workerType, loc0x0)
+		second, _ := AddCharacter(game.terrain, culture, workerType, Location{0, 4, 0.0})
+		for _, who := range []*Character{first, second} {
+			who.Carrying = workerType.MaxCarry
+			who.Target = depot
+		}
+
+		for i := 0; i < 200 && depot.ResourcesLeft < depotType.MaxResources; i++ {
+			Tick(game, 1.0)
+		}
+		if depot.ResourcesLeft < depotType.MaxResources || culture.Resources != 0 {
+			t.Fatalf("Expected the depot to be built before anything is banked, got %v of %v built and %v banked",
+				depot.ResourcesLeft, depotType.MaxResources, culture.Resources)
+		}
+
+		first.Carrying = workerType.MaxCarry
+		first.Target = depot
+		for i := 0; i < 20 && first.Target != nil; i++ {
+			Tick(game, 1.0)
+		}
+		if culture.Resources != workerType.MaxCarry || first.Carrying != 0 {
+			t.Errorf("Expected the finished depot to bank %v, got %v banked and %v carried",
+				workerType.MaxCarry, culture.Resources, first.Carrying)
+		}
+	}
+}
+
+func TestBuildWithEmptyBank(t *testing.T) {
+	game := NewGame(16, 16)
+	culture := AddCulture(game)
+	addDepot(game, culture, Location{0, 8, 0.0})
+	house := PlanHouse(culture, houseType, Location{8, 8, 0.0})
+	who, _ := AddCharacter(game.terrain, culture, workerType, loc0x0)
+	who.Target = house
+
+	reevaluateTargetHouse(who)
+	if who.Target != nil {
+		t.Errorf("Expected builder to give up with nothing in the bank")
+	}
+}
+
+func TestMineWithoutDepot(t *testing.T) {
+	game := NewGame(16, 16)
+	culture := AddCulture(game)
+	deposit, _ := AddDeposit(game, depositType, Location{6, 8, 0.0}, 0)
+	who, _ := AddCharacter(game.terrain, culture, workerType, loc0x0)
+	who.Target = deposit
+	who.Carrying = workerType.MaxCarry
+
+	reevaluateTargetHouse(who)
+	if who.Target != nil || culture.Resources != 0 {
+		t.Errorf("Expected a full miner without a depot to go idle")
+	}
+}
+
+func TestSaveKeepsBank(t *testing.T) {
+	game := NewGame(16, 16)
+	culture := AddCulture(game)
+	culture.Resources = 42
+	depot := addDepot(game, culture, Location{0, 8, 0.0})
+	house := PlanHouse(culture, houseType, Location{8, 8, 0.0})
+	who, _ := AddCharacter(game.terrain, culture, workerType, loc0x0)
+	who.Target = depot
+	who.work = house
+
+	loaded := saveAndLoad(t, game)
+	loadedCulture := loaded.Cultures[0]
+	if loadedCulture.Resources != 42 {
+		t.Errorf("Expected 42 banked resources, got %v", loadedCulture.Resources)
+	}
+	loadedWho := loadedCulture.Characters[0]
+	loadedDepot, ok := loadedWho.Target.(*House)
+	if !ok || !loadedDepot.Type.Depot || !loadedCulture.BuiltHouses[loadedDepot] {
+		t.Fatalf("Expected character to target the loaded depot, got %v", loadedWho.Target)
+	}
+	if loadedWho.work == nil || loadedWho.work.Name != house.Name {
+		t.Errorf("Expected character to remember its work, got %v", loadedWho.work)
+	}
+}
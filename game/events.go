@@ -0,0 +1,118 @@
+package game
+
+// EventType says what kind of thing an Event is about.
+type EventType string
+
+const (
+	// A house got all of the resources it needs. Subject is the house.
+	EventHouseCompleted EventType = "houseCompleted"
+
+	// A built house or deposit was mined out, and is gone. Subject is
+	// the house.
+	EventHouseDepleted EventType = "houseDepleted"
+
+	// A plan was dropped to make room for a newer one, because its
+	// culture had made too many. Subject is the plan.
+	EventPlanEvicted EventType = "planEvicted"
+
+	// A character gave up on the house it was targeting, because the
+	// house is gone or it has no way to carry on working on it. Subject
+	// is the character, and Target is the house.
+	EventTargetAbandoned EventType = "targetAbandoned"
+
+	// A character trying to get somewhere couldn't move at all. Each
+	// character is only reported once until it moves again. Subject is
+	// the character.
+	EventCharacterBlocked EventType = "characterBlocked"
+
+	// A house finished training a character. Subject is the new
+	// character, and Target is the house.
+	EventCharacterTrained EventType = "characterTrained"
+
+	// A character died. Subject is the character.
+	EventCharacterKilled EventType = "characterKilled"
+
+	// The game is finished. See the game's GameResult for how it ended.
+	EventGameOver EventType = "gameOver"
+)
+
+// Event is something that happened in a game. Tick is the number of Ticks
+// that had been completed when it happened, Culture is the name of the
+// culture it happened to, if any, and Location is where it happened.
+type Event struct {
+	Tick     int       `json:"tick"`
+	Type     EventType `json:"type"`
+	Culture  string    `json:"culture,omitempty"`
+	Subject  string    `json:"subject,omitempty"`
+	Target   string    `json:"target,omitempty"`
+	Location Location  `json:"location"`
+}
+
+// TakeEvents returns everything that has happened in game since the events
+// were last taken, and forgets about them. Every Tick starts by forgetting
+// any events that haven't been taken, so events that happen while orders
+// are applied between Ticks have to be taken before the next Tick.
+func TakeEvents(game *Game) []Event {
+	ret := game.events
+	game.events = nil
+	return ret
+}
+
+func emit(game *Game, event Event) {
+	if game == nil {
+		return
+	}
+	event.Tick = game.tick
+	game.events = append(game.events, event)
+}
+
+func emitHouseEvent(house *House, eventType EventType) {
+	emit(house.Culture.game, Event{
+		Type:     eventType,
+		Culture:  house.Culture.Name,
+		Subject:  house.Name,
+		Location: house.Location,
+	})
+}
+
+func emitCharacterEvent(who *Character, eventType EventType, target string) {
+	emit(who.Culture.game, Event{
+		Type:     eventType,
+		Culture:  who.Culture.Name,
+		Subject:  who.Name,
+		Target:   target,
+		Location: who.Location,
+	})
+}
+
+// noteResourcesAdded reports house as completed if it has just been given
+// the last of the resources it needs. before is what it had beforehand.
+func noteResourcesAdded(house *House, before float64) {
+	max := house.Type.MaxResources
+	if before < max && house.ResourcesLeft >= max {
+		emitHouseEvent(house, EventHouseCompleted)
+	}
+}
+
+// wantsToMove reports whether who is trying to get somewhere this tick,
+// rather than working, fighting, or standing around.
+func wantsToMove(who *Character) bool {
+	switch target := who.Target.(type) {
+	case *Location:
+		return who.Location.X != target.X || who.Location.Y != target.Y
+	case *House:
+		return !insideOfShadow(defaultShadowSize, who, target)
+	case *Character:
+		return !inRange(who, target)
+	}
+	return false
+}
+
+// noteBlocked records whether who was stuck this tick, and reports it the
+// first time it gets stuck.
+func noteBlocked(who *Character, blocked bool) {
+	if blocked && !who.blocked {
+		emitCharacterEvent(who, EventCharacterBlocked, "")
+	}
+	who.blocked = blocked
+}
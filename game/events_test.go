@@ -0,0 +1,123 @@
+package game
+
+import "testing"
+
+// eventsOfType picks the events of one type out of events.
+func eventsOfType(events []Event, eventType EventType) []Event {
+	var ret []Event
+	for _, event := range events {
+		if event.Type == eventType {
+			ret = append(ret, event)
+		}
+	}
+	return ret
+}
+
+func TestHouseCompletedEvent(t *testing.T) {
+	for _, scheduling := range []Scheduling{InOrderScheduling, FairScheduling} {
+		game := NewGame(16, 16)
+		SetScheduling(game, scheduling, 1)
+		culture := AddCulture(game)
+		house := PlanHouse(culture, houseType, Location{6, 6, 0.0})
+		who, _ := AddCharacter(game.terrain, culture, workerType, loc0x0)
+		who.Carrying = houseType.MaxResources
+		who.Target = house
+
+		var events []Event
+		for i := 0; i < 60; i++ {
+			Tick(game, 1.0)
+			events = append(events, TakeEvents(game)...)
+		}
+
+		completed := eventsOfType(events, EventHouseCompleted)
+		if len(completed) != 1 || completed[0].Subject != house.Name ||
+			completed[0].Culture != culture.Name {
+			t.Errorf("Expected the house to be completed once, got %v", events)
+		}
+		if len(eventsOfType(events, EventTargetAbandoned)) != 0 {
+			t.Errorf("Expected a finished builder not to abandon anything, got %v", events)
+		}
+	}
+}
+
+func TestHouseDepletedEvent(t *testing.T) {
+	game := NewGame(16, 16)
+	culture := AddCulture(game)
+	deposit, _ := AddDeposit(game, depositType, Location{4, 0, 0.0}, 3)
+	who, _ := AddCharacter(game.terrain, culture, workerType, loc0x0)
+	who.Target = deposit
+
+	var events []Event
+	for i := 0; i < 10; i++ {
+		Tick(game, 1.0)
+		events = append(events, TakeEvents(game)...)
+	}
+
+	depleted := eventsOfType(events, EventHouseDepleted)
+	if len(depleted) != 1 || depleted[0].Subject != deposit.Name ||
+		depleted[0].Culture != NeutralCulture {
+		t.Errorf("Expected the deposit to be depleted once, got %v", events)
+	}
+	abandoned := eventsOfType(events, EventTargetAbandoned)
+	if len(abandoned) != 1 || abandoned[0].Subject != who.Name ||
+		abandoned[0].Target != deposit.Name {
+		t.Errorf("Expected the miner to abandon the deposit, got %v", events)
+	}
+}
+
+func TestPlanEvictedEvent(t *testing.T) {
+	game := NewGame(16, 16)
+	culture := AddCulture(game)
+	first := PlanHouse(culture, houseType, loc0x0)
+	for i := 0; i < maxPlansAllowedPerCulture; i++ {
+		PlanHouse(culture, houseType, loc3x3)
+	}
+
+	events := TakeEvents(game)
+	if len(events) != 1 || events[0].Type != EventPlanEvicted || events[0].Subject != first.Name {
+		t.Errorf("Expected the first plan to be evicted, got %v", events)
+	}
+	if TakeEvents(game) != nil {
+		t.Errorf("Expected events to only be taken once")
+	}
+}
+
+func TestCharacterBlockedEvent(t *testing.T) {
+	for _, scheduling := range []Scheduling{InOrderScheduling, FairScheduling} {
+		game := NewGame(4, 2)
+		SetScheduling(game, scheduling, 1)
+		culture := AddCulture(game)
+		who, _ := AddCharacter(game.terrain, culture, workerType, loc0x0)
+		AddCharacter(game.terrain, culture, workerType, Location{2, 0, 0.0})
+		who.Target = &Location{2, 0, 0.0}
+
+		var events []Event
+		for i := 0; i < 5; i++ {
+			Tick(game, 1.0)
+			events = append(events, TakeEvents(game)...)
+		}
+		blocked := eventsOfType(events, EventCharacterBlocked)
+		if len(blocked) != 1 || blocked[0].Subject != who.Name {
+			t.Errorf("Expected the worker to be reported blocked once, got %v", events)
+		}
+	}
+}
+
+func TestTickForgetsOldEvents(t *testing.T) {
+	game := NewGame(8, 8)
+	culture := AddCulture(game)
+	AddWinCondition(game, TimeLimit{Ticks: 2})
+	for i := 0; i <= maxPlansAllowedPerCulture; i++ {
+		PlanHouse(culture, houseType, loc0x0)
+	}
+
+	Tick(game, 1.0)
+	if events := TakeEvents(game); len(events) != 0 {
+		t.Errorf("Expected events from before the tick to be forgotten, got %v", events)
+	}
+	Tick(game, 1.0)
+	events := TakeEvents(game)
+	if len(events) != 1 || events[0].Type != EventGameOver || events[0].Tick != 2 {
+		t.Errorf("Expected the game to be over, got %v", events)
+	}
+}
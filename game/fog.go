@@ -0,0 +1,240 @@
+package game
+
+// defaultSight is how far characters and houses can see when their type
+// doesn't say otherwise.
+const defaultSight = 8
+
+// visibility records which tiles of a terrain a culture can see.
+type visibility struct {
+	tiles         [][]bool
+	width, height int
+}
+
+// rememberedHouse is what a culture last saw of another culture's house.
+type rememberedHouse struct {
+	owner  string
+	status HouseStatus
+}
+
+func sightOf(sight int) int {
+	if sight <= 0 {
+		return defaultSight
+	}
+	return sight
+}
+
+// reveal marks every tile within radius of the x, y, width, height rectangle
+// as visible.
+func reveal(v *visibility, x, y, width, height, radius int) {
+	for tx := x - radius; tx < x+width+radius; tx++ {
+		if tx < 0 || tx >= v.width {
+			continue
+		}
+		for ty := y - radius; ty < y+height+radius; ty++ {
+			if ty < 0 || ty >= v.height {
+				continue
+			}
+			dx, dy := 0, 0
+			if tx < x {
+				dx = x - tx
+			} else if tx >= x+width {
+				dx = tx - (x + width - 1)
+			}
+			if ty < y {
+				dy = y - ty
+			} else if ty >= y+height {
+				dy = ty - (y + height - 1)
+			}
+			if dx*dx+dy*dy <= radius*radius {
+				v.tiles[tx][ty] = true
+			}
+		}
+	}
+}
+
+// isVisible reports whether any tile of the x, y, width, height rectangle is
+// visible.
+func isVisible(v *visibility, x, y, width, height int) bool {
+	for tx := x; tx < x+width; tx++ {
+		for ty := y; ty < y+height; ty++ {
+			if tx >= 0 && tx < v.width && ty >= 0 && ty < v.height && v.tiles[tx][ty] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isHouseVisible(v *visibility, house *House) bool {
+	return isVisible(v, house.Location.X, house.Location.Y,
+		house.Type.Width, house.Type.Height)
+}
+
+// cultureVisibility works out what culture can see, from the sight of its
+// characters and built houses.
+func cultureVisibility(game *Game, culture *Culture) *visibility {
+	v := &visibility{
+		tiles:  make([][]bool, game.terrain.Width),
+		width:  game.terrain.Width,
+		height: game.terrain.Height,
+	}
+	for i := range v.tiles {
+		v.tiles[i] = make([]bool, game.terrain.Height)
+	}
+
+	for _, who := range culture.Characters {
+		reveal(v, who.Location.X, who.Location.Y,
+			who.Type.Width, who.Type.Height, sightOf(who.Type.Sight))
+	}
+	for house := range culture.BuiltHouses {
+		reveal(v, house.Location.X, house.Location.Y,
+			house.Type.Width, house.Type.Height, sightOf(house.Type.Sight))
+	}
+
+	return v
+}
+
+func visibleHouses(v *visibility, houses map[*House]bool) map[*House]bool {
+	ret := make(map[*House]bool)
+	for house := range houses {
+		if isHouseVisible(v, house) {
+			ret[house] = true
+		}
+	}
+	return ret
+}
+
+// hidePlans clears the priorities and training queues of other cultures'
+// houses, which are none of the viewer's business.
+func hidePlans(houses []HouseStatus) []HouseStatus {
+	for i := range houses {
+		houses[i].Priority = 0
+		houses[i].Queue = 0
+		houses[i].Stock = 0
+		houses[i].Progress = 0
+	}
+	return houses
+}
+
+// hideOrders clears what another culture's character is carrying and what
+// it has been told to do, which the viewer can't know by looking at it.
+func hideOrders(who CharacterStatus) CharacterStatus {
+	who.Carrying = 0
+	who.Target = ""
+	who.Attacking = false
+	who.Job = ""
+	who.Marching = false
+	who.Destination = Location{}
+	return who
+}
+
+// rememberHouses updates what every culture remembers of the other cultures'
+// built houses: houses it can see now are remembered as they are, and houses
+// it can see the spot of but that aren't there anymore are forgotten. Tick
+// calls it once the tick is done, so what a culture remembers doesn't depend
+// on whether anyone is reading its status.
+func rememberHouses(game *Game) {
+	for _, culture := range game.Cultures {
+		v := cultureVisibility(game, culture)
+		if culture.memory == nil {
+			culture.memory = make(map[string]rememberedHouse)
+		}
+
+		seen := make(map[string]bool)
+		for _, other := range game.Cultures {
+			if other == culture {
+				continue
+			}
+			built := hidePlans(readHouseStatuses(game, visibleHouses(v, other.BuiltHouses)))
+			for _, status := range built {
+				seen[status.Name] = true
+				status.Remembered = true
+				status.LastSeen = game.tick
+				culture.memory[status.Name] = rememberedHouse{other.Name, status}
+			}
+		}
+
+		for name, remembered := range culture.memory {
+			status := remembered.status
+			if !seen[name] && isVisible(v, status.Location.X, status.Location.Y,
+				status.Width, status.Height) {
+				// We can see where it was, and it isn't there anymore
+				delete(culture.memory, name)
+			}
+		}
+	}
+}
+
+// ReadStatusFor returns a snapshot of game as culture sees it. Other
+// cultures' characters and houses are left out unless culture can see them,
+// except that houses culture has seen before are included, as they were when
+// last seen. Other cultures' plans and orders are hidden. Reading a status
+// doesn't change the game; see rememberHouses.
+func ReadStatusFor(game *Game, culture *Culture) GameStatus {
+	v := cultureVisibility(game, culture)
+
+	ret := GameStatus{
+		Tick:     game.tick,
+		Viewer:   culture.Name,
+		Width:    game.terrain.Width,
+		Height:   game.terrain.Height,
+		Tiles:    tileRows(game.terrain),
+		Cultures: make([]CultureStatus, len(game.Cultures)),
+		Deposits: readDepositStatuses(game),
+		Phase:    game.phase,
+		Result:   FinalResult(game),
+	}
+
+	indexes := make(map[string]int)
+	seen := make(map[string]bool)
+	for i, other := range game.Cultures {
+		indexes[other.Name] = i
+		if other == culture {
+			ret.Cultures[i] = readCultureStatus(game, other)
+			continue
+		}
+
+		characters := make([]CharacterStatus, 0)
+		for _, who := range other.Characters {
+			if isVisible(v, who.Location.X, who.Location.Y,
+				who.Type.Width, who.Type.Height) {
+				characters = append(characters,
+					hideOrders(readCharacterStatus(game, who)))
+			}
+		}
+
+		built := hidePlans(readHouseStatuses(game, visibleHouses(v, other.BuiltHouses)))
+		for _, status := range built {
+			seen[status.Name] = true
+		}
+
+		ret.Cultures[i] = CultureStatus{
+			Name:       other.Name,
+			Characters: characters,
+			PlannedHouses: hidePlans(readHouseStatuses(game,
+				visibleHouses(v, other.PlannedHouses))),
+			BuiltHouses: built,
+		}
+	}
+
+	for name, remembered := range culture.memory {
+		if seen[name] {
+			continue
+		}
+		status := remembered.status
+		if isVisible(v, status.Location.X, status.Location.Y,
+			status.Width, status.Height) {
+			// We can see where it was, and it isn't there anymore
+			continue
+		}
+		if i, ok := indexes[remembered.owner]; ok {
+			ret.Cultures[i].BuiltHouses = append(ret.Cultures[i].BuiltHouses, status)
+		}
+	}
+
+	for i := range ret.Cultures {
+		sortHouseStatuses(ret.Cultures[i].BuiltHouses)
+	}
+
+	return ret
+}
@@ -0,0 +1,145 @@
+package game
+
+import "testing"
+
+var scoutType = &CharacterType{
+	MovePerTick: 1,
+	WorkPerTick: 4,
+	MaxCarry:    10,
+	Width:       1,
+	Height:      1,
+	Sight:       3,
+}
+
+func findCultureStatus(status GameStatus, name string) CultureStatus {
+	for _, culture := range status.Cultures {
+		if culture.Name == name {
+			return culture
+		}
+	}
+	return CultureStatus{}
+}
+
+func TestReadStatusForHidesDistantEnemies(t *testing.T) {
+	game := NewGame(32, 32)
+	red := AddCulture(game)
+	green := AddCulture(game)
+
+	AddCharacter(game.terrain, red, scoutType, loc0x0)
+	near, _ := AddCharacter(game.terrain, green, scoutType, Location{2, 2, 0.0})
+	AddCharacter(game.terrain, green, scoutType, Location{20, 20, 0.0})
+	PlanHouse(green, houseType, Location{3, 0, 0.0})
+	PlanHouse(green, houseType, Location{25, 25, 0.0})
+
+	status := ReadStatusFor(game, red)
+	if status.Viewer != red.Name {
+		t.Errorf("Expected viewer %q, got %q", red.Name, status.Viewer)
+	}
+
+	greenStatus := findCultureStatus(status, green.Name)
+	if len(greenStatus.Characters) != 1 || greenStatus.Characters[0].Name != near.Name {
+		t.Errorf("Expected to see only the nearby enemy, saw %v",
+			greenStatus.Characters)
+	}
+	if len(greenStatus.PlannedHouses) != 1 {
+		t.Errorf("Expected to see only the nearby plan, saw %v",
+			greenStatus.PlannedHouses)
+	}
+
+	redStatus := findCultureStatus(status, red.Name)
+	if len(redStatus.Characters) != 1 {
+		t.Errorf("Culture can't see its own characters")
+	}
+}
+
+func TestReadStatusForHidesEnemyOrders(t *testing.T) {
+	game := NewGame(16, 16)
+	red := AddCulture(game)
+	green := AddCulture(game)
+	AddCharacter(game.terrain, red, scoutType, loc0x0)
+	house := PlanHouse(green, houseType, Location{6, 0, 0.0})
+	builder, _ := AddCharacter(game.terrain, green, scoutType, Location{2, 0, 0.0})
+	builder.Carrying = 5
+	builder.Target = house
+	marcher, _ := AddCharacter(game.terrain, green, scoutType, Location{0, 2, 0.0})
+	marcher.Target = &Location{10, 10, 0.0}
+
+	greens := findCultureStatus(ReadStatusFor(game, red), green.Name)
+	if len(greens.Characters) != 2 {
+		t.Fatalf("Expected to see both enemies, saw %v", greens.Characters)
+	}
+	for _, who := range greens.Characters {
+		if who.Carrying != 0 || who.Target != "" || who.Job != "" ||
+			who.Marching || who.Destination != (Location{}) {
+			t.Errorf("Expected the enemy's orders to be hidden, saw %v", who)
+		}
+	}
+
+	own := findCultureStatus(ReadStatusFor(game, green), green.Name)
+	if own.Characters[0].Target != house.Name || own.Characters[0].Carrying != 5 ||
+		!own.Characters[1].Marching {
+		t.Errorf("Expected a culture to see its own orders, saw %v", own.Characters)
+	}
+}
+
+func TestReadStatusForSharesSightBetweenCharacters(t *testing.T) {
+	game := NewGame(32, 32)
+	red := AddCulture(game)
+	green := AddCulture(game)
+
+	AddCharacter(game.terrain, red, scoutType, loc0x0)
+	AddCharacter(game.terrain, red, scoutType, Location{20, 20, 0.0})
+	AddCharacter(game.terrain, green, scoutType, Location{22, 22, 0.0})
+
+	greenStatus := findCultureStatus(ReadStatusFor(game, red), green.Name)
+	if len(greenStatus.Characters) != 1 {
+		t.Errorf("Characters don't share sight with their culture")
+	}
+}
+
+func TestReadStatusForRemembersBuildings(t *testing.T) {
+	game := NewGame(32, 32)
+	red := AddCulture(game)
+	green := AddCulture(game)
+
+	scout, _ := AddCharacter(game.terrain, red, scoutType, Location{10, 10, 0.0})
+	house := PlanHouse(green, houseType, Location{12, 12, 0.0})
+	house.ResourcesLeft = 50
+	rerankHouse(game.terrain, house)
+
+	ReadStatusFor(game, red)
+	if len(red.memory) != 0 {
+		t.Errorf("Reading a status changed what red remembers: %v", red.memory)
+	}
+	Tick(game, 1)
+
+	scout.Target = &Location{0, 0, 0.0}
+	for scout.Location != loc0x0 {
+		Tick(game, 1)
+	}
+	house.ResourcesLeft = 20
+
+	built := findCultureStatus(ReadStatusFor(game, red), green.Name).BuiltHouses
+	if len(built) != 1 {
+		t.Fatalf("Forgot about house once out of sight")
+	}
+	if !built[0].Remembered || built[0].LastSeen != 1 || built[0].ResourcesLeft != 50 {
+		t.Errorf("Unexpected memory of house %v", built[0])
+	}
+
+	house.ResourcesLeft = 0
+	rerankHouse(game.terrain, house)
+	built = findCultureStatus(ReadStatusFor(game, red), green.Name).BuiltHouses
+	if len(built) != 1 {
+		t.Errorf("Learned about destroyed house without seeing it")
+	}
+
+	scout.Target = &Location{10, 10, 0.0}
+	for scout.Location != (Location{10, 10, 0.0}) {
+		Tick(game, 1)
+	}
+	built = findCultureStatus(ReadStatusFor(game, red), green.Name).BuiltHouses
+	if len(built) != 0 {
+		t.Errorf("Still remembering a house that's gone: %v", built)
+	}
+}
@@ -11,29 +11,42 @@ import (
 
 const defaultShadowSize = 1
 
-// Terrain is a space that contains a game
+// Terrain is a space that contains a game. Board holds the character or house
+// occupying each tile, and Tiles holds the ground underneath.
 type Terrain struct {
 	Board         [][]interface{}
+	Tiles         [][]TileKind
 	Width, Height int
 }
 
 // Location is an absolute spot in a Terrain, including a discrete position and
 // a cosmetic offset to show continuous motion
 type Location struct {
-	X, Y   int
-	Offset float64
+	X      int     `json:"x"`
+	Y      int     `json:"y"`
+	Offset float64 `json:"offset"`
 }
 
 // CharacterType describes attributes shared between characters, like their
-// movement speed or how many resources they can carry
+// movement speed or how many resources they can carry. Characters attack
+// other characters within AttackRange tiles of them, once every
+// AttackCooldown, and types without AttackDamage can't attack at all.
 type CharacterType struct {
-	MovePerTick, WorkPerTick, MaxCarry float64
-	Width, Height                      int
+	MovePerTick    float64 `json:"movePerTick"`
+	WorkPerTick    float64 `json:"workPerTick"`
+	MaxCarry       float64 `json:"maxCarry"`
+	Width          int     `json:"width"`
+	Height         int     `json:"height"`
+	Sight          int     `json:"sight"`  // defaults to defaultSight
+	Health         float64 `json:"health"` // defaults to defaultHealth
+	AttackDamage   float64 `json:"attackDamage"`
+	AttackRange    int     `json:"attackRange"`
+	AttackCooldown float64 `json:"attackCooldown"`
 }
 
 // Character is an individual agent in the game - Characters have a type,
 // belong to a culture, occupy a position in the Terrain, and have a target for
-// their moves.
+// their moves. Characters die when their Health runs out.
 type Character struct {
 	Carrying float64
 	Culture  *Culture
@@ -41,13 +54,27 @@ type Character struct {
 	Target   interface{}
 	Type     *CharacterType
 	Name     string
+	Health   float64
+	route    *route
+	work     *House  // the house a trip to a depot is on behalf of
+	cooldown float64 // time until the character can attack again
+	blocked  bool    // couldn't move toward its target last tick
 }
 
 // HouseType is a collection of attributes shared by many houses, for example
 // how many resources are required for a completed house.
 type HouseType struct {
-	MaxResources  float64
-	Width, Height int
+	MaxResources float64 `json:"maxResources"`
+	Width        int     `json:"width"`
+	Height       int     `json:"height"`
+	Sight        int     `json:"sight"` // defaults to defaultSight
+	Depot        bool    `json:"depot"` // built depots hold the culture's resources
+
+	// Houses that train characters are stocked with TrainCost resources
+	// for each one, and take TrainTime seconds to train it. See TrainOrder.
+	Trains    string  `json:"trains"` // the name of a character type, if any
+	TrainCost float64 `json:"trainCost"`
+	TrainTime float64 `json:"trainTime"`
 }
 
 // House is a structure located in Terrain, that is made of resources. The
@@ -59,6 +86,13 @@ type House struct {
 	Location      Location
 	ResourcesLeft float64
 	Name          string
+	Priority      int // higher priority houses are built first, see AssignJobs
+	planSeq       int // orders plans, so the oldest can be evicted first
+
+	queue    int     // characters waiting to be trained
+	stock    float64 // resources delivered for training
+	training bool    // whether the first in the queue has been paid for
+	progress float64 // seconds spent training the first in the queue
 }
 
 // Culture is a collection Characters and Houses (including Houses that don't
@@ -68,23 +102,48 @@ type Culture struct {
 	Characters    []*Character
 	PlannedHouses map[*House]bool
 	BuiltHouses   map[*House]bool
+	Resources     float64 // banked in depots
+	Name          string
+	AutoJobs      bool // idle characters find their own work, see AssignJobs
+	game          *Game
+	planCount     int
+	memory        map[string]rememberedHouse
+	hasBuilt      bool // has ever had a built house
 }
 
 // Game is a universe of Cultures and their Terrain.
 type Game struct {
-	Cultures []*Culture
-	terrain  Terrain
+	Cultures       []*Culture
+	terrain        Terrain
+	names          map[string]interface{}
+	houseTypes     map[string]*HouseType
+	characterTypes map[string]*CharacterType
+	tick           int
+	journal        []OrderResult
+	scheduling     Scheduling
+	seed           int64
+	nameCount      int
+	neutral        *Culture   // owns the deposits, see AddDeposit
+	pileType       *HouseType // for deposits dropped by the dead
+	phase          Phase
+	started        int         // the tick the game left the lobby at
+	result         *GameResult // set once the game is finished
+	winConditions  []WinCondition
+	events         []Event // not yet taken, see TakeEvents
 }
 
 func DumpTerrain(terrain Terrain) {
 	chars := "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ?"
 	nextChar := 0
 	thingToName := make(map[interface{}]string)
-	thingToName[nil] = "-"
 
 	for row := 0; row < terrain.Height; row++ {
 		for col := 0; col < terrain.Width; col++ {
 			obj := terrain.Board[col][row]
+			if obj == nil {
+				fmt.Printf("%c", tileKinds[tileAt(terrain, col, row)].symbol)
+				continue
+			}
 			name, ok := thingToName[obj]
 			if !ok {
 				thingToName[obj] = string(chars[nextChar])
@@ -110,6 +169,9 @@ func isTerrainClear(who interface{}, terrain Terrain, x, y, width, height int) b
 	if y < 0 || y+height > terrain.Height {
 		return false
 	}
+	if !isPassable(terrain, x, y, width, height) {
+		return false
+	}
 
 	for checkX := 0; checkX < width; checkX++ {
 		for checkY := 0; checkY < height; checkY++ {
@@ -125,16 +187,16 @@ func isTerrainClear(who interface{}, terrain Terrain, x, y, width, height int) b
 }
 
 const maxShortMoveSide = 8 // maxShortMoveSide must be less than sqrt MAX_INT
-const maxFringeLength = maxShortMoveSide * maxShortMoveSide
 
 type tile struct {
 	x, y int
 }
 
 // steps are a region, with a corner at oX, oY, extending into the dirX, dirY
-// quarter plane.
+// quarter plane. cost holds the movement it takes to reach each tile of the
+// region, or -1 for tiles that haven't been reached.
 type steps struct {
-	count      [maxShortMoveSide][maxShortMoveSide]int
+	cost       [maxShortMoveSide][maxShortMoveSide]float64
 	oX, oY     int // origin point of this region in the larger terrain
 	dirX, dirY int // direction that this region extends from the origin
 }
@@ -144,16 +206,16 @@ func distSquared(a, b tile) int {
 	return dx*dx + dy*dy
 }
 
-func writeStep(count int, s *steps, t tile) {
+func writeStep(cost float64, s *steps, t tile) {
 	stepsX := (t.x - s.oX) * s.dirX
 	stepsY := (t.y - s.oY) * s.dirY
-	s.count[stepsX][stepsY] = count
+	s.cost[stepsX][stepsY] = cost
 }
 
-func readStep(s *steps, t tile) int {
+func readStep(s *steps, t tile) float64 {
 	stepsX := (t.x - s.oX) * s.dirX
 	stepsY := (t.y - s.oY) * s.dirY
-	return s.count[stepsX][stepsY]
+	return s.cost[stepsX][stepsY]
 }
 
 func checkMove(who *Character, terrain Terrain, s *steps, x, y int) bool {
@@ -172,9 +234,6 @@ func checkMove(who *Character, terrain Terrain, s *steps, x, y int) bool {
 	if stepY < 0 || stepY >= maxShortMoveSide {
 		return false
 	}
-	if s.count[stepX][stepY] != -1 {
-		return false
-	}
 
 	return isTerrainClear(
 		who,
@@ -185,35 +244,47 @@ func checkMove(who *Character, terrain Terrain, s *steps, x, y int) bool {
 	)
 }
 
+// neighbors are the tiles a character can step to from t.
+func neighbors(t tile) [4]tile {
+	return [...]tile{
+		{t.x + 1, t.y},
+		{t.x, t.y + 1},
+		{t.x - 1, t.y},
+		{t.x, t.y - 1},
+	}
+}
+
 // attemptShortMove attempts to move a character across some terrain toward a
 // goal with a given distance budget. It returns the distance traveled by the
 // character and updates the location of the character in-place.
 // "Short moves" take place inside of a maxShortSide x maxShortSide square -
 // the character will attempt to move to the spot in the square closest to the
-// goal.
+// goal. Each step costs the moveCost of the ground being stepped on, so
+// characters go further on roads and less far through forests.
 func attemptShortMove(who *Character, terrain Terrain, goal Location, walkDistance float64) float64 {
 	if walkDistance < 0 {
 		panic("walkDistance must not be negative")
 	}
 
-	totalDistance := walkDistance + who.Location.Offset
+	start := tile{who.Location.X, who.Location.Y}
+	carried := who.Location.Offset * stepCost(who, terrain, start)
+	totalDistance := walkDistance + carried
 
 	// offset preserves the leftover walkDistance
-	// between calls to attemptShortMove, so that
+	// between calls to attemptShortMove, as the fraction of the next
+	// step it pays for, so that on plain ground
 	//
 	//    attemptShortMove(..., far_away, 0.4)
 	//    attemptShortMove(..., far_away, 0.4)
 	//    attemptShortMove(..., far_away, 0.4)
 	//
-	// Ends with a move of one tile and an offset of 0.2
+	// Ends with a move of one tile and an offset of 0.2, and in a
+	// forest, where steps cost 2, with no move and an offset of 0.6
 	//
 	// As a special case, if the character arrives at goal, then its
 	// offset will be goal.Offset - the character will decline to move
 	// all of walkDistance.
 
-	stepDistancef, offset := math.Modf(totalDistance)
-	stepDistance := int(stepDistancef)
-
 	visionOffsetX, visionOffsetY := 0, 0
 	dirX, dirY := 1, 1
 	dx, dy := goal.X-who.Location.X, goal.Y-who.Location.Y
@@ -251,83 +322,96 @@ func attemptShortMove(who *Character, terrain Terrain, goal Location, walkDistan
 		dirY: dirY,
 	}
 
-	for x := range steps.count {
-		for y := range steps.count[x] {
-			steps.count[x][y] = -1
+	for x := range steps.cost {
+		for y := range steps.cost[x] {
+			steps.cost[x][y] = -1
 		}
 	}
 
-	var fringe [maxFringeLength]tile
 	goalTile := tile{goal.X, goal.Y}
-	fringe[0] = tile{who.Location.X, who.Location.Y}
-	fringeStart := 0
-	fringeEnd := 1
-	writeStep(0, steps, fringe[0])
-
-	for fringeStart < fringeEnd {
-		current := fringe[fringeStart]
-		currentDist := readStep(steps, current)
-		fringeStart++
-		if currentDist < stepDistance {
-			if checkMove(who, terrain, steps, current.x+1, current.y) {
-				fringe[fringeEnd] = tile{current.x + 1, current.y}
-				writeStep(currentDist+1, steps, fringe[fringeEnd])
-				fringeEnd++
-			}
-			if checkMove(who, terrain, steps, current.x, current.y+1) {
-				fringe[fringeEnd] = tile{current.x, current.y + 1}
-				writeStep(currentDist+1, steps, fringe[fringeEnd])
-				fringeEnd++
+	writeStep(0, steps, start)
+
+	// Dijkstra's algorithm, over a region small enough that we can find the
+	// next tile to settle by looking at all of them.
+	var settled [maxShortMoveSide][maxShortMoveSide]bool
+	for {
+		found := false
+		var current tile
+		currentCost := 0.0
+		for x := range steps.cost {
+			for y := range steps.cost[x] {
+				cost := steps.cost[x][y]
+				if settled[x][y] || cost == -1 || (found && cost >= currentCost) {
+					continue
+				}
+				found = true
+				currentCost = cost
+				current = tile{steps.oX + x*steps.dirX, steps.oY + y*steps.dirY}
 			}
-			if checkMove(who, terrain, steps, current.x-1, current.y) {
-				fringe[fringeEnd] = tile{current.x - 1, current.y}
-				writeStep(currentDist+1, steps, fringe[fringeEnd])
-				fringeEnd++
+		}
+		if !found {
+			break
+		}
+		settled[(current.x-steps.oX)*steps.dirX][(current.y-steps.oY)*steps.dirY] = true
+
+		for _, next := range neighbors(current) {
+			if !checkMove(who, terrain, steps, next.x, next.y) {
+				continue
 			}
-			if checkMove(who, terrain, steps, current.x, current.y-1) {
-				fringe[fringeEnd] = tile{current.x, current.y - 1}
-				writeStep(currentDist+1, steps, fringe[fringeEnd])
-				fringeEnd++
+			cost := currentCost + moveCost(terrain, next.x, next.y,
+				who.Type.Width, who.Type.Height)
+			known := readStep(steps, next)
+			if cost <= totalDistance && (known == -1 || cost < known) {
+				writeStep(cost, steps, next)
 			}
 		}
 	}
 
-	// We've found the shortest distance to every reachable point in
-	// our "vision" range.
+	// We've found the cheapest way to every reachable point in our
+	// "vision" range.
 
-	closestPoint := tile{who.Location.X, who.Location.Y}
-	closestSteps := readStep(steps, closestPoint)
+	closestPoint := start
+	closestCost := readStep(steps, closestPoint)
 	closestDistSquared := distSquared(closestPoint, goalTile)
 
-	for x := range steps.count {
-		for y := range steps.count[x] {
+	for x := range steps.cost {
+		for y := range steps.cost[x] {
 			pt := tile{
 				x: steps.oX + (x * steps.dirX),
 				y: steps.oY + (y * steps.dirY),
 			}
-			ptSteps := readStep(steps, pt)
+			ptCost := readStep(steps, pt)
 
-			if ptSteps != -1 {
+			if ptCost != -1 {
 				newDSquared := distSquared(pt, goalTile)
 				if newDSquared < closestDistSquared {
 					closestPoint = pt
-					closestSteps = ptSteps
+					closestCost = ptCost
 					closestDistSquared = newDSquared
 				}
 			}
 		}
 	}
 
+	// Movement we couldn't spend is kept as offset, but only as much as
+	// it takes to make the next step. On plain ground that's whatever is
+	// left after the last whole tile.
+	endStep := stepCost(who, terrain, closestPoint)
+	leftover := math.Mod(totalDistance-closestCost, endStep)
+	offset := leftover / endStep
+
 	if closestPoint == goalTile && offset > goal.Offset {
 		// If the character arrives at goal, it won't continue
 		// walking, so we discard leftover offset
 		offset = goal.Offset
+		leftover = offset * endStep
 	}
 
 	// closestPoint now contains the closest reachable point to goal
 	// within our search area.
 
-	// closestSteps now contains the distance in steps to closestPoint
+	// closestCost now contains the movement it takes to get to
+	// closestPoint
 
 	for x := 0; x < who.Type.Width; x++ {
 		for y := 0; y < who.Type.Height; y++ {
@@ -337,7 +421,6 @@ func attemptShortMove(who *Character, terrain Terrain, goal Location, walkDistan
 		}
 	}
 
-	originalOffset := who.Location.Offset
 	who.Location.X = closestPoint.x
 	who.Location.Y = closestPoint.y
 	who.Location.Offset = offset
@@ -350,15 +433,34 @@ func attemptShortMove(who *Character, terrain Terrain, goal Location, walkDistan
 		}
 	}
 
-	// TODO this could be negative!
-	return float64(closestSteps) + (who.Location.Offset - originalOffset)
+	// Discarding offset at the goal isn't walking backwards.
+	return math.Max(0, closestCost+leftover-carried)
 }
 
+// stepCost is the most a single step away from at can cost who, and so the
+// most movement worth keeping as offset while standing there.
+func stepCost(who *Character, terrain Terrain, at tile) float64 {
+	ret := 1.0
+	for _, next := range neighbors(at) {
+		if inBounds(terrain, next.x, next.y, who.Type.Width, who.Type.Height) {
+			cost := moveCost(terrain, next.x, next.y, who.Type.Width, who.Type.Height)
+			if cost > ret {
+				ret = cost
+			}
+		}
+	}
+	return ret
+}
+
+// attemptMove moves a character toward a goal anywhere in the terrain, using
+// up to walkDistance. It follows a route planned across the whole terrain,
+// and uses attemptShortMove to walk each leg of the route.
 func attemptMove(who *Character, terrain Terrain, goal Location, walkDistance float64) float64 {
 	movedTotal := float64(0)
 	moveRemaining := walkDistance
 	for moveRemaining > 0 {
-		movedNext := attemptShortMove(who, terrain, goal, moveRemaining)
+		waypoint := nextWaypoint(who, terrain, goal)
+		movedNext := attemptShortMove(who, terrain, waypoint, moveRemaining)
 		movedTotal = movedTotal + movedNext
 		moveRemaining = moveRemaining - movedNext
 		if movedNext == 0 {
@@ -390,6 +492,16 @@ func insideOfShadow(shadowSize int, who *Character, target *House) bool {
 	return false
 }
 
+// workLocation is the spot characters head for when they want to work on a
+// house.
+func workLocation(house *House) Location {
+	return Location{
+		X:      house.Location.X + (house.Type.Width / 2),
+		Y:      house.Location.Y + (house.Type.Height / 2),
+		Offset: 0.0,
+	}
+}
+
 func mine(who *Character, target *House, dt float64) {
 	transfer := who.Type.WorkPerTick * dt
 
@@ -430,18 +542,102 @@ func build(terrain Terrain, who *Character, target *House, dt float64) {
 		transfer = who.Carrying
 	}
 
+	before := target.ResourcesLeft
 	target.ResourcesLeft = target.ResourcesLeft + transfer
 	who.Carrying = who.Carrying - transfer
+	noteResourcesAdded(target, before)
 }
 
-func calculateName(x interface{}) string {
-	s := fmt.Sprintf("%p", x)
-	hashed := sha256.Sum224([]byte(s))
-	return base64.StdEncoding.EncodeToString(hashed[:16])
+// calculateName picks a new name for x. Games hand out names in sequence, so
+// a game played the same way twice names everything the same way both times.
+func calculateName(game *Game, x interface{}) string {
+	for {
+		s := fmt.Sprintf("%p", x)
+		if game != nil {
+			s = fmt.Sprintf("%d", game.nameCount)
+			game.nameCount++
+		}
+		hashed := sha256.Sum224([]byte(s))
+		name := base64.StdEncoding.EncodeToString(hashed[:16])
+		if game == nil || lookup(game, name) == nil {
+			return name
+		}
+	}
+}
+
+// register makes thing findable by name in game. It's safe to call with a nil
+// game, for cultures that were constructed outside of AddCulture.
+func register(game *Game, name string, thing interface{}) {
+	if game == nil {
+		return
+	}
+	if game.names == nil {
+		game.names = make(map[string]interface{})
+	}
+	game.names[name] = thing
+}
+
+func unregister(game *Game, name string, thing interface{}) {
+	if game == nil {
+		return
+	}
+	if game.names[name] == thing {
+		delete(game.names, name)
+	}
+}
+
+// lookup returns the Character, House, Culture or Location registered in game
+// under name, or nil if there is nothing by that name.
+func lookup(game *Game, name string) interface{} {
+	return game.names[name]
+}
+
+// SetName gives a Character, House or Culture a new name, or registers a
+// Location as a named waypoint. Renaming a waypoint frees its old name. Names
+// must be unique within a game; SetName fails if the name is already in use
+// by something else.
+func SetName(game *Game, thing interface{}, name string) error {
+	if name == "" {
+		return errors.New("can't use an empty name")
+	}
+	if existing := lookup(game, name); existing != nil {
+		if existing == thing {
+			return nil
+		}
+		return fmt.Errorf("name %q is already in use", name)
+	}
+
+	switch named := thing.(type) {
+	case *Character:
+		unregister(game, named.Name, named)
+		named.Name = name
+	case *House:
+		unregister(game, named.Name, named)
+		named.Name = name
+	case *Culture:
+		unregister(game, named.Name, named)
+		named.Name = name
+	case *Location:
+		// Locations don't carry their own names, so look for the old one
+		for old, other := range game.names {
+			if other == named {
+				delete(game.names, old)
+			}
+		}
+	default:
+		return fmt.Errorf("can't name things of type %T", thing)
+	}
+
+	register(game, name, thing)
+	return nil
 }
 
 func rerankHouse(terrain Terrain, house *House) {
 	if house.ResourcesLeft == 0 {
+		if _, built := house.Culture.BuiltHouses[house]; built {
+			unregister(house.Culture.game, house.Name, house)
+			emitHouseEvent(house, EventHouseDepleted)
+		}
 		delete(house.Culture.BuiltHouses, house)
 		for x := 0; x < house.Type.Width; x++ {
 			for y := 0; y < house.Type.Height; y++ {
@@ -456,6 +652,7 @@ func rerankHouse(terrain Terrain, house *House) {
 	if house.ResourcesLeft > 0 && planned {
 		delete(house.Culture.PlannedHouses, house)
 		house.Culture.BuiltHouses[house] = true
+		house.Culture.hasBuilt = true
 		for x := 0; x < house.Type.Width; x++ {
 			for y := 0; y < house.Type.Height; y++ {
 				newX := house.Location.X + x
@@ -466,31 +663,64 @@ func rerankHouse(terrain Terrain, house *House) {
 	}
 }
 
+// reevaluateTargetHouse decides whether who is done with the house it's
+// targeting, and what it should do next. Characters in cultures with a depot
+// take full loads there and fetch resources from there to build with, and
+// go back to their work afterwards.
 func reevaluateTargetHouse(who *Character) {
 	house := who.Target.(*House)
-	if _, ok := house.Culture.PlannedHouses[house]; !ok {
-		if _, ok := house.Culture.BuiltHouses[house]; !ok {
-			goto abandon // House has gone away
-		}
+	if usingDepot(who, house) {
+		reevaluateDepotTrip(who, house)
+		return
 	}
 
-	if house.Culture == who.Culture { // Building
-		if who.Carrying == 0 {
-			goto abandon // nothing left to build with
+	if !houseExists(house) {
+		// House has gone away
+		emitCharacterEvent(who, EventTargetAbandoned, house.Name)
+		goto deliver
+	}
+
+	if house.Culture == who.Culture { // Building or stocking
+		if resourcesNeeded(house) <= 0 {
+			goto deliver // our work is done
 		}
-		if house.ResourcesLeft >= house.Type.MaxResources {
-			goto abandon // our work is done
+		if who.Carrying == 0 {
+			// Nothing left to build with, so get more if we can
+			if depot := nearestDepot(who); depot != nil && who.Culture.Resources > 0 {
+				who.work = house
+				who.Target = depot
+				return
+			}
+			goto abandon
 		}
 	} else { // Mining
 		if who.Carrying >= who.Type.MaxCarry {
-			goto abandon // can't carry any more
+			// Can't carry any more, so drop it off and come back
+			if depot := nearestDepot(who); depot != nil {
+				who.work = house
+				who.Target = depot
+				return
+			}
+			goto abandon
 		}
 	}
 
 	return
 
+deliver:
+	if depot := nearestDepot(who); depot != nil && who.Carrying > 0 {
+		who.work = nil
+		who.Target = depot
+		return
+	}
+	who.Target = nil
+	who.work = nil
+	return
+
 abandon:
+	emitCharacterEvent(who, EventTargetAbandoned, house.Name)
 	who.Target = nil
+	who.work = nil
 	return
 }
 
@@ -498,7 +728,11 @@ abandon:
 // returned game has no cultures and no buildings.
 func NewGame(width, height int) *Game {
 	ret := Game{
-		Cultures: make([]*Culture, 0),
+		Cultures:       make([]*Culture, 0),
+		names:          make(map[string]interface{}),
+		houseTypes:     make(map[string]*HouseType),
+		characterTypes: make(map[string]*CharacterType),
+		phase:          PhaseLobby,
 		terrain: Terrain{
 			Board:  make([][]interface{}, width),
 			Width:  width,
@@ -506,8 +740,10 @@ func NewGame(width, height int) *Game {
 		},
 	}
 
+	ret.terrain.Tiles = make([][]TileKind, width)
 	for i := range ret.terrain.Board {
 		ret.terrain.Board[i] = make([]interface{}, ret.terrain.Height)
+		ret.terrain.Tiles[i] = make([]TileKind, ret.terrain.Height)
 	}
 
 	return &ret
@@ -521,7 +757,10 @@ func AddCulture(game *Game) *Culture {
 	ret := &Culture{
 		PlannedHouses: make(map[*House]bool),
 		BuiltHouses:   make(map[*House]bool),
+		game:          game,
 	}
+	ret.Name = calculateName(game, ret)
+	register(game, ret.Name, ret)
 	game.Cultures = append(game.Cultures, ret)
 	return ret
 }
@@ -550,9 +789,10 @@ func AddCharacter(terrain Terrain, culture *Culture,
 		Culture:  culture,
 		Location: loc,
 		Type:     ctype,
+		Health:   healthOf(ctype),
 	}
 
-	character.Name = calculateName(character)
+	character.Name = calculateName(culture.game, character)
 	for x := 0; x < character.Type.Width; x++ {
 		for y := 0; y < character.Type.Height; y++ {
 			placeX, placeY := int(loc.X)+x, int(loc.Y)+y
@@ -560,10 +800,50 @@ func AddCharacter(terrain Terrain, culture *Culture,
 		}
 	}
 
+	register(culture.game, character.Name, character)
 	culture.Characters = append(culture.Characters, character)
 	return character, nil
 }
 
+// AddHouseType makes a HouseType available to PlanOrders under the given
+// name. Each name can only be used once per game, and types with impossible
+// sizes or resources, or that train character types the game doesn't have,
+// are refused.
+func AddHouseType(game *Game, name string, houseType *HouseType) error {
+	if _, exists := game.houseTypes[name]; exists {
+		return fmt.Errorf("house type %q already exists", name)
+	}
+	if err := validateHouseType(name, houseType); err != nil {
+		return err
+	}
+	if _, known := game.characterTypes[houseType.Trains]; houseType.Trains != "" && !known {
+		return fmt.Errorf("house type %q trains unknown character type %q",
+			name, houseType.Trains)
+	}
+	if game.houseTypes == nil {
+		game.houseTypes = make(map[string]*HouseType)
+	}
+	game.houseTypes[name] = houseType
+	return nil
+}
+
+// AddCharacterType makes a CharacterType available by name. Each name can
+// only be used once per game, and types with impossible sizes or rates are
+// refused.
+func AddCharacterType(game *Game, name string, characterType *CharacterType) error {
+	if _, exists := game.characterTypes[name]; exists {
+		return fmt.Errorf("character type %q already exists", name)
+	}
+	if err := validateCharacterType(name, characterType); err != nil {
+		return err
+	}
+	if game.characterTypes == nil {
+		game.characterTypes = make(map[string]*CharacterType)
+	}
+	game.characterTypes[name] = characterType
+	return nil
+}
+
 const maxPlansAllowedPerCulture = 255
 
 // PlanHouse declares the intent by a given culture to build a house. Cultures
@@ -571,20 +851,29 @@ const maxPlansAllowedPerCulture = 255
 // will let them do it.
 func PlanHouse(culture *Culture, houseType *HouseType, loc Location) *House {
 	if len(culture.PlannedHouses) >= maxPlansAllowedPerCulture {
-		for k, _ := range culture.PlannedHouses {
-			delete(culture.PlannedHouses, k)
-			break
+		// Evict the oldest plan, rather than whatever map iteration
+		// happens to turn up, so that games stay deterministic.
+		var oldest *House
+		for k := range culture.PlannedHouses {
+			if oldest == nil || k.planSeq < oldest.planSeq {
+				oldest = k
+			}
 		}
+		emitHouseEvent(oldest, EventPlanEvicted)
+		UnplanHouse(oldest)
 	}
 
+	culture.planCount++
 	ret := &House{
 		Type:          houseType,
 		Culture:       culture,
 		Location:      loc,
 		ResourcesLeft: 0,
+		planSeq:       culture.planCount,
 	}
 
-	ret.Name = calculateName(ret)
+	ret.Name = calculateName(culture.game, ret)
+	register(culture.game, ret.Name, ret)
 	culture.PlannedHouses[ret] = true
 	return ret
 }
@@ -592,6 +881,9 @@ func PlanHouse(culture *Culture, houseType *HouseType, loc Location) *House {
 // UnplanHouse cancels the plan to build a house. Once a house has been
 // started, there is no need to unplan it.
 func UnplanHouse(house *House) {
+	if _, planned := house.Culture.PlannedHouses[house]; planned {
+		unregister(house.Culture.game, house.Name, house)
+	}
 	delete(house.Culture.PlannedHouses, house)
 }
 
@@ -606,70 +898,324 @@ type TargetOrder struct {
 	Target    string `json:"target"`
 }
 
-func (*TargetOrder) Apply(game *Game) error {
-	return errors.New("TargetOrder.Apply is unimplemented")
+// OrderError describes why an Order was rejected. Reason is one of the
+// Reject* constants, and Name is the offending name from the order.
+type OrderError struct {
+	Reason string
+	Name   string
+}
+
+// Reasons an Order might be rejected
+const (
+	RejectUnknownCharacter = "unknown character"
+	RejectUnknownTarget    = "unknown target"
+	RejectWrongTargetKind  = "target is not a house or location"
+	RejectUnknownCulture   = "unknown culture"
+	RejectUnknownHouseType = "unknown house type"
+	RejectOutOfBounds      = "location is out of bounds"
+	RejectWrongCulture     = "belongs to another culture"
+	RejectNoIssuer         = "order can't be given by a culture"
+	RejectNotPlanned       = "not a planned house"
+	RejectNotCharacter     = "target is not a character"
+	RejectOwnCulture       = "target belongs to the same culture"
+	RejectCantAttack       = "character can't attack"
+	RejectNotFinished      = "house is not finished"
+	RejectCantTrain        = "house can't train characters"
+	RejectQueueFull        = "training queue is full"
+	RejectGameOver         = "game is over"
+	RejectBadStart         = "start doesn't fit"
+)
+
+func (e *OrderError) Error() string {
+	return fmt.Sprintf("%s: %q", e.Reason, e.Name)
+}
+
+// findCharacter returns the living character in game with the given name.
+func findCharacter(game *Game, name string) (*Character, error) {
+	who, ok := lookup(game, name).(*Character)
+	if !ok {
+		return nil, &OrderError{RejectUnknownCharacter, name}
+	}
+	return who, nil
+}
+
+// Apply points the named character at the named House or Location.
+func (o *TargetOrder) Apply(game *Game) error {
+	who, err := findCharacter(game, o.Character)
+	if err != nil {
+		return err
+	}
+
+	switch target := lookup(game, o.Target).(type) {
+	case *House:
+		who.Target = target
+		who.work = nil
+	case *Location:
+		loc := *target
+		who.Target = &loc
+		who.work = nil
+	case nil:
+		return &OrderError{RejectUnknownTarget, o.Target}
+	default:
+		return &OrderError{RejectWrongTargetKind, o.Target}
+	}
+
+	return nil
 }
 
 // MarchOrder instructs the named character to find a path to location X, Y
 type MarchOrder struct {
-	Character string
-	X, Y      int
+	Character string `json:"character"`
+	X         int    `json:"x"`
+	Y         int    `json:"y"`
 }
 
 // PlanOrder instructs the named culture to build a house of the named
 // housetype at the given location.
 type PlanOrder struct {
-	Culture   string
-	HouseType string
-	X, Y      int
+	Culture   string `json:"culture"`
+	HouseType string `json:"houseType"`
+	X         int    `json:"x"`
+	Y         int    `json:"y"`
+}
+
+func inBounds(terrain Terrain, x, y, width, height int) bool {
+	return x >= 0 && y >= 0 &&
+		x+width <= terrain.Width && y+height <= terrain.Height
+}
+
+// Apply sends the named character toward X, Y. The character's whole
+// footprint must fit inside of the terrain at X, Y.
+func (o *MarchOrder) Apply(game *Game) error {
+	who, err := findCharacter(game, o.Character)
+	if err != nil {
+		return err
+	}
+
+	if !inBounds(game.terrain, o.X, o.Y, who.Type.Width, who.Type.Height) {
+		return &OrderError{RejectOutOfBounds, fmt.Sprintf("%d,%d", o.X, o.Y)}
+	}
+
+	who.Target = &Location{X: o.X, Y: o.Y, Offset: 0.0}
+	who.work = nil
+	return nil
 }
 
-type GameStatus struct{}
+// Apply plans a new house for the named culture, using a HouseType from the
+// game's catalog.
+func (o *PlanOrder) Apply(game *Game) error {
+	culture, ok := lookup(game, o.Culture).(*Culture)
+	if !ok {
+		return &OrderError{RejectUnknownCulture, o.Culture}
+	}
+
+	houseType, ok := game.houseTypes[o.HouseType]
+	if !ok {
+		return &OrderError{RejectUnknownHouseType, o.HouseType}
+	}
+
+	if !inBounds(game.terrain, o.X, o.Y, houseType.Width, houseType.Height) {
+		return &OrderError{RejectOutOfBounds, fmt.Sprintf("%d,%d", o.X, o.Y)}
+	}
 
-func ApplyOrders(game *Game, orders []Order) {
-	// TODO here is where players make changes
+	PlanHouse(culture, houseType, Location{X: o.X, Y: o.Y, Offset: 0.0})
+	return nil
 }
 
-func ReadStatus(game *Game) GameStatus {
-	// TODO here is where players see the game state
-	return GameStatus{}
+// CultureOrder adds a new culture to the game, starting with everything in
+// Start if it isn't nil. Adding cultures with orders, rather than calling
+// AddCulture directly, records them in the journal so that they happen again
+// when the journal is replayed. Apply fills in the name of the new culture.
+type CultureOrder struct {
+	Name  string    `json:"name,omitempty"`
+	Start *MapStart `json:"start,omitempty"`
+}
+
+// Apply adds the culture. CultureOrders are only rejected if their start
+// doesn't fit in the game.
+func (o *CultureOrder) Apply(game *Game) error {
+	if o.Start == nil {
+		o.Name = AddCulture(game).Name
+		return nil
+	}
+	culture, err := addStart(game, *o.Start)
+	if err != nil {
+		return &OrderError{RejectBadStart, err.Error()}
+	}
+	o.Name = culture.Name
+	return nil
+}
+
+// IssuedOrder is an Order that acts on behalf of a single culture.
+type IssuedOrder interface {
+	Order
+	Issuer(*Game) (*Culture, error)
+}
+
+// Issuer is the culture that owns the ordered character.
+func (o *TargetOrder) Issuer(game *Game) (*Culture, error) {
+	who, err := findCharacter(game, o.Character)
+	if err != nil {
+		return nil, err
+	}
+	return who.Culture, nil
+}
+
+// Issuer is the culture that owns the ordered character.
+func (o *MarchOrder) Issuer(game *Game) (*Culture, error) {
+	who, err := findCharacter(game, o.Character)
+	if err != nil {
+		return nil, err
+	}
+	return who.Culture, nil
+}
+
+// Issuer is the culture that will own the planned house.
+func (o *PlanOrder) Issuer(game *Game) (*Culture, error) {
+	culture, ok := lookup(game, o.Culture).(*Culture)
+	if !ok {
+		return nil, &OrderError{RejectUnknownCulture, o.Culture}
+	}
+	return culture, nil
+}
+
+// PlayerOrder is an Order given by the player controlling the named culture.
+// It's rejected unless the wrapped order acts on behalf of that culture.
+type PlayerOrder struct {
+	Culture string
+	Order   Order
+}
+
+// Apply applies the wrapped order if it belongs to the player's culture.
+func (o *PlayerOrder) Apply(game *Game) error {
+	issued, ok := o.Order.(IssuedOrder)
+	if !ok {
+		return &OrderError{RejectNoIssuer, o.Culture}
+	}
+
+	issuer, err := issued.Issuer(game)
+	if err != nil {
+		return err
+	}
+	if issuer.Name != o.Culture {
+		return &OrderError{RejectWrongCulture, issuer.Name}
+	}
+
+	return o.Order.Apply(game)
+}
+
+// OrderResult records the outcome of applying an Order to a game. Err is nil
+// if the order was accepted, otherwise it explains why the order was rejected.
+type OrderResult struct {
+	Tick  int
+	Order Order
+	Err   error
+}
+
+// ApplyOrders applies a batch of orders between two Ticks, in the order given,
+// and returns the outcome of each. Rejected orders leave the game unchanged,
+// and every order is rejected once the game is finished. Every order is
+// recorded, along with its outcome, in the game's journal.
+func ApplyOrders(game *Game, orders []Order) []OrderResult {
+	results := make([]OrderResult, len(orders))
+	for i, order := range orders {
+		results[i] = OrderResult{
+			Tick:  game.tick,
+			Order: order,
+		}
+		if game.phase == PhaseFinished {
+			results[i].Err = &OrderError{RejectGameOver, ""}
+		} else {
+			results[i].Err = order.Apply(game)
+		}
+	}
+
+	game.journal = append(game.journal, results...)
+	return results
+}
+
+// CurrentTick returns the number of Ticks that have been applied to game.
+func CurrentTick(game *Game) int {
+	return game.tick
+}
+
+// GameTerrain returns the terrain game is played on.
+func GameTerrain(game *Game) Terrain {
+	return game.terrain
+}
+
+// Journal returns every order that has been applied to game, in the order
+// they were applied.
+func Journal(game *Game) []OrderResult {
+	ret := make([]OrderResult, len(game.journal))
+	copy(ret, game.journal)
+	return ret
 }
 
 // Tick advances the game state by dt units of time. No commands can arrive
-// during a Tick.
+// during a Tick. Ticks never change a game's phase, except to finish it, and
+// Ticks after the game is finished do nothing. It's up to whoever is ticking
+// the game, like a GameLoop, to wait for it to start.
 func Tick(game *Game, dt float64) {
-	// TODO shouldn't iterate by culture, or one team gets to move
-	// before the other
+	if game.phase == PhaseFinished {
+		return
+	}
+	game.events = nil
+
+	if game.scheduling == FairScheduling {
+		fairTick(game, dt)
+		trainCharacters(game, dt)
+		resolveCombat(game, dt)
+		AssignJobs(game)
+		game.tick++
+		rememberHouses(game)
+		checkWinConditions(game)
+		return
+	}
+
 	// TODO shouldn't just accept any random dt or the progress of the game will depend on
 	for _, culture := range game.Cultures {
 		for _, who := range culture.Characters {
+			start, moving := who.Location, wantsToMove(who)
 			switch target := who.Target.(type) {
 			case *Location:
 				distance := who.Type.MovePerTick * dt
 				attemptMove(who, game.terrain, *target, distance)
 			case *House:
 				if insideOfShadow(defaultShadowSize, who, target) {
-					if who.Culture == target.Culture {
+					if usingDepot(who, target) {
+						exchange(who, target, dt)
+					} else if who.Culture == target.Culture && isFinished(target) {
+						stockHouse(who, target, dt)
+					} else if who.Culture == target.Culture {
 						build(game.terrain, who, target, dt)
 					} else {
 						mine(who, target, dt)
 					}
 					rerankHouse(game.terrain, target)
 				} else {
-					workTarget := Location{
-						X:      target.Location.X + (target.Type.Width / 2),
-						Y:      target.Location.Y + (target.Type.Height / 2),
-						Offset: 0.0,
-					}
 					distance := who.Type.MovePerTick * dt
-					attemptMove(who, game.terrain, workTarget, distance)
+					attemptMove(who, game.terrain, workLocation(target), distance)
 				}
 				reevaluateTargetHouse(who)
+			case *Character:
+				if !inRange(who, target) {
+					distance := who.Type.MovePerTick * dt
+					attemptMove(who, game.terrain, target.Location, distance)
+				}
 			case nil:
 				// Nothing to do
 			default:
 				log.Panicf("unexpected character target type %T\n", target)
 			}
+			noteBlocked(who, moving && who.Location == start)
 		}
 	}
+
+	trainCharacters(game, dt)
+	resolveCombat(game, dt)
+	AssignJobs(game)
+	game.tick++
+	rememberHouses(game)
+	checkWinConditions(game)
 }
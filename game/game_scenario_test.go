@@ -33,6 +33,13 @@ func TestBuildAndMine(t *testing.T) {
 		t.Fatalf("can't place green for BuildAndMine scenario: %v", err)
 	}
 
+	if err := SetName(game, red, "red"); err != nil {
+		t.Fatalf("can't name red: %v", err)
+	}
+	if err := SetName(game, redHouse, "redHouse"); err != nil {
+		t.Fatalf("can't name redHouse: %v", err)
+	}
+
 	for redHouse.ResourcesLeft < redHouse.Type.MaxResources {
 		if red.Carrying == 0 {
 			red.Carrying = red.Type.MaxCarry
@@ -429,6 +429,36 @@ func TestAttemptMoveNonzeroOffset(t *testing.T) {
 	// t.Errorf("Need to write this test")
 }
 
+func TestAttemptShortMoveForest(t *testing.T) {
+	game := NewGame(8, 4)
+	culture := AddCulture(game)
+	fillTiles(game, Forest, 1, 0, 7, 4)
+
+	who, _ := AddCharacter(game.terrain, culture, walkerType, loc0x0)
+	goal := Location{6, 0, 0.0}
+	moved := attemptShortMove(who, game.terrain, goal, 1.5)
+	if who.Location != (Location{0, 0, 0.75}) || moved != 1.5 {
+		t.Errorf("Expected to be most of a step into the forest, got %v after %v",
+			who.Location, moved)
+	}
+
+	moved = attemptShortMove(who, game.terrain, goal, 1.5)
+	if who.Location != (Location{1, 0, 0.5}) || moved != 1.5 {
+		t.Errorf("Expected to be half way to the next tree, got %v after %v",
+			who.Location, moved)
+	}
+
+	for i := 0; i < 8; i++ {
+		attemptShortMove(who, game.terrain, goal, 1.5)
+		if who.Location.Offset < 0 || who.Location.Offset >= 1 {
+			t.Fatalf("Offset should be part of one step, got %v", who.Location)
+		}
+	}
+	if who.Location != goal {
+		t.Errorf("Expected to reach %v, got %v", goal, who.Location)
+	}
+}
+
 func TestAttemptMoveLong(t *testing.T) {
 	game := NewGame(128, 128)
 	AddCulture(game)
@@ -808,3 +838,379 @@ func TestTargetOrder(t *testing.T) {
 		t.Errorf("Targeting character failed")
 	}
 }
+
+func TestTargetOrderLocation(t *testing.T) {
+	game := NewGame(16, 16)
+	AddCulture(game)
+
+	character, _ := AddCharacter(
+		game.terrain,
+		game.Cultures[0],
+		workerType,
+		loc0x0,
+	)
+	waypoint := &Location{6, 8, 0.0}
+	if err := SetName(game, waypoint, "waypoint"); err != nil {
+		t.Fatalf("Could not name waypoint: %v", err)
+	}
+
+	order := &TargetOrder{Character: character.Name, Target: "waypoint"}
+	if err := order.Apply(game); err != nil {
+		t.Fatalf("Could not order character to location: %v", err)
+	}
+
+	target, ok := character.Target.(*Location)
+	if !ok || *target != *waypoint {
+		t.Errorf("Expected target %v, got %v", waypoint, character.Target)
+	}
+	if target == waypoint {
+		t.Errorf("Character shares a waypoint with the registry")
+	}
+}
+
+func TestSetNameRenamesWaypoint(t *testing.T) {
+	game := NewGame(16, 16)
+	waypoint := &Location{6, 8, 0.0}
+	SetName(game, waypoint, "first")
+	if err := SetName(game, waypoint, "second"); err != nil {
+		t.Fatalf("Could not rename waypoint: %v", err)
+	}
+	if lookup(game, "first") != nil {
+		t.Errorf("Renamed waypoint is still registered under its old name")
+	}
+	if lookup(game, "second") != waypoint {
+		t.Errorf("Renamed waypoint can't be found by its new name")
+	}
+}
+
+func TestTargetOrderRejected(t *testing.T) {
+	game := NewGame(16, 16)
+	AddCulture(game)
+
+	character, _ := AddCharacter(
+		game.terrain,
+		game.Cultures[0],
+		workerType,
+		loc0x0,
+	)
+	house := PlanHouse(
+		game.Cultures[0],
+		houseType,
+		Location{4, 4, 0.0},
+	)
+
+	rejections := []struct {
+		order  TargetOrder
+		reason string
+	}{
+		{TargetOrder{"nobody", house.Name}, RejectUnknownCharacter},
+		{TargetOrder{house.Name, house.Name}, RejectUnknownCharacter},
+		{TargetOrder{character.Name, "nowhere"}, RejectUnknownTarget},
+		{TargetOrder{character.Name, character.Name}, RejectWrongTargetKind},
+		{TargetOrder{character.Name, game.Cultures[0].Name}, RejectWrongTargetKind},
+	}
+
+	for _, rejection := range rejections {
+		err := rejection.order.Apply(game)
+		orderErr, ok := err.(*OrderError)
+		if !ok || orderErr.Reason != rejection.reason {
+			t.Errorf("Expected %v to fail with %q, got %v",
+				rejection.order, rejection.reason, err)
+		}
+	}
+
+	if character.Target != nil {
+		t.Errorf("Rejected orders changed character target")
+	}
+}
+
+func TestTargetOrderUnplannedHouse(t *testing.T) {
+	game := NewGame(16, 16)
+	AddCulture(game)
+
+	character, _ := AddCharacter(
+		game.terrain,
+		game.Cultures[0],
+		workerType,
+		loc0x0,
+	)
+	house := PlanHouse(
+		game.Cultures[0],
+		houseType,
+		Location{4, 4, 0.0},
+	)
+	UnplanHouse(house)
+
+	order := &TargetOrder{Character: character.Name, Target: house.Name}
+	if err := order.Apply(game); err == nil {
+		t.Errorf("Targeted a house that was unplanned")
+	}
+}
+
+func TestSetNameCollision(t *testing.T) {
+	game := NewGame(16, 16)
+	AddCulture(game)
+
+	c1, _ := AddCharacter(game.terrain, game.Cultures[0], workerType, loc0x0)
+	c2, _ := AddCharacter(
+		game.terrain,
+		game.Cultures[0],
+		workerType,
+		Location{4, 4, 0.0},
+	)
+
+	if err := SetName(game, c1, "bob"); err != nil {
+		t.Fatalf("Couldn't name character: %v", err)
+	}
+	if err := SetName(game, c2, "bob"); err == nil {
+		t.Errorf("Two characters were allowed the same name")
+	}
+	if c2.Name == "bob" {
+		t.Errorf("Failed rename still changed the character name")
+	}
+	if _, err := findCharacter(game, c1.Name); err != nil {
+		t.Errorf("Renamed character can't be found: %v", err)
+	}
+}
+
+func TestMarchOrder(t *testing.T) {
+	game := NewGame(16, 16)
+	AddCulture(game)
+
+	character, _ := AddCharacter(
+		game.terrain,
+		game.Cultures[0],
+		workerType,
+		loc0x0,
+	)
+
+	order := &MarchOrder{Character: character.Name, X: 6, Y: 8}
+	if err := order.Apply(game); err != nil {
+		t.Fatalf("Could not march character: %v", err)
+	}
+
+	if target, ok := character.Target.(*Location); !ok || *target != loc6x8 {
+		t.Errorf("Expected march to %v, got %v", loc6x8, character.Target)
+	}
+}
+
+func TestMarchOrderOutOfBounds(t *testing.T) {
+	outofbounds := []Location{
+		Location{-1, 0, 0.0},
+		Location{0, -1, 0.0},
+		Location{15, 0, 0.0},
+		Location{0, 15, 0.0},
+		Location{16, 16, 0.0},
+	}
+
+	for _, loc := range outofbounds {
+		game := NewGame(16, 16)
+		AddCulture(game)
+
+		character, _ := AddCharacter(
+			game.terrain,
+			game.Cultures[0],
+			workerType,
+			loc0x0,
+		)
+
+		order := &MarchOrder{Character: character.Name, X: loc.X, Y: loc.Y}
+		err := order.Apply(game)
+		if orderErr, ok := err.(*OrderError); !ok || orderErr.Reason != RejectOutOfBounds {
+			t.Errorf("Expected march to %v to be out of bounds, got %v", loc, err)
+		}
+		if character.Target != nil {
+			t.Errorf("Out of bounds march to %v set a target", loc)
+		}
+	}
+}
+
+func TestPlanOrder(t *testing.T) {
+	game := NewGame(16, 16)
+	culture := AddCulture(game)
+	if err := AddHouseType(game, "house", houseType); err != nil {
+		t.Fatalf("Could not add house type: %v", err)
+	}
+
+	order := &PlanOrder{
+		Culture:   culture.Name,
+		HouseType: "house",
+		X:         4,
+		Y:         4,
+	}
+	if err := order.Apply(game); err != nil {
+		t.Fatalf("Could not plan house: %v", err)
+	}
+
+	if len(culture.PlannedHouses) != 1 {
+		t.Fatalf("Expected one planned house, got %d", len(culture.PlannedHouses))
+	}
+	for house := range culture.PlannedHouses {
+		if house.Type != houseType || house.Location != (Location{4, 4, 0.0}) {
+			t.Errorf("Planned unexpected house %v", house)
+		}
+	}
+}
+
+func TestPlanOrderRejected(t *testing.T) {
+	game := NewGame(16, 16)
+	culture := AddCulture(game)
+	AddHouseType(game, "house", houseType)
+
+	rejections := []struct {
+		order  PlanOrder
+		reason string
+	}{
+		{PlanOrder{"nobody", "house", 4, 4}, RejectUnknownCulture},
+		{PlanOrder{culture.Name, "castle", 4, 4}, RejectUnknownHouseType},
+		{PlanOrder{culture.Name, "house", 16, 4}, RejectOutOfBounds},
+		{PlanOrder{culture.Name, "house", 4, -1}, RejectOutOfBounds},
+	}
+
+	for _, rejection := range rejections {
+		err := rejection.order.Apply(game)
+		orderErr, ok := err.(*OrderError)
+		if !ok || orderErr.Reason != rejection.reason {
+			t.Errorf("Expected %v to fail with %q, got %v",
+				rejection.order, rejection.reason, err)
+		}
+	}
+
+	if len(culture.PlannedHouses) != 0 {
+		t.Errorf("Rejected plans were added to culture")
+	}
+}
+
+func TestAddHouseTypeTwice(t *testing.T) {
+	game := NewGame(16, 16)
+	if err := AddHouseType(game, "house", houseType); err != nil {
+		t.Fatalf("Could not add house type: %v", err)
+	}
+	if err := AddHouseType(game, "house", houseType); err == nil {
+		t.Errorf("Added the same house type name twice")
+	}
+}
+
+func TestApplyOrders(t *testing.T) {
+	game := NewGame(16, 16)
+	AddCulture(game)
+
+	character, _ := AddCharacter(
+		game.terrain,
+		game.Cultures[0],
+		workerType,
+		loc0x0,
+	)
+
+	orders := []Order{
+		&MarchOrder{Character: character.Name, X: 6, Y: 8},
+		&MarchOrder{Character: "nobody", X: 6, Y: 8},
+	}
+	results := ApplyOrders(game, orders)
+
+	if len(results) != len(orders) {
+		t.Fatalf("Expected %d results, got %d", len(orders), len(results))
+	}
+	if results[0].Err != nil {
+		t.Errorf("Good order was rejected: %v", results[0].Err)
+	}
+	if results[1].Err == nil {
+		t.Errorf("Bad order was accepted")
+	}
+	if target, ok := character.Target.(*Location); !ok || *target != loc6x8 {
+		t.Errorf("Expected march to %v, got %v", loc6x8, character.Target)
+	}
+}
+
+func TestJournal(t *testing.T) {
+	game := NewGame(16, 16)
+	AddCulture(game)
+
+	character, _ := AddCharacter(
+		game.terrain,
+		game.Cultures[0],
+		workerType,
+		loc0x0,
+	)
+
+	first := &MarchOrder{Character: character.Name, X: 6, Y: 8}
+	second := &MarchOrder{Character: "nobody", X: 0, Y: 0}
+
+	ApplyOrders(game, []Order{first})
+	Tick(game, 1)
+	Tick(game, 1)
+	ApplyOrders(game, []Order{second})
+
+	journal := Journal(game)
+	if len(journal) != 2 {
+		t.Fatalf("Expected two journal entries, got %d", len(journal))
+	}
+	if journal[0].Order != first || journal[0].Tick != 0 || journal[0].Err != nil {
+		t.Errorf("Unexpected first journal entry %v", journal[0])
+	}
+	if journal[1].Order != second || journal[1].Tick != 2 || journal[1].Err == nil {
+		t.Errorf("Unexpected second journal entry %v", journal[1])
+	}
+}
+
+func TestTooManyPlannedHousesEvictsOldest(t *testing.T) {
+	game := NewGame(1, 1)
+	AddCulture(game)
+
+	first := PlanHouse(game.Cultures[0], houseType, loc0x0)
+	second := PlanHouse(game.Cultures[0], houseType, loc0x0)
+	for i := 2; i < maxPlansAllowedPerCulture; i++ {
+		PlanHouse(game.Cultures[0], houseType, loc0x0)
+	}
+	PlanHouse(game.Cultures[0], houseType, loc0x0)
+
+	if _, ok := game.Cultures[0].PlannedHouses[first]; ok {
+		t.Errorf("Oldest plan wasn't evicted")
+	}
+	if _, ok := game.Cultures[0].PlannedHouses[second]; !ok {
+		t.Errorf("Newer plan was evicted")
+	}
+}
+
+func TestPlayerOrder(t *testing.T) {
+	game := NewGame(16, 16)
+	AddCulture(game)
+	AddCulture(game)
+	AddHouseType(game, "house", houseType)
+
+	mine, _ := AddCharacter(game.terrain, game.Cultures[0], workerType, loc0x0)
+	theirs, _ := AddCharacter(
+		game.terrain,
+		game.Cultures[1],
+		workerType,
+		Location{4, 4, 0.0},
+	)
+	player := game.Cultures[0].Name
+	enemy := game.Cultures[1].Name
+
+	accepted := []Order{
+		&MarchOrder{Character: mine.Name, X: 6, Y: 8},
+		&PlanOrder{Culture: player, HouseType: "house", X: 8, Y: 8},
+	}
+	for _, order := range accepted {
+		if err := (&PlayerOrder{player, order}).Apply(game); err != nil {
+			t.Errorf("Player couldn't give order %v: %v", order, err)
+		}
+	}
+
+	rejected := []Order{
+		&MarchOrder{Character: theirs.Name, X: 6, Y: 8},
+		&TargetOrder{Character: theirs.Name, Target: mine.Name},
+		&PlanOrder{Culture: enemy, HouseType: "house", X: 8, Y: 8},
+	}
+	for _, order := range rejected {
+		err := (&PlayerOrder{player, order}).Apply(game)
+		if orderErr, ok := err.(*OrderError); !ok || orderErr.Reason != RejectWrongCulture {
+			t.Errorf("Expected %v to be rejected, got %v", order, err)
+		}
+	}
+
+	if theirs.Target != nil || len(game.Cultures[1].PlannedHouses) != 0 {
+		t.Errorf("Player changed another culture")
+	}
+}
@@ -1,36 +1,268 @@
 package game
 
 import (
+	"errors"
+	"log"
 	"sync"
 	"time"
 )
 
+// DefaultTicksPerSecond is a reasonable rate for running a game in real time.
+const DefaultTicksPerSecond = 20
+
+// DefaultMaxCatchUpTicks is how many ticks a GameLoop will run back to back to
+// make up for a stall before giving up on the lost time.
+const DefaultMaxCatchUpTicks = 5
+
+// TickDuration is the amount of game time that passes in every call to Tick
+// made by a GameLoop. Since it never changes, a game run in a GameLoop will
+// progress the same way no matter how fast the loop itself runs.
+const TickDuration = 1.0
+
+// ErrStopped is returned by GameLoop methods that can't run once the loop is
+// stopped.
+var ErrStopped = errors.New("game loop is stopped")
+
+// viewerExpiry is how many times a loop can publish statuses for a culture
+// that nobody reads before it stops building them.
+const viewerExpiry = 100
+
+// subscriberBacklog is how many batches of events a subscriber can fall
+// behind before it starts missing them.
+const subscriberBacklog = 64
+
+// LoopConfig controls how quickly a GameLoop advances its game. Games in the
+// lobby don't advance at all until the loop starts them, once they have at
+// least MinPlayers cultures or when Start is called.
+type LoopConfig struct {
+	TicksPerSecond  int
+	MaxCatchUpTicks int
+	MinPlayers      int
+}
+
+// DefaultLoopConfig runs games at DefaultTicksPerSecond.
+var DefaultLoopConfig = LoopConfig{
+	TicksPerSecond:  DefaultTicksPerSecond,
+	MaxCatchUpTicks: DefaultMaxCatchUpTicks,
+}
+
+// WithDefaults returns config with DefaultLoopConfig's settings in place of
+// any that a loop can't run with: tick rates that aren't positive, and
+// catch-up limits under one tick.
+func (config LoopConfig) WithDefaults() LoopConfig {
+	if config.TicksPerSecond <= 0 {
+		config.TicksPerSecond = DefaultLoopConfig.TicksPerSecond
+	}
+	if config.MaxCatchUpTicks < 1 {
+		config.MaxCatchUpTicks = DefaultLoopConfig.MaxCatchUpTicks
+	}
+	return config
+}
+
 // GameLoop manages an ongoing game as a concurrent process. You can ask the
 // GameLoop for a snapshot of the game state, or send the GameLoop orders that
 // it will relay into the game it contains.
 type GameLoop struct {
 	status     GameStatus
-	orders     chan<- []Order
+	statuses   map[string]GameStatus // as seen by each culture in viewers
+	viewers    map[string]bool       // only used by the loop's goroutine
+	lastRead   map[string]int        // publish each viewer was last read at
+	publishes  int
+	orders     chan<- orderBatch
+	calls      chan<- loopCall
+	done       chan struct{}
 	stopped    bool
 	statusLock sync.RWMutex
 	stopLock   sync.RWMutex
+
+	subscribers   map[chan []Event]bool
+	subscribeLock sync.Mutex
 }
 
 // ReadLatestStatus returns a (possibly out of date) snapshot of the game status.
+// Every reader gets its own copy of the game's result.
 func (l *GameLoop) ReadLatestStatus() GameStatus {
 	l.statusLock.RLock()
 	defer l.statusLock.RUnlock()
-	return l.status
+	ret := l.status
+	ret.Result = copyResult(ret.Result)
+	return ret
+}
+
+// ReadLatestStatusFor returns a (possibly out of date) snapshot of the game
+// status, as seen by the named culture. It returns false if there is no such
+// culture. Statuses are only kept up to date for cultures that have been
+// read in the last viewerExpiry publishes, so the first read for a culture,
+// or the first in a long while, waits for the loop to build one.
+func (l *GameLoop) ReadLatestStatusFor(culture string) (GameStatus, bool) {
+	status, ok := l.readStatusFor(culture)
+	if !ok && l.Call(func(*Game) { l.watch(culture) }) {
+		status, ok = l.readStatusFor(culture)
+	}
+	return status, ok
+}
+
+func (l *GameLoop) readStatusFor(culture string) (GameStatus, bool) {
+	l.statusLock.Lock()
+	defer l.statusLock.Unlock()
+	status, ok := l.statuses[culture]
+	if ok {
+		l.lastRead[culture] = l.publishes
+	}
+	status.Result = copyResult(status.Result)
+	return status, ok
+}
+
+// watch starts building statuses for culture. It must only be called from
+// the loop's goroutine.
+func (l *GameLoop) watch(culture string) {
+	l.statusLock.Lock()
+	defer l.statusLock.Unlock()
+	l.viewers[culture] = true
+	l.lastRead[culture] = l.publishes
+}
+
+// CurrentTick returns the number of the most recently completed tick.
+func (l *GameLoop) CurrentTick() int {
+	return l.ReadLatestStatus().Tick
+}
+
+// orderBatch is a set of orders on their way into the game, along with a
+// channel to report their outcomes back to the writer.
+type orderBatch struct {
+	orders  []Order
+	results chan<- []OrderResult
+}
+
+// WriteOrders relays orders into the game, and waits until they've been applied
+// to report the outcome of each one. If the loop is stopped, the orders are
+// dropped and WriteOrders returns nil.
+func (l *GameLoop) WriteOrders(orders []Order) []OrderResult {
+	results := make(chan []OrderResult, 1)
+	select {
+	case l.orders <- orderBatch{orders, results}:
+		return <-results
+	case <-l.done:
+		return nil
+	}
+}
+
+// loopCall is a function to run against the game between ticks. finished is
+// closed once the function has run and its effects are visible in the
+// latest status.
+type loopCall struct {
+	f        func(*Game)
+	finished chan<- struct{}
+}
+
+// Call runs f against the loop's game between ticks, and waits for it to
+// finish. f must not hold on to the game after it returns. Call returns false
+// without running f if the loop is stopped.
+func (l *GameLoop) Call(f func(*Game)) bool {
+	finished := make(chan struct{})
+	select {
+	case l.calls <- loopCall{f, finished}:
+		<-finished
+		return true
+	case <-l.done:
+		return false
+	}
 }
 
-func (l *GameLoop) WriteOrders(orders []Order) {
-	l.orders <- orders
+// AddCulture adds a new culture to the loop's game, and returns its name. It
+// returns false if the loop is stopped or the game is finished.
+func (l *GameLoop) AddCulture() (string, bool) {
+	name, err := l.AddCultureAt(nil)
+	return name, err == nil
+}
+
+// AddCultureAt adds a new culture to the loop's game like AddCulture,
+// starting with everything in start if it isn't nil. It returns ErrStopped if
+// the loop is stopped, and the CultureOrder's error if the game is finished or
+// start doesn't fit.
+func (l *GameLoop) AddCultureAt(start *MapStart) (string, error) {
+	order := &CultureOrder{Start: start}
+	var err error
+	if !l.Call(func(g *Game) {
+		err = ApplyOrders(g, []Order{order})[0].Err
+	}) {
+		return "", ErrStopped
+	}
+	return order.Name, err
+}
+
+// Start starts the loop's game if it's still in the lobby, however many
+// players it has. It returns false if the game had already started or the
+// loop is stopped.
+func (l *GameLoop) Start() bool {
+	started := false
+	ok := l.Call(func(g *Game) {
+		started = StartGame(g)
+	})
+	return ok && started
+}
+
+// Subscribe returns a channel that receives the events that happen in the
+// loop's game from now on, in batches, in the order they happened. Batches
+// are shared between subscribers and must not be changed. Subscribers that
+// fall more than subscriberBacklog batches behind miss events, rather than
+// holding up the game. The channel is closed when the loop stops, or when
+// the returned function is called to cancel the subscription.
+func (l *GameLoop) Subscribe() (<-chan []Event, func()) {
+	l.subscribeLock.Lock()
+	defer l.subscribeLock.Unlock()
+
+	events := make(chan []Event, subscriberBacklog)
+	if l.IsStopped() {
+		close(events)
+		return events, func() {}
+	}
+	if l.subscribers == nil {
+		l.subscribers = make(map[chan []Event]bool)
+	}
+	l.subscribers[events] = true
+
+	cancel := func() {
+		l.subscribeLock.Lock()
+		defer l.subscribeLock.Unlock()
+		if l.subscribers[events] {
+			delete(l.subscribers, events)
+			close(events)
+		}
+	}
+	return events, cancel
+}
+
+// announce sends a batch of events to every subscriber.
+func (l *GameLoop) announce(events []Event) {
+	if len(events) == 0 {
+		return
+	}
+	l.subscribeLock.Lock()
+	defer l.subscribeLock.Unlock()
+	for subscriber := range l.subscribers {
+		select {
+		case subscriber <- events:
+		default:
+			log.Printf("subscriber is behind, dropped %d events", len(events))
+		}
+	}
 }
 
 func (l *GameLoop) Stop() {
 	l.stopLock.Lock()
-	defer l.stopLock.Unlock()
-	l.stopped = true
+	if !l.stopped {
+		l.stopped = true
+		close(l.done)
+	}
+	l.stopLock.Unlock()
+
+	l.subscribeLock.Lock()
+	defer l.subscribeLock.Unlock()
+	for subscriber := range l.subscribers {
+		close(subscriber)
+	}
+	l.subscribers = nil
 }
 
 func (l *GameLoop) IsStopped() bool {
@@ -39,35 +271,119 @@ func (l *GameLoop) IsStopped() bool {
 	return l.stopped
 }
 
-func RunGameLoop() *GameLoop {
-	g := &Game{}
-	orders := make(chan []Order)
-	shared := &GameLoop{}
-	shared.status = ReadStatus(g)
+// ticksDue returns how many ticks should run to account for elapsed time, and
+// how much time is left over for later ticks. No more than maxTicks are ever
+// due, any time beyond that is discarded.
+func ticksDue(elapsed, step time.Duration, maxTicks int) (int, time.Duration) {
+	ticks := int(elapsed / step)
+	if ticks > maxTicks {
+		return maxTicks, 0
+	}
+	return ticks, elapsed - time.Duration(ticks)*step
+}
+
+// RunGameLoop starts running g in its own goroutine, advancing it
+// config.TicksPerSecond times per second of wall clock time. Every Tick
+// advances the game by the same amount of game time, so identical orders
+// arriving at identical ticks always produce identical games. The loop stops
+// ticking once the game is finished, but keeps running until it's stopped.
+// Games in the lobby don't tick until they're started. Events from the game
+// are sent to subscribers after every batch of orders, and after the status
+// is published following every round of Ticks. Settings in config that the
+// loop can't run with are replaced, see LoopConfig.WithDefaults.
+func RunGameLoop(g *Game, config LoopConfig) *GameLoop {
+	config = config.WithDefaults()
+	orders := make(chan orderBatch)
+	calls := make(chan loopCall)
+	shared := &GameLoop{
+		viewers:  make(map[string]bool),
+		lastRead: make(map[string]int),
+		done:     make(chan struct{}),
+	}
 	shared.orders = orders
+	shared.calls = calls
+
+	startIfReady := func() {
+		if len(g.Cultures) >= config.MinPlayers {
+			StartGame(g)
+		}
+	}
+
+	// Only cultures that someone is reading the status of are worth
+	// building statuses for, so viewers that nobody has read in a while
+	// are dropped.
+	publish := func() {
+		workingStatus := ReadStatus(g)
+		workingStatuses := make(map[string]GameStatus)
+		for _, culture := range g.Cultures {
+			if shared.viewers[culture.Name] {
+				workingStatuses[culture.Name] = ReadStatusFor(g, culture)
+			}
+		}
+
+		shared.statusLock.Lock()
+		shared.status = workingStatus
+		shared.statuses = workingStatuses
+		shared.publishes++
+		for culture := range shared.viewers {
+			if shared.publishes-shared.lastRead[culture] > viewerExpiry {
+				delete(shared.viewers, culture)
+				delete(shared.lastRead, culture)
+			}
+		}
+		shared.statusLock.Unlock()
+	}
+	startIfReady()
+	publish()
+
+	step := time.Second / time.Duration(config.TicksPerSecond)
 
 	go func() {
-		lastTime := time.Now().UnixNano() / 1000000
-		for shared.IsStopped() {
+		lastTime := time.Now()
+		var elapsed time.Duration
+		timer := time.NewTimer(step)
+		defer timer.Stop()
+
+		for !shared.IsStopped() {
 			select {
-			case orders := <-orders:
-				for _, o := range orders {
-					o.Apply(g)
-				}
-			default:
+			case batch := <-orders:
+				batch.results <- ApplyOrders(g, batch.orders)
+				shared.announce(TakeEvents(g))
+			case call := <-calls:
+				call.f(g)
+				startIfReady()
+				publish()
+				shared.announce(TakeEvents(g))
+				close(call.finished)
+			case <-timer.C:
+				timer.Reset(step)
+			case <-shared.done:
 			}
 
-			thisTime := time.Now().UnixNano()
-			dt := float64(thisTime - lastTime)
+			thisTime := time.Now()
+			elapsed = elapsed + thisTime.Sub(lastTime)
 			lastTime = thisTime
-			Tick(g, dt)
-
-			// TODO readStatus needs to be cheap, or needs to be on-demand
-			workingStatus := ReadStatus(g)
 
-			shared.statusLock.Lock()
-			shared.status = workingStatus
-			shared.statusLock.Unlock()
+			if g.phase != PhaseRunning {
+				// Games in the lobby wait for players, and
+				// finished games keep serving their final
+				// status and answering calls until they're
+				// stopped.
+				elapsed = 0
+				continue
+			}
+			var ticks int
+			ticks, elapsed = ticksDue(elapsed, step, config.MaxCatchUpTicks)
+			if ticks == 0 {
+				continue
+			}
+			var events []Event
+			for i := 0; i < ticks; i++ {
+				Tick(g, TickDuration)
+				events = append(events, TakeEvents(g)...)
+			}
+			publish()
+			shared.announce(events)
 		}
 	}()
 
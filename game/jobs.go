@@ -0,0 +1,166 @@
+package game
+
+// JobsOrder turns the named culture's job scheduler on or off.
+type JobsOrder struct {
+	Culture string `json:"culture"`
+	Enabled bool   `json:"enabled"`
+}
+
+// PriorityOrder sets the priority of the named house, which must still need
+// resources to be finished or to train characters. The job scheduler sends
+// builders to higher priority houses first.
+type PriorityOrder struct {
+	House    string `json:"house"`
+	Priority int    `json:"priority"`
+}
+
+// Apply turns the job scheduler on or off.
+func (o *JobsOrder) Apply(game *Game) error {
+	culture, err := o.Issuer(game)
+	if err != nil {
+		return err
+	}
+	culture.AutoJobs = o.Enabled
+	return nil
+}
+
+// Issuer is the culture whose job scheduler is being switched.
+func (o *JobsOrder) Issuer(game *Game) (*Culture, error) {
+	culture, ok := lookup(game, o.Culture).(*Culture)
+	if !ok {
+		return nil, &OrderError{RejectUnknownCulture, o.Culture}
+	}
+	return culture, nil
+}
+
+// Apply sets the priority of the house.
+func (o *PriorityOrder) Apply(game *Game) error {
+	house, ok := lookup(game, o.House).(*House)
+	if !ok {
+		return &OrderError{RejectUnknownTarget, o.House}
+	}
+	if IsDeposit(house) || !needsResources(house) {
+		return &OrderError{RejectNotPlanned, o.House}
+	}
+	house.Priority = o.Priority
+	return nil
+}
+
+// Issuer is the culture that owns the house.
+func (o *PriorityOrder) Issuer(game *Game) (*Culture, error) {
+	house, ok := lookup(game, o.House).(*House)
+	if !ok {
+		return nil, &OrderError{RejectUnknownTarget, o.House}
+	}
+	return house.Culture, nil
+}
+
+// needsResources reports whether house is still unfinished, or is waiting on
+// resources to train characters.
+func needsResources(house *House) bool {
+	return resourcesNeeded(house) > 0
+}
+
+// canWork reports whether who is able to carry resources around at all.
+func canWork(who *Character) bool {
+	return who.Type.WorkPerTick > 0 && who.Type.MaxCarry > 0
+}
+
+// unfinishedHouses returns the houses culture could build on right now. Plans
+// with something in the way are left out, since nobody can work on them.
+func unfinishedHouses(culture *Culture) []*House {
+	var ret []*House
+	for _, house := range sortedHouses(culture.PlannedHouses) {
+		clear := isTerrainClear(house, culture.game.terrain,
+			house.Location.X, house.Location.Y, house.Type.Width, house.Type.Height)
+		if clear {
+			ret = append(ret, house)
+		}
+	}
+	for _, house := range sortedHouses(culture.BuiltHouses) {
+		if needsResources(house) {
+			ret = append(ret, house)
+		}
+	}
+	return ret
+}
+
+// bestHouse picks the house with the highest priority, and of those the one
+// closest to who. It returns nil if there are no houses.
+func bestHouse(who *Character, houses []*House, usePriority bool) *House {
+	var ret *House
+	closest := 0
+	here := tile{who.Location.X, who.Location.Y}
+	for _, house := range houses {
+		loc := workLocation(house)
+		dist := distSquared(here, tile{loc.X, loc.Y})
+		if ret != nil && usePriority && house.Priority != ret.Priority {
+			if house.Priority > ret.Priority {
+				ret = house
+				closest = dist
+			}
+			continue
+		}
+		if ret == nil || dist < closest {
+			ret = house
+			closest = dist
+		}
+	}
+	return ret
+}
+
+// assignJob picks the next thing for an idle character to do. Characters with
+// resources build with them, or drop them off if nothing needs building.
+// Empty handed characters fetch resources from the bank if there's something
+// to build, and otherwise go mining.
+func assignJob(who *Character) {
+	culture := who.Culture
+	unfinished := unfinishedHouses(culture)
+	depot := nearestDepot(who)
+
+	if who.Carrying > 0 {
+		if house := bestHouse(who, unfinished, true); house != nil {
+			who.Target = house
+			return
+		}
+		if depot != nil {
+			who.Target = depot
+			return
+		}
+	} else if depot != nil && culture.Resources > 0 {
+		if house := bestHouse(who, unfinished, true); house != nil {
+			who.Target = depot
+			who.work = house
+			return
+		}
+	}
+
+	if who.Carrying < who.Type.MaxCarry {
+		var deposits []*House
+		for _, deposit := range Deposits(culture.game) {
+			if deposit.ResourcesLeft > 0 {
+				deposits = append(deposits, deposit)
+			}
+		}
+		if deposit := bestHouse(who, deposits, false); deposit != nil {
+			who.Target = deposit
+		}
+	}
+}
+
+// AssignJobs gives something to do to every idle character in every culture
+// that has AutoJobs turned on. Characters that can't carry anything, like
+// scouts, are left alone. Tick calls AssignJobs after moving everyone, so
+// characters never spend more than a tick idle.
+func AssignJobs(game *Game) {
+	for _, culture := range game.Cultures {
+		if !culture.AutoJobs {
+			continue
+		}
+		for _, who := range culture.Characters {
+			if who.Target == nil && canWork(who) {
+				assignJob(who)
+			}
+		}
+	}
+}
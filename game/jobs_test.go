@@ -0,0 +1,121 @@
+package game
+
+import "testing"
+
+func TestJobsOrders(t *testing.T) {
+	game := NewGame(16, 16)
+	culture := AddCulture(game)
+	deposit, _ := AddDeposit(game, depositType, Location{10, 10, 0.0}, 0)
+	house := PlanHouse(culture, houseType, Location{4, 4, 0.0})
+
+	jobs := &JobsOrder{Culture: culture.Name, Enabled: true}
+	if err := ApplyOrders(game, []Order{jobs})[0].Err; err != nil || !culture.AutoJobs {
+		t.Errorf("Expected jobs order to turn on AutoJobs, got %v", err)
+	}
+
+	priority := &PriorityOrder{House: house.Name, Priority: 3}
+	if err := priority.Apply(game); err != nil || house.Priority != 3 {
+		t.Errorf("Expected priority order to set priority, got %v", err)
+	}
+
+	rejected := []struct {
+		order  Order
+		reason string
+	}{
+		{&JobsOrder{Culture: "nobody"}, RejectUnknownCulture},
+		{&PriorityOrder{House: "nowhere"}, RejectUnknownTarget},
+		{&PriorityOrder{House: deposit.Name}, RejectNotPlanned},
+	}
+	for _, r := range rejected {
+		err, ok := r.order.Apply(game).(*OrderError)
+		if !ok || err.Reason != r.reason {
+			t.Errorf("Expected %v to be rejected with %q, got %v", r.order, r.reason, err)
+		}
+	}
+
+	house.ResourcesLeft = houseType.MaxResources
+	rerankHouse(game.terrain, house)
+	finished := &PriorityOrder{House: house.Name, Priority: 1}
+	if err, ok := finished.Apply(game).(*OrderError); !ok || err.Reason != RejectNotPlanned {
+		t.Errorf("Expected finished houses to be rejected, got %v", err)
+	}
+}
+
+func TestAssignJobsPrefersPriority(t *testing.T) {
+	game := NewGame(16, 16)
+	culture := AddCulture(game)
+	culture.AutoJobs = true
+	near := PlanHouse(culture, houseType, Location{4, 0, 0.0})
+	far := PlanHouse(culture, houseType, Location{12, 12, 0.0})
+	who, _ := AddCharacter(game.terrain, culture, workerType, loc0x0)
+	who.Carrying = workerType.MaxCarry
+
+	AssignJobs(game)
+	if who.Target != near {
+		t.Errorf("Expected worker to build the nearest house")
+	}
+
+	who.Target = nil
+	far.Priority = 1
+	AssignJobs(game)
+	if who.Target != far {
+		t.Errorf("Expected worker to build the higher priority house")
+	}
+}
+
+func TestAssignJobsOnlyWhenEnabled(t *testing.T) {
+	game := NewGame(16, 16)
+	culture := AddCulture(game)
+	AddDeposit(game, depositType, Location{10, 10, 0.0}, 0)
+	who, _ := AddCharacter(game.terrain, culture, workerType, loc0x0)
+
+	Tick(game, 1.0)
+	if who.Target != nil {
+		t.Errorf("Expected idle worker to stay idle without AutoJobs")
+	}
+
+	culture.AutoJobs = true
+	Tick(game, 1.0)
+	if who.Target == nil {
+		t.Errorf("Expected idle worker to go mining with AutoJobs")
+	}
+}
+
+func TestAutoJobsBuildWithoutOrders(t *testing.T) {
+	for _, scheduling := range []Scheduling{InOrderScheduling, FairScheduling} {
+		game := NewGame(16, 16)
+		SetScheduling(game, scheduling, 1)
+		culture := AddCulture(game)
+		culture.AutoJobs = true
+		addDepot(game, culture, Location{0, 8, 0.0})
+		AddDeposit(game, depositType, Location{12, 12, 0.0}, 150)
+		house := PlanHouse(culture, houseType, Location{8, 4, 0.0})
+		AddCharacter(game.terrain, culture, workerType, loc0x0)
+		AddCharacter(game.terrain, culture, workerType, Location{4, 0, 0.0})
+
+		for i := 0; i < 1000 && needsResources(house); i++ {
+			Tick(game, 1.0)
+		}
+
+		if needsResources(house) {
+			t.Fatalf("Expected workers to mine and build on their own, %v of %v resources",
+				house.ResourcesLeft, houseType.MaxResources)
+		}
+	}
+}
+
+func TestSaveKeepsJobs(t *testing.T) {
+	game := NewGame(16, 16)
+	culture := AddCulture(game)
+	culture.AutoJobs = true
+	house := PlanHouse(culture, houseType, Location{4, 4, 0.0})
+	house.Priority = 2
+
+	loaded := saveAndLoad(t, game)
+	if !loaded.Cultures[0].AutoJobs {
+		t.Errorf("Expected AutoJobs to be saved")
+	}
+	if loadedHouse := lookup(loaded, house.Name).(*House); loadedHouse.Priority != 2 {
+		t.Errorf("Expected priority 2, got %d", loadedHouse.Priority)
+	}
+}
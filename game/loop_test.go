@@ -1,7 +1,257 @@
 package game
 
-import "testing"
+import (
+	"testing"
+	"time"
+)
 
 func TestBuildAndMineLoop(t *testing.T) {
 
 }
+
+func TestTicksDue(t *testing.T) {
+	step := 50 * time.Millisecond
+	cases := []struct {
+		elapsed   time.Duration
+		ticks     int
+		remaining time.Duration
+	}{
+		{0, 0, 0},
+		{49 * time.Millisecond, 0, 49 * time.Millisecond},
+		{50 * time.Millisecond, 1, 0},
+		{120 * time.Millisecond, 2, 20 * time.Millisecond},
+		{250 * time.Millisecond, 5, 0},
+		{10 * time.Second, 5, 0},
+	}
+
+	for _, c := range cases {
+		ticks, remaining := ticksDue(c.elapsed, step, 5)
+		if ticks != c.ticks || remaining != c.remaining {
+			t.Errorf("ticksDue(%v) gave %d, %v, expected %d, %v",
+				c.elapsed, ticks, remaining, c.ticks, c.remaining)
+		}
+	}
+}
+
+func TestLoopConfigDefaults(t *testing.T) {
+	configs := []LoopConfig{
+		{},
+		{TicksPerSecond: -3, MaxCatchUpTicks: -1},
+	}
+	for _, config := range configs {
+		if fixed := config.WithDefaults(); fixed != DefaultLoopConfig {
+			t.Errorf("Expected %v to fall back to the defaults, got %v", config, fixed)
+		}
+	}
+
+	custom := LoopConfig{TicksPerSecond: 1000, MaxCatchUpTicks: 2, MinPlayers: 3}
+	if custom.WithDefaults() != custom {
+		t.Errorf("Expected a usable config to be left alone")
+	}
+
+	loop := RunGameLoop(NewGame(4, 4), LoopConfig{})
+	defer loop.Stop()
+	waitForTick(t, loop, 1)
+}
+
+func TestGameLoopTicks(t *testing.T) {
+	g := NewGame(16, 16)
+	AddCulture(g)
+	character, _ := AddCharacter(g.terrain, g.Cultures[0], workerType, loc0x0)
+
+	loop := RunGameLoop(g, LoopConfig{TicksPerSecond: 1000, MaxCatchUpTicks: 5})
+	defer loop.Stop()
+
+	results := loop.WriteOrders([]Order{
+		&MarchOrder{Character: character.Name, X: 6, Y: 8},
+	})
+	if len(results) != 1 || results[0].Err != nil {
+		t.Fatalf("Unexpected march results %v", results)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for loop.CurrentTick() < 20 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Loop only reached tick %d", loop.CurrentTick())
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	status := loop.ReadLatestStatus()
+	if status.Cultures[0].Characters[0].Location != loc6x8 {
+		t.Errorf("Character didn't march, status %v", status)
+	}
+}
+
+func TestGameLoopStop(t *testing.T) {
+	loop := RunGameLoop(NewGame(4, 4), DefaultLoopConfig)
+	loop.Stop()
+	loop.Stop()
+
+	if !loop.IsStopped() {
+		t.Errorf("Stopped loop isn't stopped")
+	}
+	if results := loop.WriteOrders([]Order{&MarchOrder{}}); results != nil {
+		t.Errorf("Stopped loop accepted orders")
+	}
+}
+
+func TestGameLoopFinishes(t *testing.T) {
+	g := NewGame(4, 4)
+	AddCulture(g)
+	AddWinCondition(g, TimeLimit{Ticks: 10})
+	loop := RunGameLoop(g, LoopConfig{TicksPerSecond: 1000, MaxCatchUpTicks: 5})
+	defer loop.Stop()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for loop.ReadLatestStatus().Phase != PhaseFinished {
+		if time.Now().After(deadline) {
+			t.Fatalf("Loop never finished the game")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	status := loop.ReadLatestStatus()
+	if status.Tick != 10 || status.Result == nil || status.Result.Tick != 10 {
+		t.Fatalf("Expected the loop to stop ticking at the time limit, got %v", status)
+	}
+	status.Result.Winners[0] = "nobody"
+	if winners := loop.ReadLatestStatus().Result.Winners; winners[0] == "nobody" {
+		t.Errorf("Expected every reader to get its own result, got %v", winners)
+	}
+	if loop.IsStopped() {
+		t.Errorf("Expected the loop to keep serving the finished game")
+	}
+	if _, ok := loop.AddCulture(); ok {
+		t.Errorf("Expected no new cultures in a finished game")
+	}
+}
+
+// waitForTick waits for loop to reach tick.
+func waitForTick(t *testing.T, loop *GameLoop, tick int) {
+	deadline := time.Now().Add(5 * time.Second)
+	for loop.CurrentTick() < tick {
+		if time.Now().After(deadline) {
+			t.Fatalf("Loop only reached tick %d", loop.CurrentTick())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestGameLoopLobby(t *testing.T) {
+	loop := RunGameLoop(NewGame(4, 4),
+		LoopConfig{TicksPerSecond: 1000, MaxCatchUpTicks: 5, MinPlayers: 2})
+	defer loop.Stop()
+
+	loop.AddCulture()
+	time.Sleep(20 * time.Millisecond)
+	if status := loop.ReadLatestStatus(); status.Tick != 0 || status.Phase != PhaseLobby {
+		t.Fatalf("Expected the game to wait in the lobby for players, got %v", status)
+	}
+
+	loop.AddCulture()
+	if phase := loop.ReadLatestStatus().Phase; phase != PhaseRunning {
+		t.Fatalf("Expected the game to start once enough players joined, got %v", phase)
+	}
+	waitForTick(t, loop, 1)
+	if loop.Start() {
+		t.Errorf("Expected a running game not to start again")
+	}
+}
+
+func TestGameLoopStart(t *testing.T) {
+	loop := RunGameLoop(NewGame(4, 4),
+		LoopConfig{TicksPerSecond: 1000, MaxCatchUpTicks: 5, MinPlayers: 4})
+	defer loop.Stop()
+
+	loop.AddCulture()
+	if !loop.Start() || loop.ReadLatestStatus().Phase != PhaseRunning {
+		t.Fatalf("Expected the game to start when asked")
+	}
+	waitForTick(t, loop, 1)
+}
+
+func TestGameLoopStatusFor(t *testing.T) {
+	loop := RunGameLoop(NewGame(4, 4), LoopConfig{TicksPerSecond: 1000, MaxCatchUpTicks: 5})
+	defer loop.Stop()
+
+	watched, _ := loop.AddCulture()
+	loop.AddCulture()
+	if status, ok := loop.ReadLatestStatusFor(watched); !ok || status.Viewer != watched {
+		t.Fatalf("Expected a status as seen by %s, got %v", watched, status)
+	}
+	if _, ok := loop.ReadLatestStatusFor("nobody"); ok {
+		t.Errorf("Expected no status for a culture that isn't playing")
+	}
+
+	waitForTick(t, loop, 3)
+	status, _ := loop.ReadLatestStatusFor(watched)
+	if status.Tick < 3 {
+		t.Errorf("Expected %s's status to be kept up to date, got tick %d", watched, status.Tick)
+	}
+	loop.statusLock.RLock()
+	defer loop.statusLock.RUnlock()
+	if len(loop.statuses) != 1 {
+		t.Errorf("Expected statuses only for cultures being read, got %d", len(loop.statuses))
+	}
+}
+
+func TestGameLoopDropsUnreadViewers(t *testing.T) {
+	loop := RunGameLoop(NewGame(4, 4), LoopConfig{TicksPerSecond: 1000, MaxCatchUpTicks: 5})
+	defer loop.Stop()
+
+	watched, _ := loop.AddCulture()
+	status, ok := loop.ReadLatestStatusFor(watched)
+	if !ok {
+		t.Fatalf("Expected a status as seen by %s", watched)
+	}
+
+	// Every round of ticks publishes, and runs no more than MaxCatchUpTicks
+	waitForTick(t, loop, status.Tick+5*(viewerExpiry+2))
+	loop.statusLock.RLock()
+	statuses := len(loop.statuses)
+	loop.statusLock.RUnlock()
+	if statuses != 0 {
+		t.Errorf("Expected no statuses for a culture nobody reads, got %d", statuses)
+	}
+
+	if status, ok := loop.ReadLatestStatusFor(watched); !ok || status.Viewer != watched {
+		t.Errorf("Expected a status as seen by %s once read again, got %v", watched, status)
+	}
+}
+
+func TestGameLoopSubscribe(t *testing.T) {
+	g := NewGame(16, 16)
+	culture := AddCulture(g)
+	house := PlanHouse(culture, houseType, Location{6, 6, 0.0})
+	who, _ := AddCharacter(g.terrain, culture, workerType, loc0x0)
+	who.Carrying = houseType.MaxResources
+	who.Target = house
+
+	loop := RunGameLoop(g, LoopConfig{TicksPerSecond: 1000, MaxCatchUpTicks: 5})
+	events, cancel := loop.Subscribe()
+	defer cancel()
+
+	timeout := time.After(5 * time.Second)
+	for completed := false; !completed; {
+		select {
+		case batch := <-events:
+			completed = len(eventsOfType(batch, EventHouseCompleted)) > 0
+		case <-timeout:
+			t.Fatalf("Never heard about the house being completed")
+		}
+	}
+
+	loop.Stop()
+	for range events {
+		// Drain until the loop closes the subscription
+	}
+	if _, ok := <-events; ok {
+		t.Errorf("Expected stopping the loop to end subscriptions")
+	}
+	late, _ := loop.Subscribe()
+	if _, ok := <-late; ok {
+		t.Errorf("Expected subscribing to a stopped loop to get nothing")
+	}
+}
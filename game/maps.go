@@ -0,0 +1,457 @@
+package game
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"os"
+)
+
+// Map describes the starting state of a game. Tiles is the ground, drawn one
+// row per string with the same symbols DumpTerrain uses, so map files can be
+// written by hand:
+//
+//	{
+//	  "tiles": [
+//	    "--------",
+//	    "--^^----",
+//	    "--^^--##",
+//	    "========"
+//	  ],
+//	  "deposits": [{"type": "house", "x": 5, "y": 0, "resources": 50}],
+//	  "starts": [
+//	    {"characters": [{"type": "worker", "x": 0, "y": 0}]},
+//	    {"characters": [{"type": "worker", "x": 7, "y": 0}]}
+//	  ]
+//	}
+//
+// Deposits and characters refer to types in the catalog the map is played
+// with. Each start is the roster of one culture.
+type Map struct {
+	Tiles    []string     `json:"tiles"`
+	Deposits []MapDeposit `json:"deposits"`
+	Starts   []MapStart   `json:"starts"`
+}
+
+// MapDeposit is a pile of resources for cultures to mine. Deposits with no
+// Resources start full.
+type MapDeposit struct {
+	Type      string  `json:"type"`
+	X         int     `json:"x"`
+	Y         int     `json:"y"`
+	Resources float64 `json:"resources,omitempty"`
+}
+
+// MapStart is where one culture's characters begin the game, along with the
+// houses it starts with already built and the resources in its bank.
+type MapStart struct {
+	Characters []MapCharacter `json:"characters"`
+	Houses     []MapHouse     `json:"houses,omitempty"`
+	Resources  float64        `json:"resources,omitempty"`
+}
+
+// MapHouse is a finished house placed on a map.
+type MapHouse struct {
+	Type string `json:"type"`
+	X    int    `json:"x"`
+	Y    int    `json:"y"`
+}
+
+// MapCharacter is a single character placed on a map.
+type MapCharacter struct {
+	Type string `json:"type"`
+	X    int    `json:"x"`
+	Y    int    `json:"y"`
+}
+
+// ReadMap reads a map written as JSON.
+func ReadMap(r io.Reader) (*Map, error) {
+	var m Map
+	if err := json.NewDecoder(r).Decode(&m); err != nil {
+		return nil, fmt.Errorf("can't read map: %v", err)
+	}
+	return &m, nil
+}
+
+// ReadMapFile reads the map in the named file.
+func ReadMapFile(path string) (*Map, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadMap(f)
+}
+
+// NewGameFromMap creates a game that's ready to play on m, using the types in
+// catalog. Every start gets its own culture, added in order with AddCulture,
+// and the deposits are added with AddDeposit. Maps can also be played with
+// their starts left out, and given to cultures as they join with
+// CultureOrders.
+func NewGameFromMap(m *Map, catalog *Catalog) (*Game, error) {
+	if len(m.Tiles) == 0 || len(m.Tiles[0]) == 0 {
+		return nil, fmt.Errorf("map has no tiles")
+	}
+	game := NewGame(len(m.Tiles[0]), len(m.Tiles))
+	if err := AddCatalog(game, catalog); err != nil {
+		return nil, err
+	}
+	if err := setTileRows(game.terrain, m.Tiles); err != nil {
+		return nil, fmt.Errorf("map has bad tiles: %v", err)
+	}
+
+	for _, d := range m.Deposits {
+		houseType, ok := game.houseTypes[d.Type]
+		if !ok {
+			return nil, fmt.Errorf("deposit at %d, %d has unknown type %q", d.X, d.Y, d.Type)
+		}
+		_, err := AddDeposit(game, houseType, Location{X: d.X, Y: d.Y}, d.Resources)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for i, start := range m.Starts {
+		if _, err := addStart(game, start); err != nil {
+			return nil, fmt.Errorf("start %d %v", i, err)
+		}
+	}
+
+	return game, nil
+}
+
+// addStart adds a new culture to game with everything in start. Nothing is
+// added unless all of start fits: its types have to be in the game, and its
+// houses and characters have to be on open ground, clear of everything else
+// and each other.
+func addStart(game *Game, start MapStart) (*Culture, error) {
+	taken := make(map[tile]bool)
+	fits := func(x, y, width, height int) bool {
+		if !isTerrainClear(nil, game.terrain, x, y, width, height) {
+			return false
+		}
+		for dx := 0; dx < width; dx++ {
+			for dy := 0; dy < height; dy++ {
+				if taken[tile{x + dx, y + dy}] {
+					return false
+				}
+				taken[tile{x + dx, y + dy}] = true
+			}
+		}
+		return true
+	}
+
+	houseTypes := make([]*HouseType, len(start.Houses))
+	for i, h := range start.Houses {
+		houseType, ok := game.houseTypes[h.Type]
+		if !ok {
+			return nil, fmt.Errorf("has unknown house type %q", h.Type)
+		}
+		if !fits(h.X, h.Y, houseType.Width, houseType.Height) {
+			return nil, fmt.Errorf("has an obstructed house at %d, %d", h.X, h.Y)
+		}
+		houseTypes[i] = houseType
+	}
+	characterTypes := make([]*CharacterType, len(start.Characters))
+	for i, c := range start.Characters {
+		characterType, ok := game.characterTypes[c.Type]
+		if !ok {
+			return nil, fmt.Errorf("has unknown character type %q", c.Type)
+		}
+		if !fits(c.X, c.Y, characterType.Width, characterType.Height) {
+			return nil, fmt.Errorf("has an obstructed character at %d, %d", c.X, c.Y)
+		}
+		characterTypes[i] = characterType
+	}
+
+	culture := AddCulture(game)
+	culture.Resources = start.Resources
+	for i, h := range start.Houses {
+		house := PlanHouse(culture, houseTypes[i], Location{X: h.X, Y: h.Y})
+		house.ResourcesLeft = houseTypes[i].MaxResources
+		rerankHouse(game.terrain, house)
+	}
+	for i, c := range start.Characters {
+		_, err := AddCharacter(game.terrain, culture, characterTypes[i],
+			Location{X: c.X, Y: c.Y})
+		if err != nil {
+			log.Panicf("can't place a start that fits, %v", err)
+		}
+	}
+	return culture, nil
+}
+
+// MapConfig controls GenerateMap. Maps can be generated for 2 or 4 players.
+type MapConfig struct {
+	Width, Height int
+	Players       int
+	Seed          int64
+
+	WorkerType string // character type every culture starts with
+	Workers    int    // how many of them
+
+	DepositType       string // house type to use for deposits
+	DepositsPerPlayer int
+
+	DepotType string  // house type every culture starts with, if any
+	Resources float64 // resources every culture starts with
+}
+
+// mapGenerationAttempts is how many seeds GenerateMap tries before giving up
+// on finding a map where everyone can reach each other.
+const mapGenerationAttempts = 16
+
+// startMargin is how far starts are from the corners of generated maps, and
+// startClearing is how much open ground surrounds them.
+const startMargin, startClearing = 2, 6
+
+// symmetry maps a footprint at x, y to the matching spot for another player.
+type symmetry func(x, y, width, height int) (int, int)
+
+// symmetries returns a symmetry for each player. The first is always the
+// identity, and the second is always the opposite corner, so 2 player maps
+// are face to face.
+func symmetries(mapWidth, mapHeight, players int) ([]symmetry, error) {
+	identity := func(x, y, w, h int) (int, int) { return x, y }
+	rotate := func(x, y, w, h int) (int, int) { return mapWidth - x - w, mapHeight - y - h }
+	flipX := func(x, y, w, h int) (int, int) { return mapWidth - x - w, y }
+	flipY := func(x, y, w, h int) (int, int) { return x, mapHeight - y - h }
+
+	switch players {
+	case 2:
+		return []symmetry{identity, rotate}, nil
+	case 4:
+		return []symmetry{identity, rotate, flipX, flipY}, nil
+	}
+	return nil, fmt.Errorf("can't generate maps for %d players", players)
+}
+
+// GenerateMap builds a random map from config.Seed. Every player's corner of
+// the map is a mirror image of every other player's, so no start is better
+// than another. The same config always generates the same map.
+func GenerateMap(config MapConfig, catalog *Catalog) (*Map, error) {
+	if config.Width < 2*startClearing || config.Height < 2*startClearing {
+		return nil, fmt.Errorf("maps must be at least %dx%d", 2*startClearing, 2*startClearing)
+	}
+	syms, err := symmetries(config.Width, config.Height, config.Players)
+	if err != nil {
+		return nil, err
+	}
+	worker, ok := catalog.CharacterTypes[config.WorkerType]
+	if !ok {
+		return nil, fmt.Errorf("unknown character type %q", config.WorkerType)
+	}
+	depositType, ok := catalog.HouseTypes[config.DepositType]
+	if !ok {
+		return nil, fmt.Errorf("unknown house type %q", config.DepositType)
+	}
+	if config.Workers*worker.Width > startClearing-startMargin+1 {
+		// Workers start in a row, which has to fit in the clearing.
+		return nil, fmt.Errorf("can't fit %d workers in a start", config.Workers)
+	}
+	var depot *HouseType
+	if config.DepotType != "" {
+		depot, ok = catalog.HouseTypes[config.DepotType]
+		if !ok {
+			return nil, fmt.Errorf("unknown house type %q", config.DepotType)
+		}
+		// The depot goes below the workers, in the clearing.
+		if depot.Width > startClearing || worker.Height+1+depot.Height > startClearing {
+			return nil, fmt.Errorf("can't fit a depot in a start")
+		}
+	}
+
+	// Failed attempts carry on with the same rng, so that maps from nearby
+	// seeds don't end up identical.
+	rng := rand.New(rand.NewSource(config.Seed))
+	for attempt := 0; attempt < mapGenerationAttempts; attempt++ {
+		m := generateMap(config, syms, rng, worker, depositType, depot)
+
+		game, err := NewGameFromMap(m, catalog)
+		if err != nil {
+			return nil, err
+		}
+		if startsConnected(game) {
+			return m, nil
+		}
+	}
+
+	return nil, fmt.Errorf("couldn't generate a map where every start is reachable")
+}
+
+// GenerateGame creates a game that's ready to play on a map built by
+// GenerateMap.
+func GenerateGame(config MapConfig, catalog *Catalog) (*Game, error) {
+	m, err := GenerateMap(config, catalog)
+	if err != nil {
+		return nil, err
+	}
+	return NewGameFromMap(m, catalog)
+}
+
+func generateMap(config MapConfig, syms []symmetry, rng *rand.Rand,
+	worker *CharacterType, depositType *HouseType, depot *HouseType) *Map {
+	width, height := config.Width, config.Height
+	tiles := make([][]TileKind, width)
+	for x := range tiles {
+		tiles[x] = make([]TileKind, height)
+	}
+	reserved := make([][]bool, width)
+	for x := range reserved {
+		reserved[x] = make([]bool, height)
+	}
+
+	// set changes a tile for every player at once
+	set := func(x, y int, kind TileKind) {
+		for _, sym := range syms {
+			sx, sy := sym(x, y, 1, 1)
+			if !reserved[sx][sy] {
+				tiles[sx][sy] = kind
+			}
+		}
+	}
+	reserve := func(x, y, w, h int) {
+		for _, sym := range syms {
+			sx, sy := sym(x, y, w, h)
+			for dx := 0; dx < w; dx++ {
+				for dy := 0; dy < h; dy++ {
+					reserved[sx+dx][sy+dy] = true
+				}
+			}
+		}
+	}
+	isReserved := func(x, y, w, h int) bool {
+		for _, sym := range syms {
+			sx, sy := sym(x, y, w, h)
+			for dx := 0; dx < w; dx++ {
+				for dy := 0; dy < h; dy++ {
+					if reserved[sx+dx][sy+dy] {
+						return true
+					}
+				}
+			}
+		}
+		return false
+	}
+
+	reserve(0, 0, startClearing+startMargin, startClearing+startMargin)
+
+	// A road from each start to the middle of the map
+	roadY := startMargin + worker.Height
+	for x := startMargin; x <= width/2; x++ {
+		set(x, roadY, Road)
+	}
+	for y := roadY; y <= height/2; y++ {
+		set(width/2, y, Road)
+	}
+
+	// Patches of forest, rock and water
+	features := [...]TileKind{Forest, Forest, Rock, Water}
+	patches := width * height / 64
+	for i := 0; i < patches; i++ {
+		kind := features[rng.Intn(len(features))]
+		cx, cy := rng.Intn(width), rng.Intn(height)
+		r := 1 + rng.Intn(3)
+		for x := cx - r; x <= cx+r; x++ {
+			for y := cy - r; y <= cy+r; y++ {
+				inside := (x-cx)*(x-cx)+(y-cy)*(y-cy) <= r*r
+				if inside && x >= 0 && x < width && y >= 0 && y < height &&
+					tiles[x][y] != Road {
+					set(x, y, kind)
+				}
+			}
+		}
+	}
+
+	// Deposits near each start
+	var deposits []MapDeposit
+	for placed, tries := 0, 0; placed < config.DepositsPerPlayer && tries < 1000; tries++ {
+		reach := 3 * startClearing
+		x := startMargin + rng.Intn(reach)
+		y := startMargin + rng.Intn(reach)
+		w, h := depositType.Width, depositType.Height
+		// Leave room for the ring of space around the deposit
+		if x+w+1 > width || y+h+1 > height || isReserved(x, y, w, h) {
+			continue
+		}
+		clear := true
+		for i, sym := range syms {
+			sx, sy := sym(x, y, w, h)
+			for _, other := range syms[:i] {
+				ox, oy := other(x, y, w, h)
+				if sx < ox+w+2 && ox < sx+w+2 && sy < oy+h+2 && oy < sy+h+2 {
+					clear = false // too close to another player's copy
+				}
+			}
+			for dx := 0; dx < w; dx++ {
+				for dy := 0; dy < h; dy++ {
+					if !tileKinds[tiles[sx+dx][sy+dy]].passable {
+						clear = false
+					}
+				}
+			}
+		}
+		if !clear {
+			continue
+		}
+		// Keep a ring of space around deposits, so they can be mined
+		reserve(x-1, y-1, w+2, h+2)
+		for _, sym := range syms {
+			sx, sy := sym(x, y, w, h)
+			deposits = append(deposits, MapDeposit{Type: config.DepositType, X: sx, Y: sy})
+		}
+		placed++
+	}
+
+	m := &Map{Deposits: deposits}
+	for _, sym := range syms {
+		start := MapStart{Resources: config.Resources}
+		for i := 0; i < config.Workers; i++ {
+			x, y := sym(startMargin+i*worker.Width, startMargin, worker.Width, worker.Height)
+			start.Characters = append(start.Characters, MapCharacter{config.WorkerType, x, y})
+		}
+		if depot != nil {
+			x, y := sym(startMargin, startMargin+worker.Height+1, depot.Width, depot.Height)
+			start.Houses = append(start.Houses, MapHouse{config.DepotType, x, y})
+		}
+		m.Starts = append(m.Starts, start)
+	}
+	for y := 0; y < height; y++ {
+		row := make([]byte, width)
+		for x := 0; x < width; x++ {
+			row[x] = tileKinds[tiles[x][y]].symbol
+		}
+		m.Tiles = append(m.Tiles, string(row))
+	}
+	return m
+}
+
+// startsConnected reports whether the first character of every culture can
+// walk to the first character of every other culture.
+func startsConnected(game *Game) bool {
+	var first []*Character
+	for _, culture := range game.Cultures {
+		if len(culture.Characters) > 0 {
+			first = append(first, culture.Characters[0])
+		}
+	}
+	if len(first) < 2 {
+		return true
+	}
+
+	walker := first[0]
+	for _, other := range first[1:] {
+		// Aim next to the other character, since it's in the way
+		goal := Location{X: other.Location.X, Y: other.Location.Y + other.Type.Height}
+		if goal.Y+walker.Type.Height > game.terrain.Height {
+			goal.Y = other.Location.Y - walker.Type.Height
+		}
+		r := planRoute(walker, game.terrain, goal)
+		if manhattan(r.end, tile{goal.X, goal.Y}) > 1 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,256 @@
+package game
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testMap = `{
+	"tiles": [
+		"------------",
+		"--^^--------",
+		"--^^----##--",
+		"============",
+		"------------",
+		"------------"
+	],
+	"deposits": [{"type": "house", "x": 5, "y": 0, "resources": 50}],
+	"starts": [
+		{"characters": [
+			{"type": "worker", "x": 0, "y": 4},
+			{"type": "scout", "x": 3, "y": 4}
+		]},
+		{"characters": [{"type": "worker", "x": 10, "y": 4}]}
+	]
+}`
+
+func readTestCatalog(t *testing.T) *Catalog {
+	catalog, err := ReadCatalog(strings.NewReader(testCatalog))
+	if err != nil {
+		t.Fatalf("Unexpected error reading catalog: %v", err)
+	}
+	return catalog
+}
+
+func TestNewGameFromMap(t *testing.T) {
+	m, err := ReadMap(strings.NewReader(testMap))
+	if err != nil {
+		t.Fatalf("Unexpected error reading map: %v", err)
+	}
+	game, err := NewGameFromMap(m, readTestCatalog(t))
+	if err != nil {
+		t.Fatalf("Unexpected error creating game: %v", err)
+	}
+
+	if game.terrain.Width != 12 || game.terrain.Height != 6 {
+		t.Errorf("Expected a 12x6 game, got %dx%d", game.terrain.Width, game.terrain.Height)
+	}
+	if TileAt(game.terrain, 2, 1) != Forest || TileAt(game.terrain, 9, 2) != Rock ||
+		TileAt(game.terrain, 0, 3) != Road {
+		t.Errorf("Unexpected tiles %v", tileRows(game.terrain))
+	}
+
+	deposits := Deposits(game)
+	if len(deposits) != 1 {
+		t.Fatalf("Expected one deposit, got %d", len(deposits))
+	}
+	if deposits[0].ResourcesLeft != 50 || game.terrain.Board[5][0] != deposits[0] {
+		t.Errorf("Unexpected deposit %v", deposits[0])
+	}
+
+	if len(game.Cultures) != 2 {
+		t.Fatalf("Expected two players, got %d cultures", len(game.Cultures))
+	}
+	first := game.Cultures[0]
+	if len(first.Characters) != 2 || first.Characters[1].Type.Sight != 12 {
+		t.Errorf("Expected first player to start with a worker and scout")
+	}
+	if game.Cultures[1].Characters[0].Location != (Location{10, 4, 0.0}) {
+		t.Errorf("Unexpected second start %v", game.Cultures[1].Characters[0].Location)
+	}
+}
+
+func TestNewGameFromBadMap(t *testing.T) {
+	bad := []string{
+		`{"tiles": []}`,
+		`{"tiles": ["----", "---"]}`,
+		`{"tiles": ["--?-"]}`,
+		`{"tiles": ["----"], "deposits": [{"type": "castle", "x": 0, "y": 0}]}`,
+		`{"tiles": ["#---"], "deposits": [{"type": "house", "x": 0, "y": 0}]}`,
+		`{"tiles": ["----"], "starts": [{"characters": [{"type": "ghost"}]}]}`,
+		`{"tiles": ["----"], "starts": [{"characters": [{"type": "scout", "x": 4}]}]}`,
+	}
+	catalog := readTestCatalog(t)
+	for _, doc := range bad {
+		m, err := ReadMap(strings.NewReader(doc))
+		if err != nil {
+			t.Fatalf("Unexpected error reading map %s: %v", doc, err)
+		}
+		if _, err := NewGameFromMap(m, catalog); err == nil {
+			t.Errorf("Expected an error creating a game from %s", doc)
+		}
+	}
+}
+
+var testMapConfig = MapConfig{
+	Width:             48,
+	Height:            40,
+	Players:           2,
+	Seed:              42,
+	WorkerType:        "worker",
+	Workers:           2,
+	DepositType:       "house",
+	DepositsPerPlayer: 3,
+}
+
+func TestGenerateMapIsSymmetric(t *testing.T) {
+	catalog := readTestCatalog(t)
+	for _, players := range []int{2, 4} {
+		config := testMapConfig
+		config.Players = players
+		m, err := GenerateMap(config, catalog)
+		if err != nil {
+			t.Fatalf("Unexpected error generating %d player map: %v", players, err)
+		}
+
+		for y, row := range m.Tiles {
+			for x := range row {
+				if row[x] != m.Tiles[config.Height-1-y][config.Width-1-x] {
+					t.Fatalf("%d player map isn't symmetric at %d, %d", players, x, y)
+				}
+			}
+		}
+		if len(m.Starts) != players {
+			t.Errorf("Expected %d starts, got %d", players, len(m.Starts))
+		}
+		if len(m.Deposits) != players*config.DepositsPerPlayer {
+			t.Errorf("Expected %d deposits, got %d",
+				players*config.DepositsPerPlayer, len(m.Deposits))
+		}
+
+		game, err := NewGameFromMap(m, catalog)
+		if err != nil {
+			t.Fatalf("Generated map isn't playable: %v", err)
+		}
+		if !startsConnected(game) {
+			t.Errorf("Expected every start to be reachable")
+		}
+	}
+}
+
+func TestGenerateMapIsSeeded(t *testing.T) {
+	catalog := readTestCatalog(t)
+	a, _ := GenerateMap(testMapConfig, catalog)
+	b, _ := GenerateMap(testMapConfig, catalog)
+	if !reflect.DeepEqual(a, b) {
+		t.Errorf("Expected the same seed to generate the same map")
+	}
+
+	config := testMapConfig
+	config.Seed++
+	c, _ := GenerateMap(config, catalog)
+	if reflect.DeepEqual(a.Tiles, c.Tiles) {
+		t.Errorf("Expected different seeds to generate different maps")
+	}
+}
+
+func TestGenerateMapWithDepots(t *testing.T) {
+	catalog := readTestCatalog(t)
+	config := testMapConfig
+	config.Players = 4
+	config.DepotType = "house"
+	config.Resources = 30
+	game, err := GenerateGame(config, catalog)
+	if err != nil {
+		t.Fatalf("Unexpected error generating game: %v", err)
+	}
+
+	for _, culture := range game.Cultures {
+		if len(culture.BuiltHouses) != 1 || culture.Resources != 30 {
+			t.Errorf("Expected %s to start with a house and 30 resources", culture.Name)
+		}
+	}
+	if !startsConnected(game) {
+		t.Errorf("Expected every start to be reachable")
+	}
+}
+
+func TestGenerateMapBadConfig(t *testing.T) {
+	catalog := readTestCatalog(t)
+	bad := []MapConfig{
+		{Width: 48, Height: 40, Players: 3, WorkerType: "worker", DepositType: "house"},
+		{Width: 4, Height: 4, Players: 2, WorkerType: "worker", DepositType: "house"},
+		{Width: 48, Height: 40, Players: 2, WorkerType: "ghost", DepositType: "house"},
+		{Width: 48, Height: 40, Players: 2, WorkerType: "worker", DepositType: "castle"},
+		{Width: 48, Height: 40, Players: 2, WorkerType: "worker", DepositType: "house",
+			DepotType: "castle"},
+	}
+	for _, config := range bad {
+		if _, err := GenerateMap(config, catalog); err == nil {
+			t.Errorf("Expected an error generating a map with %v", config)
+		}
+	}
+}
+
+func TestCultureOrderStart(t *testing.T) {
+	m, err := ReadMap(strings.NewReader(testMap))
+	if err != nil {
+		t.Fatalf("Unexpected error reading map: %v", err)
+	}
+	starts := m.Starts
+	m.Starts = nil
+	game, err := NewGameFromMap(m, readTestCatalog(t))
+	if err != nil {
+		t.Fatalf("Unexpected error creating game: %v", err)
+	}
+	if len(game.Cultures) != 0 {
+		t.Fatalf("Expected no cultures before anyone joins, got %d", len(game.Cultures))
+	}
+
+	order := &CultureOrder{Start: &starts[0]}
+	if err := ApplyOrders(game, []Order{order})[0].Err; err != nil {
+		t.Fatalf("Unexpected error adding culture: %v", err)
+	}
+	culture := game.Cultures[0]
+	if culture.Name != order.Name || len(culture.Characters) != 2 ||
+		culture.Characters[0].Location != (Location{0, 4, 0.0}) {
+		t.Errorf("Expected %s to start with a worker and scout", order.Name)
+	}
+
+	// The first start is taken, and the second overlaps part of it
+	blocked := MapStart{Characters: []MapCharacter{
+		{Type: "worker", X: 11, Y: 4},
+		{Type: "worker", X: 3, Y: 4},
+	}}
+	err = ApplyOrders(game, []Order{&CultureOrder{Start: &blocked}})[0].Err
+	if orderErr, ok := err.(*OrderError); !ok || orderErr.Reason != RejectBadStart {
+		t.Errorf("Expected a blocked start to be rejected, got %v", err)
+	}
+	if len(game.Cultures) != 1 || game.terrain.Board[11][4] != nil {
+		t.Errorf("Expected nothing from a blocked start to be added")
+	}
+}
+
+func TestExampleMapIsPlayable(t *testing.T) {
+	catalog, err := ReadCatalogFile("../catalogs/default.json")
+	if err != nil {
+		t.Fatalf("Default catalog is broken: %v", err)
+	}
+	m, err := ReadMapFile("../maps/crossroads.json")
+	if err != nil {
+		t.Fatalf("Example map is broken: %v", err)
+	}
+	game, err := NewGameFromMap(m, catalog)
+	if err != nil {
+		t.Fatalf("Example map isn't playable: %v", err)
+	}
+	if !startsConnected(game) {
+		t.Errorf("Expected every start in the example map to be reachable")
+	}
+	for _, culture := range game.Cultures {
+		if nearestDepot(culture.Characters[0]) == nil || culture.Resources == 0 {
+			t.Errorf("Expected %s to start with a depot and resources", culture.Name)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package game
+
+import "container/heap"
+
+// maxRouteSearch bounds the number of tiles planRoute will consider, so that
+// unreachable goals on large maps don't stall a Tick. Characters that chase a
+// moving target plan a new route every tick, so it's kept well under the size
+// of the largest maps.
+const maxRouteSearch = 4096
+
+// maxGoalSearch bounds the number of tiles planRoute looks through to find a
+// free spot near a goal that's covered by something.
+const maxGoalSearch = 256
+
+// partialRouteCooldown is how many ticks a character follows a route that
+// doesn't reach its goal before planning again, in case the way has cleared.
+const partialRouteCooldown = 20
+
+// routeLookahead is how far along a route a character will aim each short
+// move. It must be small enough to keep the waypoint inside of the
+// maxShortMoveSide window that attemptShortMove searches.
+const routeLookahead = maxShortMoveSide/2 - 1
+
+// route is a character's plan for reaching a goal. Tiles are the positions the
+// character should pass through, not including the position it started from.
+// The route ends at the goal, or the nearest free spot to it if the goal is
+// covered, or else at the closest the character could get, in which case it
+// doesn't reach. Planned is the tick it was planned at, and searched is how
+// many tiles were considered to plan it.
+type route struct {
+	goal     tile
+	end      tile
+	tiles    []tile
+	reaches  bool
+	planned  int
+	searched int
+}
+
+func manhattan(a, b tile) int {
+	dx, dy := a.x-b.x, a.y-b.y
+	if dx < 0 {
+		dx = -dx
+	}
+	if dy < 0 {
+		dy = -dy
+	}
+	return dx + dy
+}
+
+type pathNode struct {
+	at       tile
+	cost     float64 // movement it takes to get here from the start
+	estimate float64 // cost plus the heuristic distance to the goal
+}
+
+// pathQueue is a priority queue of pathNodes. Among nodes with the same
+// estimate, the one furthest from the start comes first, which keeps the
+// search narrow in open country.
+type pathQueue []pathNode
+
+func (q pathQueue) Len() int { return len(q) }
+
+func (q pathQueue) Less(i, j int) bool {
+	if q[i].estimate == q[j].estimate {
+		return q[i].cost > q[j].cost
+	}
+	return q[i].estimate < q[j].estimate
+}
+
+func (q pathQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *pathQueue) Push(x interface{}) { *q = append(*q, x.(pathNode)) }
+
+func (q *pathQueue) Pop() interface{} {
+	old := *q
+	ret := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return ret
+}
+
+// freeGoal returns goal if who fits there, and otherwise the nearest spot
+// around it that who does fit, found by searching outward through whatever
+// covers the goal. Of the nearest spots, the one closest to who wins. Goals
+// with no free spot within maxGoalSearch tiles are returned as they are.
+func freeGoal(who *Character, terrain Terrain, goal tile) tile {
+	width, height := who.Type.Width, who.Type.Height
+	if isTerrainClear(who, terrain, goal.x, goal.y, width, height) {
+		return goal
+	}
+
+	start := tile{who.Location.X, who.Location.Y}
+	seen := map[tile]bool{goal: true}
+	frontier := []tile{goal}
+	for len(frontier) > 0 && len(seen) < maxGoalSearch {
+		var best tile
+		found := false
+		var next []tile
+		for _, at := range frontier {
+			for _, n := range neighbors(at) {
+				if seen[n] || !inBounds(terrain, n.x, n.y, 1, 1) {
+					continue
+				}
+				seen[n] = true
+				if !isTerrainClear(who, terrain, n.x, n.y, width, height) {
+					next = append(next, n)
+					continue
+				}
+				if !found || manhattan(n, start) < manhattan(best, start) {
+					best = n
+					found = true
+				}
+			}
+		}
+		if found {
+			return best
+		}
+		frontier = next
+	}
+	return goal
+}
+
+// planRoute uses A* to find the cheapest path for who's whole footprint from
+// its current location to goal, taking the moveCost of the ground into
+// account. The heuristic assumes the rest of the way is plain ground, so
+// routes that use roads are good but not always the very best. Goals covered
+// by a house or a character are planned to from the nearest free spot next to
+// them, see freeGoal. If goal can't be reached, the route leads to the
+// closest reachable spot instead, in the same way attemptShortMove does.
+func planRoute(who *Character, terrain Terrain, goal Location) *route {
+	start := tile{who.Location.X, who.Location.Y}
+	goalTile := freeGoal(who, terrain, tile{goal.X, goal.Y})
+
+	cameFrom := map[tile]tile{start: start}
+	costs := map[tile]float64{start: 0}
+	queue := &pathQueue{{start, 0, float64(manhattan(start, goalTile))}}
+
+	closest := start
+	closestDistSquared := distSquared(start, goalTile)
+
+	for queue.Len() > 0 && len(costs) < maxRouteSearch {
+		current := heap.Pop(queue).(pathNode)
+		if current.cost > costs[current.at] {
+			continue // we've already found a better way here
+		}
+
+		dist := distSquared(current.at, goalTile)
+		if dist < closestDistSquared ||
+			(dist == closestDistSquared && current.cost < costs[closest]) {
+			closest = current.at
+			closestDistSquared = dist
+		}
+		if current.at == goalTile {
+			break
+		}
+
+		for _, next := range neighbors(current.at) {
+			if !isTerrainClear(who, terrain, next.x, next.y,
+				who.Type.Width, who.Type.Height) {
+				continue
+			}
+			cost := current.cost + moveCost(terrain, next.x, next.y,
+				who.Type.Width, who.Type.Height)
+			known, ok := costs[next]
+			if (ok && known <= cost) || (!ok && len(costs) >= maxRouteSearch) {
+				continue
+			}
+			costs[next] = cost
+			cameFrom[next] = current.at
+			estimate := cost + float64(manhattan(next, goalTile))
+			heap.Push(queue, pathNode{next, cost, estimate})
+		}
+	}
+
+	var tiles []tile
+	for at := closest; at != start; at = cameFrom[at] {
+		tiles = append(tiles, at)
+	}
+	for i, j := 0, len(tiles)-1; i < j; i, j = i+1, j-1 {
+		tiles[i], tiles[j] = tiles[j], tiles[i]
+	}
+
+	return &route{
+		goal:     tile{goal.X, goal.Y},
+		end:      closest,
+		tiles:    tiles,
+		reaches:  closest == goalTile,
+		planned:  who.Culture.game.tick,
+		searched: len(costs),
+	}
+}
+
+// routeBlocked reports whether anything has moved into the next few tiles of
+// who's route since it was planned.
+func routeBlocked(who *Character, terrain Terrain, r *route) bool {
+	for i := 0; i < len(r.tiles) && i < routeLookahead; i++ {
+		next := r.tiles[i]
+		if !isTerrainClear(who, terrain, next.x, next.y,
+			who.Type.Width, who.Type.Height) {
+			return true
+		}
+	}
+	return false
+}
+
+// nextWaypoint returns a spot a short move away from who, along a route to
+// goal. The route is cached on the character and re-planned when the goal
+// changes, when the character strays from it, when it becomes blocked, or
+// every partialRouteCooldown ticks if it doesn't reach the goal.
+func nextWaypoint(who *Character, terrain Terrain, goal Location) Location {
+	here := tile{who.Location.X, who.Location.Y}
+	r := who.route
+
+	if r != nil {
+		// Skip past any part of the route we've already walked
+		for i := 0; i < len(r.tiles) && i < maxShortMoveSide; i++ {
+			if r.tiles[i] == here {
+				r.tiles = r.tiles[i+1:]
+				break
+			}
+		}
+	}
+
+	// Characters that have finished their route stay put without
+	// re-planning, since an unreachable goal can be expensive to plan for,
+	// but only for a while: whatever was in the way may have moved. If
+	// they've managed to wander off of the end of the route, things have
+	// changed and it's worth planning again.
+	replan := r == nil ||
+		r.goal != tile{goal.X, goal.Y} ||
+		(len(r.tiles) > 0 && manhattan(here, r.tiles[0]) > 1) ||
+		(len(r.tiles) == 0 && here != r.end) ||
+		(!r.reaches && who.Culture.game.tick-r.planned >= partialRouteCooldown) ||
+		routeBlocked(who, terrain, r)
+	if replan {
+		r = planRoute(who, terrain, goal)
+		who.route = r
+	}
+
+	if len(r.tiles) == 0 {
+		// We're as close as the route can get us, so leave the rest
+		// to attemptShortMove.
+		return goal
+	}
+
+	i := routeLookahead - 1
+	if i >= len(r.tiles) {
+		return goal
+	}
+	return Location{X: r.tiles[i].x, Y: r.tiles[i].y, Offset: 0.0}
+}
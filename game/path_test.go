@@ -0,0 +1,181 @@
+package game
+
+import "testing"
+
+var wallType = &HouseType{
+	MaxResources: 1,
+	Width:        2,
+	Height:       24,
+}
+
+func buildWall(game *Game, culture *Culture, loc Location) *House {
+	wall := PlanHouse(culture, wallType, loc)
+	wall.ResourcesLeft = wall.Type.MaxResources
+	rerankHouse(game.terrain, wall)
+	return wall
+}
+
+func TestAttemptMoveAroundLongWall(t *testing.T) {
+	game := NewGame(32, 32)
+	AddCulture(game)
+	buildWall(game, game.Cultures[0], Location{10, 0, 0.0})
+
+	walker, _ := AddCharacter(
+		game.terrain,
+		game.Cultures[0],
+		workerType,
+		loc0x0,
+	)
+
+	goal := Location{20, 0, 0.0}
+	moved := attemptMove(walker, game.terrain, goal, 100)
+	if walker.Location != goal {
+		DumpTerrain(game.terrain)
+		t.Fatalf("Couldn't walk around wall, expected %v got %v",
+			goal, walker.Location)
+	}
+
+	// Down past the end of the wall and back up again
+	shortest := float64(2*(24-0) + 20)
+	if moved != shortest {
+		t.Errorf("Expected to walk %v, walked %v", shortest, moved)
+	}
+}
+
+func TestAttemptMoveAroundLongWallByTicks(t *testing.T) {
+	game := NewGame(32, 32)
+	AddCulture(game)
+	buildWall(game, game.Cultures[0], Location{10, 0, 0.0})
+
+	walker, _ := AddCharacter(
+		game.terrain,
+		game.Cultures[0],
+		workerType,
+		loc0x0,
+	)
+
+	goal := Location{20, 0, 0.0}
+	walker.Target = &goal
+	for i := 0; i < 200 && walker.Location != goal; i++ {
+		Tick(game, 0.5)
+	}
+
+	if walker.Location != goal {
+		DumpTerrain(game.terrain)
+		t.Errorf("Ticking didn't get around the wall, stuck at %v",
+			walker.Location)
+	}
+}
+
+func TestPlanRouteUnreachable(t *testing.T) {
+	game := NewGame(32, 32)
+	AddCulture(game)
+	walker, _ := AddCharacter(
+		game.terrain,
+		game.Cultures[0],
+		workerType,
+		loc0x0,
+	)
+	buildWall(game, game.Cultures[0], Location{10, 0, 0.0})
+	buildWall(game, game.Cultures[0], Location{10, 8, 0.0})
+
+	r := planRoute(walker, game.terrain, Location{20, 4, 0.0})
+	expected := tile{8, 4}
+	if r.end != expected {
+		t.Errorf("Expected route to end at %v, ended at %v", expected, r.end)
+	}
+	if len(r.tiles) != 12 {
+		t.Errorf("Expected a route of 12 steps, got %d", len(r.tiles))
+	}
+}
+
+func TestNextWaypointReplansWhenBlocked(t *testing.T) {
+	game := NewGame(32, 32)
+	AddCulture(game)
+	walker, _ := AddCharacter(
+		game.terrain,
+		game.Cultures[0],
+		workerType,
+		loc0x0,
+	)
+
+	goal := Location{20, 0, 0.0}
+	nextWaypoint(walker, game.terrain, goal)
+	before := walker.route
+
+	buildWall(game, game.Cultures[0], Location{2, 0, 0.0})
+	nextWaypoint(walker, game.terrain, goal)
+
+	if walker.route == before {
+		t.Fatalf("Route wasn't replanned after being blocked")
+	}
+	if routeBlocked(walker, game.terrain, walker.route) {
+		t.Errorf("Replanned route is still blocked")
+	}
+}
+
+func TestPartialRouteReplansOnceClear(t *testing.T) {
+	game := NewGame(32, 26)
+	culture := AddCulture(game)
+	buildWall(game, culture, Location{10, 0, 0.0})
+
+	// The only gap in the wall is crowded
+	var crowd []*Character
+	for y := 24; y < 26; y++ {
+		who, _ := AddCharacter(game.terrain, culture, walkerType, Location{10, y, 0.0})
+		crowd = append(crowd, who)
+	}
+	walker, _ := AddCharacter(game.terrain, culture, walkerType, loc0x0)
+	goal := Location{20, 0, 0.0}
+	walker.Target = &goal
+	for i := 0; i < 40; i++ {
+		Tick(game, 1.0)
+	}
+	if walker.route == nil || walker.route.reaches {
+		t.Fatalf("Expected a route that doesn't reach past the crowd, got %v", walker.route)
+	}
+
+	for i, who := range crowd {
+		who.Target = &Location{0, 20 + i, 0.0}
+	}
+	for i := 0; i < 200 && walker.Location != goal; i++ {
+		Tick(game, 1.0)
+	}
+	if walker.Location != goal {
+		DumpTerrain(game.terrain)
+		t.Errorf("Expected to get through once the crowd left, stuck at %v", walker.Location)
+	}
+}
+
+func TestPlanRouteToCoveredGoal(t *testing.T) {
+	game := NewGame(16, 16)
+	culture := AddCulture(game)
+	walker, _ := AddCharacter(game.terrain, culture, walkerType, loc0x0)
+	victim, _ := AddCharacter(game.terrain, culture, walkerType, Location{8, 8, 0.0})
+
+	r := planRoute(walker, game.terrain, victim.Location)
+	if !r.reaches || manhattan(r.end, tile{8, 8}) != 1 {
+		t.Errorf("Expected a route to next to the victim, got one to %v", r.end)
+	}
+	if r.searched > 100 {
+		t.Errorf("Expected a short search for a covered goal, searched %d tiles", r.searched)
+	}
+}
+
+func TestPlanRouteSearchIsBounded(t *testing.T) {
+	game := NewGame(256, 256)
+	culture := AddCulture(game)
+	walker, _ := AddCharacter(game.terrain, culture, walkerType, loc0x0)
+
+	// The goal is in open ground, walled off by water
+	fillTiles(game, Water, 200, 200, 20, 20)
+	fillTiles(game, Grass, 205, 205, 10, 10)
+
+	r := planRoute(walker, game.terrain, Location{210, 210, 0.0})
+	if r.reaches {
+		t.Fatalf("Expected the goal to be unreachable")
+	}
+	if r.searched > maxRouteSearch {
+		t.Errorf("Expected to search at most %d tiles, searched %d", maxRouteSearch, r.searched)
+	}
+}
@@ -0,0 +1,165 @@
+package game
+
+// maxTrainQueue is the most characters a house can have waiting to be
+// trained at once.
+const maxTrainQueue = 5
+
+// maxSpawnGap is the farthest from its house that a trained character can
+// appear. Houses with no room that close wait until some opens up.
+const maxSpawnGap = 3
+
+// TrainOrder adds a character to the named house's training queue. The house
+// must be finished, and its type must train characters. Workers stock the
+// house with resources for everything in the queue, and characters are
+// trained one at a time once their share has been delivered.
+type TrainOrder struct {
+	House string `json:"house"`
+}
+
+// Apply adds a character to the queue.
+func (o *TrainOrder) Apply(game *Game) error {
+	house, ok := lookup(game, o.House).(*House)
+	if !ok {
+		return &OrderError{RejectUnknownTarget, o.House}
+	}
+	if IsDeposit(house) || !isFinished(house) {
+		return &OrderError{RejectNotFinished, o.House}
+	}
+	if trainedType(house) == nil {
+		return &OrderError{RejectCantTrain, o.House}
+	}
+	if house.queue >= maxTrainQueue {
+		return &OrderError{RejectQueueFull, o.House}
+	}
+	house.queue++
+	return nil
+}
+
+// Issuer is the culture that owns the house.
+func (o *TrainOrder) Issuer(game *Game) (*Culture, error) {
+	house, ok := lookup(game, o.House).(*House)
+	if !ok {
+		return nil, &OrderError{RejectUnknownTarget, o.House}
+	}
+	return house.Culture, nil
+}
+
+// isFinished reports whether house is built and has all of its resources.
+// Only finished houses train characters.
+func isFinished(house *House) bool {
+	return house.Culture.BuiltHouses[house] &&
+		house.ResourcesLeft >= house.Type.MaxResources
+}
+
+// trainedType is the type of character house trains, or nil if it doesn't
+// train any.
+func trainedType(house *House) *CharacterType {
+	if house.Type.Trains == "" || house.Culture.game == nil {
+		return nil
+	}
+	return house.Culture.game.characterTypes[house.Type.Trains]
+}
+
+// stockNeeded is how many more resources house needs to pay for everything
+// in its queue that hasn't been paid for yet.
+func stockNeeded(house *House) float64 {
+	if !isFinished(house) {
+		return 0
+	}
+	unpaid := house.queue
+	if house.training {
+		unpaid--
+	}
+	needed := float64(unpaid)*house.Type.TrainCost - house.stock
+	if needed < 0 {
+		return 0
+	}
+	return needed
+}
+
+// resourcesNeeded is how many more resources house needs, either to be
+// finished or, once it is, to train the characters in its queue.
+func resourcesNeeded(house *House) float64 {
+	if isFinished(house) {
+		return stockNeeded(house)
+	}
+	return house.Type.MaxResources - house.ResourcesLeft
+}
+
+// stockHouse moves resources from who into the stock house keeps for training.
+func stockHouse(who *Character, house *House, dt float64) {
+	transfer := who.Type.WorkPerTick * dt
+	needed := stockNeeded(house)
+	if transfer > needed {
+		transfer = needed
+	}
+	if transfer > who.Carrying {
+		transfer = who.Carrying
+	}
+	house.stock = house.stock + transfer
+	who.Carrying = who.Carrying - transfer
+}
+
+// spawnCharacter puts a newly trained character of the given type on the
+// nearest clear tile around house, searching the tiles at each distance top
+// to bottom and left to right. It returns nil if there's no room.
+func spawnCharacter(game *Game, house *House, ctype *CharacterType) *Character {
+	hx, hy := house.Location.X, house.Location.Y
+	hWidth, hHeight := house.Type.Width, house.Type.Height
+	for want := 0; want <= maxSpawnGap; want++ {
+		for y := hy - want - ctype.Height; y <= hy+hHeight+want; y++ {
+			for x := hx - want - ctype.Width; x <= hx+hWidth+want; x++ {
+				spot := footprintGap(x, y, ctype.Width, ctype.Height,
+					hx, hy, hWidth, hHeight)
+				if spot != want ||
+					!isTerrainClear(nil, game.terrain, x, y, ctype.Width, ctype.Height) {
+					continue
+				}
+				who, err := AddCharacter(game.terrain, house.Culture, ctype,
+					Location{X: x, Y: y})
+				if err == nil {
+					return who
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// trainCharacters advances training in every finished house with a queue.
+// A house starts on the first character in its queue once it has been
+// stocked with enough to pay for it, and the character appears next to the
+// house when its training time is up.
+func trainCharacters(game *Game, dt float64) {
+	for _, culture := range game.Cultures {
+		for _, house := range sortedHouses(culture.BuiltHouses) {
+			ctype := trainedType(house)
+			if house.queue == 0 || ctype == nil || !isFinished(house) {
+				continue
+			}
+
+			if !house.training {
+				if house.stock < house.Type.TrainCost {
+					continue
+				}
+				house.stock = house.stock - house.Type.TrainCost
+				house.training = true
+				house.progress = 0
+			}
+
+			house.progress = house.progress + dt
+			if house.progress < house.Type.TrainTime {
+				continue
+			}
+			house.progress = house.Type.TrainTime
+			trained := spawnCharacter(game, house, ctype)
+			if trained == nil {
+				continue // Try again when there's room
+			}
+			emitCharacterEvent(trained, EventCharacterTrained, house.Name)
+			house.queue--
+			house.training = false
+			house.progress = 0
+		}
+	}
+}
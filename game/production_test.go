@@ -0,0 +1,178 @@
+package game
+
+import "testing"
+
+var campType = &HouseType{
+	MaxResources: 10,
+	Width:        2,
+	Height:       2,
+	Trains:       "worker",
+	TrainCost:    20,
+	TrainTime:    3,
+}
+
+// addCamp adds an already built camp that trains workers for culture at loc.
+func addCamp(game *Game, culture *Culture, loc Location) *House {
+	if _, ok := CharacterTypeNamed(game, "worker"); !ok {
+		AddCharacterType(game, "worker", workerType)
+	}
+	camp := PlanHouse(culture, campType, loc)
+	camp.ResourcesLeft = campType.MaxResources
+	rerankHouse(game.terrain, camp)
+	return camp
+}
+
+func TestTrainOrder(t *testing.T) {
+	game := NewGame(16, 16)
+	culture := AddCulture(game)
+	camp := addCamp(game, culture, loc0x0)
+	plan := PlanHouse(culture, campType, Location{4, 0, 0.0})
+	depot := addDepot(game, culture, Location{8, 0, 0.0})
+	deposit, _ := AddDeposit(game, depositType, Location{12, 0, 0.0}, 0)
+
+	rejected := []struct {
+		house  string
+		reason string
+	}{
+		{"nowhere", RejectUnknownTarget},
+		{plan.Name, RejectNotFinished},
+		{deposit.Name, RejectNotFinished},
+		{depot.Name, RejectCantTrain},
+	}
+	for _, r := range rejected {
+		err, ok := (&TrainOrder{r.house}).Apply(game).(*OrderError)
+		if !ok || err.Reason != r.reason {
+			t.Errorf("Expected training at %q to be rejected with %q, got %v",
+				r.house, r.reason, err)
+		}
+	}
+
+	for i := 0; i < maxTrainQueue; i++ {
+		if err := (&TrainOrder{camp.Name}).Apply(game); err != nil {
+			t.Fatalf("Unexpected error training: %v", err)
+		}
+	}
+	err, ok := (&TrainOrder{camp.Name}).Apply(game).(*OrderError)
+	if !ok || err.Reason != RejectQueueFull {
+		t.Errorf("Expected a full queue to be rejected, got %v", err)
+	}
+
+	status := ReadStatus(game).Cultures[0].BuiltHouses
+	for _, house := range status {
+		if house.Name == camp.Name && (house.Queue != maxTrainQueue || house.Trains != "worker") {
+			t.Errorf("Expected status to show the queue, got %v", house)
+		}
+	}
+	if stockNeeded(camp) != maxTrainQueue*campType.TrainCost {
+		t.Errorf("Expected the camp to need stock for the whole queue, needs %v",
+			stockNeeded(camp))
+	}
+}
+
+func TestTrainCharacters(t *testing.T) {
+	for _, scheduling := range []Scheduling{InOrderScheduling, FairScheduling} {
+		game := NewGame(16, 16)
+		SetScheduling(game, scheduling, 1)
+		culture := AddCulture(game)
+		camp := addCamp(game, culture, Location{6, 6, 0.0})
+		camp.queue = 2
+		who, _ := AddCharacter(game.terrain, culture, workerType, loc0x0)
+
+		for i := 0; i < 10; i++ {
+			Tick(game, 1.0)
+		}
+		if len(culture.Characters) != 1 || camp.training {
+			t.Fatalf("Expected the camp to wait to be stocked")
+		}
+
+		who.Carrying = 50
+		who.Target = camp
+		for i := 0; i < 40 && len(culture.Characters) < 3; i++ {
+			Tick(game, 1.0)
+		}
+
+		if len(culture.Characters) != 3 || camp.queue != 0 {
+			t.Fatalf("Expected two workers to be trained, have %d characters and %d queued",
+				len(culture.Characters), camp.queue)
+		}
+		if who.Carrying != 10 || camp.stock != 0 || who.Target != nil {
+			t.Errorf("Expected the camp to take only what it needed, worker has %v, camp %v",
+				who.Carrying, camp.stock)
+		}
+		for _, trained := range culture.Characters[1:] {
+			if trained.Type != workerType {
+				t.Errorf("Expected a worker, got %v", trained.Type)
+			}
+			gap := footprintGap(trained.Location.X, trained.Location.Y, 2, 2,
+				camp.Location.X, camp.Location.Y, 2, 2)
+			if gap != 0 {
+				t.Errorf("Expected trained worker next to the camp, at %v", trained.Location)
+			}
+		}
+	}
+}
+
+func TestTrainingWaitsForRoom(t *testing.T) {
+	// There's nowhere around the camp for a worker to fit.
+	game := NewGame(4, 4)
+	culture := AddCulture(game)
+	camp := addCamp(game, culture, loc1x1)
+	camp.queue = 1
+	camp.stock = campType.TrainCost
+
+	for i := 0; i < 5; i++ {
+		Tick(game, 1.0)
+	}
+	if len(culture.Characters) != 0 || camp.queue != 1 {
+		t.Errorf("Expected no room for a trained worker")
+	}
+	if !camp.training || camp.stock != 0 || camp.progress != campType.TrainTime {
+		t.Errorf("Expected the worker to wait, finished, for room")
+	}
+}
+
+func TestAutoJobsStockTrainers(t *testing.T) {
+	for _, scheduling := range []Scheduling{InOrderScheduling, FairScheduling} {
+		game := NewGame(16, 16)
+		SetScheduling(game, scheduling, 1)
+		culture := AddCulture(game)
+		culture.AutoJobs = true
+		culture.Resources = campType.TrainCost
+		addDepot(game, culture, Location{0, 8, 0.0})
+		camp := addCamp(game, culture, Location{8, 8, 0.0})
+		camp.queue = 1
+		AddCharacter(game.terrain, culture, workerType, loc0x0)
+
+		for i := 0; i < 100 && len(culture.Characters) < 2; i++ {
+			Tick(game, 1.0)
+		}
+		if len(culture.Characters) != 2 || culture.Resources != 0 {
+			t.Errorf("Expected the bank to pay for a worker, has %v left",
+				culture.Resources)
+		}
+	}
+}
+
+func TestSaveKeepsTraining(t *testing.T) {
+	game := NewGame(16, 16)
+	culture := AddCulture(game)
+	camp := addCamp(game, culture, loc3x3)
+	camp.queue = 2
+	camp.stock = 5
+	camp.training = true
+	camp.progress = 1.5
+
+	loaded := saveAndLoad(t, game)
+	houses := sortedHouses(loaded.Cultures[0].BuiltHouses)
+	if len(houses) != 1 {
+		t.Fatalf("Expected the camp to be loaded, got %v", houses)
+	}
+	loadedCamp := houses[0]
+	if loadedCamp.queue != 2 || loadedCamp.stock != 5 ||
+		!loadedCamp.training || loadedCamp.progress != 1.5 {
+		t.Errorf("Expected training to be saved, got %v", loadedCamp)
+	}
+	if *loadedCamp.Type != *campType || trainedType(loadedCamp) == nil {
+		t.Errorf("Expected the camp to still train workers, got %v", loadedCamp.Type)
+	}
+}
@@ -0,0 +1,798 @@
+package game
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// saveVersion is the version of the documents written by Save. When the
+// format changes, bump saveVersion and add a migration from the old version.
+const saveVersion = 2
+
+// migration upgrades a decoded save document from one version to the next.
+type migration func(doc map[string]interface{}) (map[string]interface{}, error)
+
+// migrations maps a save version to the migration that upgrades documents of
+// that version to the following version. Load applies them in sequence until
+// a document reaches saveVersion.
+var migrations = map[int]migration{
+	1: migratePileType,
+}
+
+// migratePileType finds the type of the piles dropped by dead characters in
+// documents saved before it was recorded. Piles were the only deposits with
+// an uncatalogued 1x1 type and no resources of their own.
+func migratePileType(doc map[string]interface{}) (map[string]interface{}, error) {
+	houseTypes, _ := doc["houseTypes"].([]interface{})
+	for _, t := range houseTypes {
+		houseType, ok := t.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("house type isn't an object")
+		}
+		if houseType["catalog"] == true || houseType["maxResources"] != float64(0) ||
+			houseType["width"] != float64(1) || houseType["height"] != float64(1) {
+			continue
+		}
+		doc["pileType"] = houseType["key"]
+		break
+	}
+	return doc, nil
+}
+
+// savedCharacterType is a CharacterType. Types that were added to the game's
+// catalog with AddCharacterType are keyed by their catalog name.
+type savedCharacterType struct {
+	Key         string  `json:"key"`
+	Catalog     bool    `json:"catalog"`
+	MovePerTick float64 `json:"movePerTick"`
+	WorkPerTick float64 `json:"workPerTick"`
+	MaxCarry    float64 `json:"maxCarry"`
+	Width       int     `json:"width"`
+	Height      int     `json:"height"`
+	Sight       int     `json:"sight"`
+
+	Health         float64 `json:"health,omitempty"`
+	AttackDamage   float64 `json:"attackDamage,omitempty"`
+	AttackRange    int     `json:"attackRange,omitempty"`
+	AttackCooldown float64 `json:"attackCooldown,omitempty"`
+}
+
+// savedHouseType is a HouseType. Types that were added to the game's catalog
+// with AddHouseType are keyed by their catalog name.
+type savedHouseType struct {
+	Key          string  `json:"key"`
+	Catalog      bool    `json:"catalog"`
+	MaxResources float64 `json:"maxResources"`
+	Width        int     `json:"width"`
+	Height       int     `json:"height"`
+	Sight        int     `json:"sight"`
+	Depot        bool    `json:"depot,omitempty"`
+	Trains       string  `json:"trains,omitempty"`
+	TrainCost    float64 `json:"trainCost,omitempty"`
+	TrainTime    float64 `json:"trainTime,omitempty"`
+}
+
+// savedRoute is a route as a list of [x, y] pairs.
+type savedRoute struct {
+	Goal  [2]int   `json:"goal"`
+	End   [2]int   `json:"end"`
+	Tiles [][2]int `json:"tiles"`
+}
+
+// savedCharacter refers to its type by key, and its target by name.
+// Characters marching to a location have a Destination instead.
+type savedCharacter struct {
+	Name        string      `json:"name"`
+	Type        string      `json:"type"`
+	Location    Location    `json:"location"`
+	Carrying    float64     `json:"carrying"`
+	Target      string      `json:"target,omitempty"`
+	Destination *Location   `json:"destination,omitempty"`
+	Work        string      `json:"work,omitempty"`
+	Route       *savedRoute `json:"route,omitempty"`
+	Victim      string      `json:"victim,omitempty"`
+	Health      float64     `json:"health,omitempty"`
+	Cooldown    float64     `json:"cooldown,omitempty"`
+	Blocked     bool        `json:"blocked,omitempty"`
+}
+
+type savedHouse struct {
+	Name          string   `json:"name"`
+	Type          string   `json:"type"`
+	Location      Location `json:"location"`
+	ResourcesLeft float64  `json:"resourcesLeft"`
+	Priority      int      `json:"priority,omitempty"`
+	PlanSeq       int      `json:"planSeq"`
+	Queue         int      `json:"queue,omitempty"`
+	Stock         float64  `json:"stock,omitempty"`
+	Training      bool     `json:"training,omitempty"`
+	Progress      float64  `json:"progress,omitempty"`
+}
+
+// savedGoneHouse is a house that is no longer in the game, but that
+// characters still target or work on. They'll give up on it on their next
+// Tick, just as they would have if the game had never been saved.
+type savedGoneHouse struct {
+	Culture string `json:"culture"`
+	savedHouse
+}
+
+type savedMemory struct {
+	Owner  string      `json:"owner"`
+	Status HouseStatus `json:"status"`
+}
+
+type savedCulture struct {
+	Name          string           `json:"name"`
+	PlanCount     int              `json:"planCount"`
+	Resources     float64          `json:"resources"`
+	AutoJobs      bool             `json:"autoJobs,omitempty"`
+	Characters    []savedCharacter `json:"characters"`
+	PlannedHouses []savedHouse     `json:"plannedHouses"`
+	BuiltHouses   []savedHouse     `json:"builtHouses"`
+	Memory        []savedMemory    `json:"memory,omitempty"`
+	HasBuilt      bool             `json:"hasBuilt,omitempty"`
+}
+
+type savedWaypoint struct {
+	Name     string   `json:"name"`
+	Location Location `json:"location"`
+}
+
+// savedGame is the document written by Save. Everything a Game refers to by
+// pointer is written out once and referred to by name or key.
+type savedGame struct {
+	Version        int                  `json:"version"`
+	Width          int                  `json:"width"`
+	Height         int                  `json:"height"`
+	Tiles          []string             `json:"tiles,omitempty"`
+	Tick           int                  `json:"tick"`
+	Scheduling     Scheduling           `json:"scheduling"`
+	Seed           int64                `json:"seed"`
+	NameCount      int                  `json:"nameCount"`
+	CharacterTypes []savedCharacterType `json:"characterTypes"`
+	HouseTypes     []savedHouseType     `json:"houseTypes"`
+	PileType       string               `json:"pileType,omitempty"`
+	Cultures       []savedCulture       `json:"cultures"`
+	Deposits       []savedHouse         `json:"deposits,omitempty"`
+	Waypoints      []savedWaypoint      `json:"waypoints,omitempty"`
+	Gone           []savedGoneHouse     `json:"gone,omitempty"`
+	Phase          Phase                `json:"phase,omitempty"`
+	Started        int                  `json:"started,omitempty"`
+	Result         *GameResult          `json:"result,omitempty"`
+	WinConditions  []savedWinCondition  `json:"winConditions,omitempty"`
+}
+
+// savedWinCondition is one of the win conditions in this package. Kind says
+// which one, and only the fields it uses are set.
+type savedWinCondition struct {
+	Kind      string  `json:"kind"`
+	Resources float64 `json:"resources,omitempty"`
+	Ticks     int     `json:"ticks,omitempty"`
+}
+
+const (
+	winLastStanding   = "lastStanding"
+	winResourceTarget = "resourceTarget"
+	winTimeLimit      = "timeLimit"
+)
+
+func saveWinCondition(condition WinCondition) (savedWinCondition, error) {
+	switch c := condition.(type) {
+	case LastCultureStanding:
+		return savedWinCondition{Kind: winLastStanding}, nil
+	case ResourceTarget:
+		return savedWinCondition{Kind: winResourceTarget, Resources: c.Resources}, nil
+	case TimeLimit:
+		return savedWinCondition{Kind: winTimeLimit, Ticks: c.Ticks}, nil
+	}
+	return savedWinCondition{}, fmt.Errorf("can't save win condition %T", condition)
+}
+
+func loadWinCondition(s savedWinCondition) (WinCondition, error) {
+	switch s.Kind {
+	case winLastStanding:
+		return LastCultureStanding{}, nil
+	case winResourceTarget:
+		return ResourceTarget{Resources: s.Resources}, nil
+	case winTimeLimit:
+		return TimeLimit{Ticks: s.Ticks}, nil
+	}
+	return nil, fmt.Errorf("unknown win condition %q", s.Kind)
+}
+
+// sortedHouses orders houses by when they were planned, so that saves of the
+// same game are always identical.
+func sortedHouses(houses map[*House]bool) []*House {
+	ret := make([]*House, 0, len(houses))
+	for house := range houses {
+		ret = append(ret, house)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].planSeq == ret[j].planSeq {
+			return ret[i].Name < ret[j].Name
+		}
+		return ret[i].planSeq < ret[j].planSeq
+	})
+	return ret
+}
+
+func saveRoute(r *route) *savedRoute {
+	if r == nil {
+		return nil
+	}
+	ret := &savedRoute{
+		Goal:  [2]int{r.goal.x, r.goal.y},
+		End:   [2]int{r.end.x, r.end.y},
+		Tiles: make([][2]int, len(r.tiles)),
+	}
+	for i, t := range r.tiles {
+		ret.Tiles[i] = [2]int{t.x, t.y}
+	}
+	return ret
+}
+
+func loadRoute(s *savedRoute) *route {
+	if s == nil {
+		return nil
+	}
+	ret := &route{
+		goal:  tile{s.Goal[0], s.Goal[1]},
+		end:   tile{s.End[0], s.End[1]},
+		tiles: make([]tile, len(s.Tiles)),
+	}
+	for i, t := range s.Tiles {
+		ret.tiles[i] = tile{t[0], t[1]}
+	}
+	return ret
+}
+
+// Save writes everything needed to resume game to w, including the routes
+// characters are following, so a loaded game plays out exactly as the
+// original would have. The order journal isn't saved, so the Journal of a
+// loaded game starts out empty, and games with win conditions from outside of
+// this package can't be saved at all.
+func Save(game *Game, w io.Writer) error {
+	doc := savedGame{
+		Version:    saveVersion,
+		Width:      game.terrain.Width,
+		Height:     game.terrain.Height,
+		Tiles:      tileRows(game.terrain),
+		Tick:       game.tick,
+		Scheduling: game.scheduling,
+		Seed:       game.seed,
+		NameCount:  game.nameCount,
+		Phase:      game.phase,
+		Started:    game.started,
+		Result:     FinalResult(game),
+	}
+
+	for _, condition := range game.winConditions {
+		saved, err := saveWinCondition(condition)
+		if err != nil {
+			return err
+		}
+		doc.WinConditions = append(doc.WinConditions, saved)
+	}
+
+	characterTypes := make(map[*CharacterType]string)
+	addCharacterType := func(key string, catalog bool, t *CharacterType) {
+		if _, ok := characterTypes[t]; !ok {
+			// Types in the catalog under several names are
+			// saved under the first, like their statuses say
+			characterTypes[t] = key
+		}
+		doc.CharacterTypes = append(doc.CharacterTypes, savedCharacterType{
+			Key:         key,
+			Catalog:     catalog,
+			MovePerTick: t.MovePerTick,
+			WorkPerTick: t.WorkPerTick,
+			MaxCarry:    t.MaxCarry,
+			Width:       t.Width,
+			Height:      t.Height,
+			Sight:       t.Sight,
+
+			Health:         t.Health,
+			AttackDamage:   t.AttackDamage,
+			AttackRange:    t.AttackRange,
+			AttackCooldown: t.AttackCooldown,
+		})
+	}
+	for _, name := range sortedKeys(game.characterTypes) {
+		addCharacterType(name, true, game.characterTypes[name])
+	}
+	characterTypeKey := func(t *CharacterType) string {
+		if key, ok := characterTypes[t]; ok {
+			return key
+		}
+		addCharacterType(fmt.Sprintf("character-type-%d", len(characterTypes)), false, t)
+		return characterTypes[t]
+	}
+
+	houseTypes := make(map[*HouseType]string)
+	addHouseType := func(key string, catalog bool, t *HouseType) {
+		if _, ok := houseTypes[t]; !ok {
+			// Types in the catalog under several names are
+			// saved under the first, like their statuses say
+			houseTypes[t] = key
+		}
+		doc.HouseTypes = append(doc.HouseTypes, savedHouseType{
+			Key:          key,
+			Catalog:      catalog,
+			MaxResources: t.MaxResources,
+			Width:        t.Width,
+			Height:       t.Height,
+			Sight:        t.Sight,
+			Depot:        t.Depot,
+			Trains:       t.Trains,
+			TrainCost:    t.TrainCost,
+			TrainTime:    t.TrainTime,
+		})
+	}
+	for _, name := range sortedKeys(game.houseTypes) {
+		addHouseType(name, true, game.houseTypes[name])
+	}
+	houseTypeKey := func(t *HouseType) string {
+		if key, ok := houseTypes[t]; ok {
+			return key
+		}
+		addHouseType(fmt.Sprintf("house-type-%d", len(houseTypes)), false, t)
+		return houseTypes[t]
+	}
+	if game.pileType != nil {
+		doc.PileType = houseTypeKey(game.pileType)
+	}
+
+	saveHouse := func(house *House) savedHouse {
+		return savedHouse{
+			Name:          house.Name,
+			Type:          houseTypeKey(house.Type),
+			Location:      house.Location,
+			ResourcesLeft: house.ResourcesLeft,
+			Priority:      house.Priority,
+			PlanSeq:       house.planSeq,
+			Queue:         house.queue,
+			Stock:         house.stock,
+			Training:      house.training,
+			Progress:      house.progress,
+		}
+	}
+	saveHouses := func(houses map[*House]bool) []savedHouse {
+		ret := make([]savedHouse, 0, len(houses))
+		for _, house := range sortedHouses(houses) {
+			ret = append(ret, saveHouse(house))
+		}
+		return ret
+	}
+	gone := make(map[*House]bool)
+	saveGone := func(house *House) {
+		if lookup(game, house.Name) == house || gone[house] {
+			return
+		}
+		gone[house] = true
+		doc.Gone = append(doc.Gone, savedGoneHouse{house.Culture.Name, saveHouse(house)})
+	}
+
+	for _, culture := range game.Cultures {
+		saved := savedCulture{
+			Name:          culture.Name,
+			PlanCount:     culture.planCount,
+			Resources:     culture.Resources,
+			AutoJobs:      culture.AutoJobs,
+			Characters:    make([]savedCharacter, 0, len(culture.Characters)),
+			PlannedHouses: saveHouses(culture.PlannedHouses),
+			BuiltHouses:   saveHouses(culture.BuiltHouses),
+			HasBuilt:      culture.hasBuilt,
+		}
+
+		for _, who := range culture.Characters {
+			character := savedCharacter{
+				Name:     who.Name,
+				Type:     characterTypeKey(who.Type),
+				Location: who.Location,
+				Carrying: who.Carrying,
+				Route:    saveRoute(who.route),
+				Health:   who.Health,
+				Cooldown: who.cooldown,
+				Blocked:  who.blocked,
+			}
+			switch target := who.Target.(type) {
+			case *House:
+				character.Target = target.Name
+				saveGone(target)
+			case *Location:
+				destination := *target
+				character.Destination = &destination
+			case *Character:
+				character.Victim = target.Name
+			}
+			if who.work != nil {
+				character.Work = who.work.Name
+				saveGone(who.work)
+			}
+			saved.Characters = append(saved.Characters, character)
+		}
+
+		memoryNames := make([]string, 0, len(culture.memory))
+		for name := range culture.memory {
+			memoryNames = append(memoryNames, name)
+		}
+		sort.Strings(memoryNames)
+		for _, name := range memoryNames {
+			remembered := culture.memory[name]
+			saved.Memory = append(saved.Memory, savedMemory{
+				Owner:  remembered.owner,
+				Status: remembered.status,
+			})
+		}
+
+		doc.Cultures = append(doc.Cultures, saved)
+	}
+
+	if game.neutral != nil {
+		doc.Deposits = saveHouses(game.neutral.BuiltHouses)
+	}
+
+	for name, thing := range game.names {
+		if loc, ok := thing.(*Location); ok {
+			doc.Waypoints = append(doc.Waypoints, savedWaypoint{name, *loc})
+		}
+	}
+	sort.Slice(doc.Waypoints, func(i, j int) bool {
+		return doc.Waypoints[i].Name < doc.Waypoints[j].Name
+	})
+
+	return json.NewEncoder(w).Encode(doc)
+}
+
+// migrate brings a decoded save document up to saveVersion.
+func migrate(doc map[string]interface{}) (map[string]interface{}, error) {
+	version, ok := doc["version"].(float64)
+	if !ok {
+		return nil, fmt.Errorf("saved game has no version")
+	}
+
+	for v := int(version); v != saveVersion; v++ {
+		if v > saveVersion {
+			return nil, fmt.Errorf("saved game version %d is newer than %d", v, saveVersion)
+		}
+		upgrade, ok := migrations[v]
+		if !ok {
+			return nil, fmt.Errorf("can't migrate saved game from version %d", v)
+		}
+		var err error
+		if doc, err = upgrade(doc); err != nil {
+			return nil, fmt.Errorf("migrating saved game from version %d: %v", v, err)
+		}
+		doc["version"] = float64(v + 1)
+	}
+
+	return doc, nil
+}
+
+func registerUnique(game *Game, name string, thing interface{}) error {
+	if lookup(game, name) != nil {
+		return fmt.Errorf("saved game uses the name %q twice", name)
+	}
+	register(game, name, thing)
+	return nil
+}
+
+func loadHouses(game *Game, culture *Culture, saved []savedHouse,
+	houseTypes map[string]*HouseType, into map[*House]bool) error {
+	for _, s := range saved {
+		house, err := loadHouse(culture, s, houseTypes)
+		if err != nil {
+			return err
+		}
+		if err := registerUnique(game, house.Name, house); err != nil {
+			return err
+		}
+		into[house] = true
+	}
+	return nil
+}
+
+// loadHouse rebuilds a single house for culture, without adding it to the
+// game.
+func loadHouse(culture *Culture, s savedHouse, houseTypes map[string]*HouseType) (*House, error) {
+	houseType, ok := houseTypes[s.Type]
+	if !ok {
+		return nil, fmt.Errorf("house %q has unknown type %q", s.Name, s.Type)
+	}
+	return &House{
+		Type:          houseType,
+		Culture:       culture,
+		Location:      s.Location,
+		ResourcesLeft: s.ResourcesLeft,
+		Name:          s.Name,
+		Priority:      s.Priority,
+		planSeq:       s.PlanSeq,
+		queue:         s.Queue,
+		stock:         s.Stock,
+		training:      s.Training,
+		progress:      s.Progress,
+	}, nil
+}
+
+// occupy puts a loaded house on the board.
+func occupy(game *Game, house *House) error {
+	if !isTerrainClear(nil, game.terrain, house.Location.X, house.Location.Y,
+		house.Type.Width, house.Type.Height) {
+		return fmt.Errorf("house %q overlaps something", house.Name)
+	}
+	fillFootprint(game.terrain, house.Location.X, house.Location.Y,
+		house.Type.Width, house.Type.Height, house)
+	return nil
+}
+
+// Load reads a game written by Save, migrating older versions of the format
+// as needed. Terrain occupancy and references between characters and houses
+// are rebuilt from the saved names.
+func Load(r io.Reader) (*Game, error) {
+	var raw map[string]interface{}
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("can't read saved game: %v", err)
+	}
+	raw, err := migrate(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	// Round trip through JSON to get from the generic document that
+	// migrations work on to the current, typed version.
+	encoded, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	var doc savedGame
+	if err := json.Unmarshal(encoded, &doc); err != nil {
+		return nil, fmt.Errorf("can't read saved game: %v", err)
+	}
+
+	if doc.Width <= 0 || doc.Height <= 0 {
+		return nil, fmt.Errorf("saved game has bad size %dx%d", doc.Width, doc.Height)
+	}
+	game := NewGame(doc.Width, doc.Height)
+	game.tick = doc.Tick
+	game.nameCount = doc.NameCount
+	if doc.Tiles != nil {
+		if err := setTileRows(game.terrain, doc.Tiles); err != nil {
+			return nil, fmt.Errorf("saved game has bad tiles: %v", err)
+		}
+	}
+	SetScheduling(game, doc.Scheduling, doc.Seed)
+
+	game.phase = doc.Phase
+	if game.phase == "" {
+		// Saved before games had phases
+		game.phase = PhaseLobby
+		if game.tick > 0 {
+			game.phase = PhaseRunning
+		}
+	}
+	game.started = doc.Started
+	game.result = doc.Result
+	for _, s := range doc.WinConditions {
+		condition, err := loadWinCondition(s)
+		if err != nil {
+			return nil, err
+		}
+		AddWinCondition(game, condition)
+	}
+
+	characterTypes := make(map[string]*CharacterType)
+	for _, s := range doc.CharacterTypes {
+		characterType := &CharacterType{
+			MovePerTick: s.MovePerTick,
+			WorkPerTick: s.WorkPerTick,
+			MaxCarry:    s.MaxCarry,
+			Width:       s.Width,
+			Height:      s.Height,
+			Sight:       s.Sight,
+
+			Health:         s.Health,
+			AttackDamage:   s.AttackDamage,
+			AttackRange:    s.AttackRange,
+			AttackCooldown: s.AttackCooldown,
+		}
+		characterTypes[s.Key] = characterType
+		if s.Catalog {
+			if err := AddCharacterType(game, s.Key, characterType); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	houseTypes := make(map[string]*HouseType)
+	for _, s := range doc.HouseTypes {
+		houseType := &HouseType{
+			MaxResources: s.MaxResources,
+			Width:        s.Width,
+			Height:       s.Height,
+			Sight:        s.Sight,
+			Depot:        s.Depot,
+			Trains:       s.Trains,
+			TrainCost:    s.TrainCost,
+			TrainTime:    s.TrainTime,
+		}
+		houseTypes[s.Key] = houseType
+		if s.Catalog {
+			if err := AddHouseType(game, s.Key, houseType); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if doc.PileType != "" {
+		houseType, ok := houseTypes[doc.PileType]
+		if !ok {
+			return nil, fmt.Errorf("piles have unknown type %q", doc.PileType)
+		}
+		game.pileType = houseType
+	}
+
+	targets := make(map[*Character]string)
+	works := make(map[*Character]string)
+	victims := make(map[*Character]string)
+	for _, saved := range doc.Cultures {
+		culture := &Culture{
+			PlannedHouses: make(map[*House]bool),
+			BuiltHouses:   make(map[*House]bool),
+			Name:          saved.Name,
+			game:          game,
+			planCount:     saved.PlanCount,
+			Resources:     saved.Resources,
+			AutoJobs:      saved.AutoJobs,
+			memory:        make(map[string]rememberedHouse),
+		}
+		if err := registerUnique(game, culture.Name, culture); err != nil {
+			return nil, err
+		}
+		game.Cultures = append(game.Cultures, culture)
+
+		err := loadHouses(game, culture, saved.PlannedHouses, houseTypes,
+			culture.PlannedHouses)
+		if err != nil {
+			return nil, err
+		}
+		err = loadHouses(game, culture, saved.BuiltHouses, houseTypes,
+			culture.BuiltHouses)
+		if err != nil {
+			return nil, err
+		}
+		culture.hasBuilt = saved.HasBuilt || len(culture.BuiltHouses) > 0
+		for house := range culture.BuiltHouses {
+			if err := occupy(game, house); err != nil {
+				return nil, err
+			}
+		}
+
+		for _, s := range saved.Characters {
+			characterType, ok := characterTypes[s.Type]
+			if !ok {
+				return nil, fmt.Errorf("character %q has unknown type %q", s.Name, s.Type)
+			}
+			who := &Character{
+				Carrying: s.Carrying,
+				Culture:  culture,
+				Location: s.Location,
+				Type:     characterType,
+				Name:     s.Name,
+				Health:   s.Health,
+				route:    loadRoute(s.Route),
+				cooldown: s.Cooldown,
+				blocked:  s.Blocked,
+			}
+			if who.Health <= 0 {
+				// Saved before characters had health
+				who.Health = healthOf(characterType)
+			}
+			if !isTerrainClear(nil, game.terrain, who.Location.X, who.Location.Y,
+				characterType.Width, characterType.Height) {
+				return nil, fmt.Errorf("character %q overlaps something", who.Name)
+			}
+			fillFootprint(game.terrain, who.Location.X, who.Location.Y,
+				characterType.Width, characterType.Height, who)
+			if err := registerUnique(game, who.Name, who); err != nil {
+				return nil, err
+			}
+
+			if s.Destination != nil {
+				destination := *s.Destination
+				who.Target = &destination
+			}
+			if s.Target != "" {
+				targets[who] = s.Target
+			}
+			if s.Work != "" {
+				works[who] = s.Work
+			}
+			if s.Victim != "" {
+				victims[who] = s.Victim
+			}
+			culture.Characters = append(culture.Characters, who)
+		}
+
+		for _, m := range saved.Memory {
+			culture.memory[m.Status.Name] = rememberedHouse{m.Owner, m.Status}
+		}
+	}
+
+	if len(doc.Deposits) > 0 {
+		neutral := neutralCulture(game)
+		err := loadHouses(game, neutral, doc.Deposits, houseTypes, neutral.BuiltHouses)
+		if err != nil {
+			return nil, err
+		}
+		for deposit := range neutral.BuiltHouses {
+			if deposit.planSeq > neutral.planCount {
+				neutral.planCount = deposit.planSeq
+			}
+			if err := occupy(game, deposit); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	gone := make(map[string]*House)
+	for _, s := range doc.Gone {
+		var owner *Culture
+		if s.Culture == NeutralCulture {
+			owner = neutralCulture(game)
+		}
+		for _, culture := range game.Cultures {
+			if culture.Name == s.Culture {
+				owner = culture
+			}
+		}
+		if owner == nil {
+			return nil, fmt.Errorf("house %q belongs to unknown culture %q", s.Name, s.Culture)
+		}
+		house, err := loadHouse(owner, s.savedHouse, houseTypes)
+		if err != nil {
+			return nil, err
+		}
+		gone[house.Name] = house
+	}
+	findHouse := func(name string) (*House, bool) {
+		if house, ok := gone[name]; ok {
+			return house, true
+		}
+		house, ok := lookup(game, name).(*House)
+		return house, ok
+	}
+
+	for who, name := range targets {
+		house, ok := findHouse(name)
+		if !ok {
+			return nil, fmt.Errorf("character %q targets unknown house %q", who.Name, name)
+		}
+		who.Target = house
+	}
+	for who, name := range works {
+		house, ok := findHouse(name)
+		if !ok {
+			return nil, fmt.Errorf("character %q works on unknown house %q", who.Name, name)
+		}
+		who.work = house
+	}
+
+	for who, name := range victims {
+		victim, ok := lookup(game, name).(*Character)
+		if !ok {
+			return nil, fmt.Errorf("character %q attacks unknown character %q", who.Name, name)
+		}
+		who.Target = victim
+	}
+
+	for _, waypoint := range doc.Waypoints {
+		loc := waypoint.Location
+		if err := registerUnique(game, waypoint.Name, &loc); err != nil {
+			return nil, err
+		}
+	}
+
+	return game, nil
+}
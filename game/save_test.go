@@ -0,0 +1,236 @@
+package game
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func saveAndLoad(t *testing.T, game *Game) *Game {
+	var buf bytes.Buffer
+	if err := Save(game, &buf); err != nil {
+		t.Fatalf("Unexpected error saving game: %v", err)
+	}
+	loaded, err := Load(&buf)
+	if err != nil {
+		t.Fatalf("Unexpected error loading game: %v", err)
+	}
+	return loaded
+}
+
+func occupantName(thing interface{}) string {
+	switch thing := thing.(type) {
+	case *Character:
+		return thing.Name
+	case *House:
+		return thing.Name
+	}
+	return ""
+}
+
+func buildSaveGame() *Game {
+	game := NewGame(16, 16)
+	red := AddCulture(game)
+	green := AddCulture(game)
+	AddHouseType(game, "house", houseType)
+	fillTiles(game, Rock, 6, 0, 1, 3)
+	fillTiles(game, Road, 0, 14, 16, 1)
+
+	builder, _ := AddCharacter(game.terrain, red, workerType, loc0x0)
+	miner, _ := AddCharacter(game.terrain, green, workerType, Location{8, 8, 0.5})
+	marcher, _ := AddCharacter(game.terrain, green, workerType, Location{10, 2, 0.0})
+	planned := PlanHouse(red, houseType, Location{4, 4, 0.0})
+	built := PlanHouse(red, houseType, Location{12, 12, 0.0})
+	built.ResourcesLeft = 50
+	rerankHouse(game.terrain, built)
+
+	builder.Carrying = 5
+	builder.Target = planned
+	miner.Target = built
+	marcher.Target = &Location{2, 12, 0.0}
+	SetName(game, &Location{3, 3, 0.0}, "rally")
+	for i := 0; i < 3; i++ {
+		Tick(game, 1.0)
+	}
+	return game
+}
+
+func TestSaveRoundTrip(t *testing.T) {
+	game := buildSaveGame()
+	game.pileType = &HouseType{Width: 1, Height: 1, Sight: 2}
+	AddDeposit(game, pileType(game), Location{14, 0, 0.0}, 7)
+	AddDeposit(game, houseType, Location{15, 0, 0.0}, 0)
+	loaded := saveAndLoad(t, game)
+
+	if !reflect.DeepEqual(ReadStatus(game), ReadStatus(loaded)) {
+		t.Errorf("Expected loaded status %v, got %v", ReadStatus(game), ReadStatus(loaded))
+	}
+	if CurrentTick(loaded) != 3 {
+		t.Errorf("Expected loaded game at tick 3, got %d", CurrentTick(loaded))
+	}
+	for x := 0; x < 16; x++ {
+		for y := 0; y < 16; y++ {
+			if occupantName(game.terrain.Board[x][y]) != occupantName(loaded.terrain.Board[x][y]) {
+				t.Errorf("Expected %v at %dx%d, found %v", game.terrain.Board[x][y],
+					x, y, loaded.terrain.Board[x][y])
+			}
+		}
+	}
+	if loc, ok := lookup(loaded, "rally").(*Location); !ok || *loc != loc3x3 {
+		t.Errorf("Expected waypoint rally at 3x3, got %v", lookup(loaded, "rally"))
+	}
+	if _, ok := loaded.houseTypes["house"]; !ok {
+		t.Errorf("Expected house type catalog to be loaded")
+	}
+	if loaded.pileType == nil || *loaded.pileType != *game.pileType {
+		t.Errorf("Expected pile type %v, got %v", game.pileType, loaded.pileType)
+	}
+	for _, deposit := range Deposits(loaded) {
+		if (deposit.Type == loaded.pileType) != (deposit.Location.X == 14) {
+			t.Errorf("Deposit at %v has the wrong type %v", deposit.Location, deposit.Type)
+		}
+	}
+
+	for i := 0; i < 20; i++ {
+		Tick(game, 1.0)
+		Tick(loaded, 1.0)
+	}
+	if !reflect.DeepEqual(ReadStatus(game), ReadStatus(loaded)) {
+		t.Errorf("Expected loaded game to play out like the original")
+	}
+}
+
+func TestSaveResolvesTargets(t *testing.T) {
+	game := buildSaveGame()
+	loaded := saveAndLoad(t, game)
+
+	builder := loaded.Cultures[0].Characters[0]
+	house, ok := builder.Target.(*House)
+	if !ok {
+		t.Fatalf("Expected builder to target a house, got %v", builder.Target)
+	}
+	if !loaded.Cultures[0].PlannedHouses[house] {
+		t.Errorf("Expected builder target to be the loaded planned house")
+	}
+	if house.Culture != loaded.Cultures[0] {
+		t.Errorf("Expected target house to belong to the loaded culture")
+	}
+
+	for house := range loaded.Cultures[0].BuiltHouses {
+		at := loaded.terrain.Board[house.Location.X][house.Location.Y]
+		if at != house {
+			t.Errorf("Expected built house on the board, found %v", at)
+		}
+	}
+}
+
+func TestSaveKeepsGoneTargets(t *testing.T) {
+	game := NewGame(16, 16)
+	red := AddCulture(game)
+	green := AddCulture(game)
+	house := addHouse(game, green, Location{8, 8, 0.0})
+	deposit, _ := AddDeposit(game, depositType, Location{8, 0, 0.0}, 0)
+	raider, _ := AddCharacter(game.terrain, red, workerType, loc0x0)
+	raider.Target = house
+	miner, _ := AddCharacter(game.terrain, red, workerType, Location{0, 4, 0.0})
+	miner.Target = deposit
+	for _, gone := range []*House{house, deposit} {
+		gone.ResourcesLeft = 0
+		rerankHouse(game.terrain, gone)
+	}
+
+	loaded := saveAndLoad(t, game)
+	target, ok := loaded.Cultures[0].Characters[0].Target.(*House)
+	if !ok || target.Name != house.Name || houseExists(target) || lookup(loaded, house.Name) != nil {
+		t.Fatalf("Expected the raider to still target the destroyed house, got %v", target)
+	}
+
+	Tick(game, 1.0)
+	Tick(loaded, 1.0)
+	events := TakeEvents(game)
+	if len(eventsOfType(events, EventTargetAbandoned)) != 2 {
+		t.Errorf("Expected both characters to abandon their targets, got %v", events)
+	}
+	if loadedEvents := TakeEvents(loaded); !reflect.DeepEqual(loadedEvents, events) {
+		t.Errorf("Expected loaded game to play out like the original, got %v", loadedEvents)
+	}
+	if !reflect.DeepEqual(ReadStatus(game), ReadStatus(loaded)) {
+		t.Errorf("Expected loaded status %v, got %v", ReadStatus(game), ReadStatus(loaded))
+	}
+}
+
+func TestLoadRejectsNewerVersion(t *testing.T) {
+	_, err := Load(strings.NewReader(`{"version": 99, "width": 4, "height": 4}`))
+	if err == nil {
+		t.Errorf("Expected an error loading a version from the future")
+	}
+}
+
+func TestLoadMigratesOldVersions(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Save(buildSaveGame(), &buf); err != nil {
+		t.Fatalf("Unexpected error saving game: %v", err)
+	}
+	var doc map[string]interface{}
+	json.Unmarshal(buf.Bytes(), &doc)
+	doc["version"] = float64(saveVersion - 1)
+	doc["wide"] = doc["width"]
+	delete(doc, "width")
+	old, _ := json.Marshal(doc)
+
+	previous := migrations[saveVersion-1]
+	migrations[saveVersion-1] = func(doc map[string]interface{}) (map[string]interface{}, error) {
+		doc["width"] = doc["wide"]
+		delete(doc, "wide")
+		return doc, nil
+	}
+	defer func() { migrations[saveVersion-1] = previous }()
+
+	loaded, err := Load(bytes.NewReader(old))
+	if err != nil {
+		t.Fatalf("Unexpected error loading old version: %v", err)
+	}
+	if loaded.terrain.Width != 16 {
+		t.Errorf("Expected migrated width 16, got %d", loaded.terrain.Width)
+	}
+}
+
+func TestLoadMigratesPileType(t *testing.T) {
+	game := buildSaveGame()
+	AddDeposit(game, houseType, Location{15, 0, 0.0}, 0)
+	AddDeposit(game, pileType(game), Location{14, 0, 0.0}, 7)
+	var buf bytes.Buffer
+	if err := Save(game, &buf); err != nil {
+		t.Fatalf("Unexpected error saving game: %v", err)
+	}
+	var doc map[string]interface{}
+	json.Unmarshal(buf.Bytes(), &doc)
+	doc["version"] = float64(1)
+	delete(doc, "pileType")
+	old, _ := json.Marshal(doc)
+
+	loaded, err := Load(bytes.NewReader(old))
+	if err != nil {
+		t.Fatalf("Unexpected error loading version 1: %v", err)
+	}
+	for _, deposit := range Deposits(loaded) {
+		if (deposit.Type == loaded.pileType) != (deposit.Location.X == 14) {
+			t.Errorf("Deposit at %v has the wrong type %v", deposit.Location, deposit.Type)
+		}
+	}
+}
+
+func TestLoadRejectsOverlaps(t *testing.T) {
+	var buf bytes.Buffer
+	Save(buildSaveGame(), &buf)
+	var doc savedGame
+	json.Unmarshal(buf.Bytes(), &doc)
+	doc.Cultures[1].Characters[0].Location = doc.Cultures[0].Characters[0].Location
+	broken, _ := json.Marshal(doc)
+
+	if _, err := Load(bytes.NewReader(broken)); err == nil {
+		t.Errorf("Expected an error loading overlapping characters")
+	}
+}
@@ -0,0 +1,345 @@
+package game
+
+import (
+	"log"
+	"math/rand"
+)
+
+// Scheduling selects how Tick decides which characters get to act first.
+type Scheduling int
+
+const (
+	// InOrderScheduling updates characters one at a time, culture by
+	// culture, so earlier cultures get the first pick of contested tiles
+	// and resources. This is the default.
+	InOrderScheduling Scheduling = iota
+
+	// FairScheduling decides what every character wants to do based on
+	// the state of the game before the tick, and then settles conflicts
+	// between characters with a seeded, deterministic rule.
+	FairScheduling
+)
+
+// SetScheduling chooses how future Ticks of game will be scheduled. The seed
+// is only used by FairScheduling, and games with the same seed will always
+// resolve conflicts the same way.
+func SetScheduling(game *Game, scheduling Scheduling, seed int64) {
+	game.scheduling = scheduling
+	game.seed = seed
+}
+
+// intent is what a character would like to do in a tick, decided before any
+// character has acted.
+type intent struct {
+	who      *Character
+	dest     Location
+	house    *House  // house to work on, if any
+	depot    *House  // depot to use, if any
+	stock    *House  // house to stock for training, if any
+	transfer float64 // resources the character would like to move
+}
+
+func fillFootprint(terrain Terrain, x, y, width, height int, occupant interface{}) {
+	for dx := 0; dx < width; dx++ {
+		for dy := 0; dy < height; dy++ {
+			terrain.Board[x+dx][y+dy] = occupant
+		}
+	}
+}
+
+// previewMove returns where who would end up if it moved toward goal, without
+// actually moving.
+func previewMove(who *Character, terrain Terrain, goal Location, distance float64) Location {
+	start := who.Location
+	attemptMove(who, terrain, goal, distance)
+	dest := who.Location
+
+	width, height := who.Type.Width, who.Type.Height
+	fillFootprint(terrain, dest.X, dest.Y, width, height, nil)
+	fillFootprint(terrain, start.X, start.Y, width, height, who)
+	who.Location = start
+
+	return dest
+}
+
+func planIntent(terrain Terrain, who *Character, dt float64) intent {
+	ret := intent{who: who, dest: who.Location}
+	distance := who.Type.MovePerTick * dt
+
+	switch target := who.Target.(type) {
+	case *Location:
+		ret.dest = previewMove(who, terrain, *target, distance)
+	case *House:
+		if !insideOfShadow(defaultShadowSize, who, target) {
+			ret.dest = previewMove(who, terrain, workLocation(target), distance)
+			break
+		}
+
+		if usingDepot(who, target) {
+			ret.depot = target
+			ret.transfer = exchangeAmount(who, dt)
+			break
+		}
+
+		ret.transfer = who.Type.WorkPerTick * dt
+		if who.Culture == target.Culture && isFinished(target) {
+			ret.stock = target
+			if ret.transfer > who.Carrying {
+				ret.transfer = who.Carrying
+			}
+			break
+		}
+
+		ret.house = target
+		if who.Culture == target.Culture {
+			if ret.transfer > who.Carrying {
+				ret.transfer = who.Carrying
+			}
+		} else {
+			if ret.transfer > who.Type.MaxCarry-who.Carrying {
+				ret.transfer = who.Type.MaxCarry - who.Carrying
+			}
+		}
+	case *Character:
+		if !inRange(who, target) {
+			ret.dest = previewMove(who, terrain, target.Location, distance)
+		}
+	case nil:
+		// Nothing to do
+	default:
+		log.Panicf("unexpected character target type %T\n", target)
+	}
+
+	return ret
+}
+
+// resolveMove moves a character to its intended destination, unless a
+// character that moved earlier in the tick has taken the spot.
+func resolveMove(terrain Terrain, in intent) {
+	who := in.who
+	if in.dest.X == who.Location.X && in.dest.Y == who.Location.Y {
+		who.Location.Offset = in.dest.Offset
+		return
+	}
+
+	width, height := who.Type.Width, who.Type.Height
+	if !isTerrainClear(who, terrain, in.dest.X, in.dest.Y, width, height) {
+		return
+	}
+
+	fillFootprint(terrain, who.Location.X, who.Location.Y, width, height, nil)
+	who.Location = in.dest
+	fillFootprint(terrain, who.Location.X, who.Location.Y, width, height, who)
+}
+
+// share is the fraction of each request that can be granted when requests for
+// a total of demand compete for available resources.
+func share(demand, available float64) float64 {
+	if demand <= available {
+		return 1
+	}
+	if available <= 0 {
+		return 0
+	}
+	return available / demand
+}
+
+func isBuilding(in intent) bool {
+	return in.who.Culture == in.house.Culture
+}
+
+// resolveWork carries out all of the mining and building intended for a tick.
+// When characters ask for more than a house can give (or take), every
+// character gets the same fraction of what it asked for.
+func resolveWork(terrain Terrain, intents []intent, order []int) {
+	var houses []*House
+	mining := make(map[*House]float64)
+	building := make(map[*House]float64)
+
+	for _, i := range order {
+		in := intents[i]
+		if in.house == nil {
+			continue
+		}
+
+		house := in.house
+		if _, seen := mining[house]; !seen {
+			houses = append(houses, house)
+			mining[house] = 0
+			building[house] = 0
+		}
+
+		if isBuilding(in) {
+			_, built := house.Culture.BuiltHouses[house]
+			// Characters that moved this tick may be in the way.
+			if !built && !isTerrainClear(house, terrain,
+				house.Location.X, house.Location.Y,
+				house.Type.Width, house.Type.Height) {
+				intents[i].transfer = 0
+				continue
+			}
+			building[house] = building[house] + in.transfer
+		} else {
+			mining[house] = mining[house] + in.transfer
+		}
+	}
+
+	for _, house := range houses {
+		mineShare := share(mining[house], house.ResourcesLeft)
+		buildShare := share(building[house],
+			house.Type.MaxResources-house.ResourcesLeft)
+
+		for _, i := range order {
+			in := intents[i]
+			if in.house != house {
+				continue
+			}
+			if isBuilding(in) {
+				in.who.Carrying = in.who.Carrying - in.transfer*buildShare
+			} else {
+				in.who.Carrying = in.who.Carrying + in.transfer*mineShare
+			}
+		}
+
+		// Update the house from the totals, rather than character by
+		// character, so that a fully mined house ends up at exactly zero.
+		// Deposits can hold more than their type's MaxResources, so
+		// only clamp when someone actually asked for something.
+		mined := mining[house] * mineShare
+		if mineShare < 1 && mining[house] > 0 {
+			mined = house.ResourcesLeft
+		}
+		built := building[house] * buildShare
+		if buildShare < 1 && building[house] > 0 {
+			built = house.Type.MaxResources - house.ResourcesLeft
+		}
+		before := house.ResourcesLeft
+		house.ResourcesLeft = house.ResourcesLeft - mined + built
+		noteResourcesAdded(house, before)
+	}
+
+	for _, house := range houses {
+		rerankHouse(terrain, house)
+	}
+}
+
+// resolveExchanges carries out all of the depot deliveries and pickups
+// intended for a tick. Deliveries are banked first, and when characters ask
+// for more than their culture has banked, each gets the same fraction of what
+// it asked for.
+func resolveExchanges(intents []intent, order []int) {
+	var cultures []*Culture
+	pickups := make(map[*Culture]float64)
+
+	for _, i := range order {
+		in := intents[i]
+		if in.depot == nil {
+			continue
+		}
+		culture := in.who.Culture
+		if in.transfer >= 0 {
+			in.who.Carrying = in.who.Carrying - in.transfer
+			culture.Resources = culture.Resources + in.transfer
+			continue
+		}
+		if _, seen := pickups[culture]; !seen {
+			cultures = append(cultures, culture)
+		}
+		pickups[culture] = pickups[culture] - in.transfer
+	}
+
+	for _, culture := range cultures {
+		pickupShare := share(pickups[culture], culture.Resources)
+		for _, i := range order {
+			in := intents[i]
+			if in.depot == nil || in.transfer >= 0 || in.who.Culture != culture {
+				continue
+			}
+			in.who.Carrying = in.who.Carrying - in.transfer*pickupShare
+		}
+
+		if pickupShare < 1 {
+			culture.Resources = 0
+		} else {
+			culture.Resources = culture.Resources - pickups[culture]
+		}
+	}
+}
+
+// resolveStocking carries out all of the deliveries to houses that train
+// characters intended for a tick. When characters bring more than a house
+// needs, each gets the same fraction of what it brought accepted.
+func resolveStocking(intents []intent, order []int) {
+	var houses []*House
+	delivered := make(map[*House]float64)
+
+	for _, i := range order {
+		in := intents[i]
+		if in.stock == nil {
+			continue
+		}
+		if _, seen := delivered[in.stock]; !seen {
+			houses = append(houses, in.stock)
+		}
+		delivered[in.stock] = delivered[in.stock] + in.transfer
+	}
+
+	for _, house := range houses {
+		needed := stockNeeded(house)
+		deliveryShare := share(delivered[house], needed)
+		for _, i := range order {
+			in := intents[i]
+			if in.stock == house {
+				in.who.Carrying = in.who.Carrying - in.transfer*deliveryShare
+			}
+		}
+
+		if deliveryShare < 1 {
+			house.stock = house.stock + needed
+		} else {
+			house.stock = house.stock + delivered[house]
+		}
+	}
+}
+
+// fairTick advances the game using FairScheduling. Every character's intent is
+// decided against the game as it was when the tick began. Characters then
+// move in an order shuffled by the game seed and tick number, so that no
+// culture gets the first pick of contested tiles every tick.
+func fairTick(game *Game, dt float64) {
+	var everyone []*Character
+	for _, culture := range game.Cultures {
+		everyone = append(everyone, culture.Characters...)
+	}
+
+	intents := make([]intent, len(everyone))
+	for i, who := range everyone {
+		intents[i] = planIntent(game.terrain, who, dt)
+	}
+
+	rng := rand.New(rand.NewSource(game.seed + int64(game.tick)))
+	order := rng.Perm(len(everyone))
+
+	starts := make([]Location, len(everyone))
+	moving := make([]bool, len(everyone))
+	for i, who := range everyone {
+		starts[i] = who.Location
+		moving[i] = wantsToMove(who)
+	}
+	for _, i := range order {
+		resolveMove(game.terrain, intents[i])
+	}
+	for i, who := range everyone {
+		noteBlocked(who, moving[i] && who.Location == starts[i])
+	}
+
+	resolveWork(game.terrain, intents, order)
+	resolveExchanges(intents, order)
+	resolveStocking(intents, order)
+
+	for _, who := range everyone {
+		if _, ok := who.Target.(*House); ok {
+			reevaluateTargetHouse(who)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package game
+
+import "testing"
+
+var pawnType = &CharacterType{
+	MovePerTick: 1,
+	WorkPerTick: 4,
+	MaxCarry:    10,
+	Width:       1,
+	Height:      1,
+}
+
+// contestedTile sets up two characters from different cultures who both want
+// to step onto the tile between them, and returns the index of the culture
+// that gets it.
+func contestedTile(t *testing.T, seed int64) int {
+	game := NewGame(3, 1)
+	SetScheduling(game, FairScheduling, seed)
+	AddCulture(game)
+	AddCulture(game)
+
+	left, _ := AddCharacter(game.terrain, game.Cultures[0], pawnType, Location{0, 0, 0.0})
+	right, _ := AddCharacter(game.terrain, game.Cultures[1], pawnType, Location{2, 0, 0.0})
+	left.Target = &Location{1, 0, 0.0}
+	right.Target = &Location{1, 0, 0.0}
+
+	Tick(game, 1)
+
+	switch game.terrain.Board[1][0] {
+	case left:
+		if right.Location != (Location{2, 0, 0.0}) {
+			t.Errorf("Loser moved to %v", right.Location)
+		}
+		return 0
+	case right:
+		if left.Location != (Location{0, 0, 0.0}) {
+			t.Errorf("Loser moved to %v", left.Location)
+		}
+		return 1
+	}
+
+	DumpTerrain(game.terrain)
+	t.Fatalf("Nobody took the contested tile")
+	return -1
+}
+
+func TestFairSchedulingContestedTile(t *testing.T) {
+	var wins [2]int
+	for seed := int64(0); seed < 32; seed++ {
+		winner := contestedTile(t, seed)
+		wins[winner]++
+
+		if contestedTile(t, seed) != winner {
+			t.Errorf("Seed %d didn't pick the same winner twice", seed)
+		}
+	}
+
+	if wins[0] == 0 || wins[1] == 0 {
+		t.Errorf("One culture always wins contested tiles: %v", wins)
+	}
+}
+
+func TestFairSchedulingSharesLastResources(t *testing.T) {
+	game := NewGame(8, 8)
+	SetScheduling(game, FairScheduling, 0)
+	AddCulture(game)
+	AddCulture(game)
+	AddCulture(game)
+
+	house := PlanHouse(game.Cultures[0], houseType, Location{4, 4, 0.0})
+	house.ResourcesLeft = 4
+	rerankHouse(game.terrain, house)
+
+	first, _ := AddCharacter(game.terrain, game.Cultures[1], pawnType, Location{3, 4, 0.0})
+	second, _ := AddCharacter(game.terrain, game.Cultures[2], pawnType, Location{5, 4, 0.0})
+	first.Target = house
+	second.Target = house
+
+	Tick(game, 1)
+
+	if first.Carrying != 2 || second.Carrying != 2 {
+		t.Errorf("Expected miners to split resources evenly, got %v and %v",
+			first.Carrying, second.Carrying)
+	}
+	if house.ResourcesLeft != 0 {
+		t.Errorf("Expected house to be mined out, has %v", house.ResourcesLeft)
+	}
+	if _, built := game.Cultures[0].BuiltHouses[house]; built {
+		t.Errorf("Mined out house is still built")
+	}
+}
+
+func TestFairSchedulingBuildAndMine(t *testing.T) {
+	game := NewGame(4, 4)
+	SetScheduling(game, FairScheduling, 7)
+	AddCulture(game)
+
+	house := PlanHouse(game.Cultures[0], houseType, loc0x0)
+	builder, _ := AddCharacter(game.terrain, game.Cultures[0], workerType, Location{2, 0, 0.0})
+
+	for house.ResourcesLeft < house.Type.MaxResources {
+		if builder.Carrying == 0 {
+			builder.Carrying = builder.Type.MaxCarry
+			builder.Target = house
+		}
+
+		carryingBefore := builder.Carrying
+		locBefore := builder.Location
+
+		Tick(game, 1)
+
+		if carryingBefore == builder.Carrying && locBefore == builder.Location {
+			DumpTerrain(game.terrain)
+			t.Fatalf("Gridlock trying to build")
+		}
+	}
+
+	if _, built := game.Cultures[0].BuiltHouses[house]; !built {
+		t.Errorf("Completed house isn't built")
+	}
+	if builder.Target != nil {
+		t.Errorf("Builder failed to reevaluate after building house")
+	}
+}
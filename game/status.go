@@ -0,0 +1,210 @@
+package game
+
+import "sort"
+
+// CharacterStatus is a snapshot of a single Character. Type is the name the
+// character type was given with AddCharacterType, if it has one. Target is
+// the name of the House the character is working on, if any. If the character
+// is marching toward a Location instead, Marching is true and Destination
+// holds the spot, and if it's attacking another character, Attacking is true
+// and Target is the victim's name. Characters on a trip to a depot have the
+// name of the house they'll return to as their Job.
+type CharacterStatus struct {
+	Name        string   `json:"name"`
+	Type        string   `json:"type"`
+	Location    Location `json:"location"`
+	Width       int      `json:"width"`
+	Height      int      `json:"height"`
+	Carrying    float64  `json:"carrying"`
+	Health      float64  `json:"health"`
+	Target      string   `json:"target,omitempty"`
+	Attacking   bool     `json:"attacking,omitempty"`
+	Job         string   `json:"job,omitempty"`
+	Marching    bool     `json:"marching"`
+	Destination Location `json:"destination"`
+}
+
+// HouseStatus is a snapshot of a single House, either planned or built. Type
+// is the name the house type was given with AddHouseType, if it has one.
+// Priority is the priority its culture's job scheduler gives it. Depots are
+// where their culture's resources are banked. Houses that train characters
+// name the character type they train, with Queue characters waiting, Stock
+// resources delivered to pay for them, and Progress seconds spent training the
+// first.
+// Houses that are Remembered are out of sight, and are shown as they were
+// when they were last seen at tick LastSeen.
+type HouseStatus struct {
+	Name          string   `json:"name"`
+	Type          string   `json:"type"`
+	Location      Location `json:"location"`
+	Width         int      `json:"width"`
+	Height        int      `json:"height"`
+	ResourcesLeft float64  `json:"resourcesLeft"`
+	MaxResources  float64  `json:"maxResources"`
+	Depot         bool     `json:"depot,omitempty"`
+	Priority      int      `json:"priority,omitempty"`
+	Trains        string   `json:"trains,omitempty"`
+	Queue         int      `json:"queue,omitempty"`
+	Stock         float64  `json:"stock,omitempty"`
+	Progress      float64  `json:"progress,omitempty"`
+	Remembered    bool     `json:"remembered,omitempty"`
+	LastSeen      int      `json:"lastSeen,omitempty"`
+}
+
+// CultureStatus is a snapshot of a Culture and everything that belongs to it.
+// Resources and AutoJobs are only shown to the culture itself, when read with
+// ReadStatusFor.
+type CultureStatus struct {
+	Name          string            `json:"name"`
+	Resources     float64           `json:"resources"`
+	AutoJobs      bool              `json:"autoJobs"`
+	Characters    []CharacterStatus `json:"characters"`
+	PlannedHouses []HouseStatus     `json:"plannedHouses"`
+	BuiltHouses   []HouseStatus     `json:"builtHouses"`
+}
+
+// GameStatus is a snapshot of an entire game. It shares no memory with the
+// game it was read from, so it's safe to pass to other goroutines while the
+// game continues to Tick. Viewer is the culture the status was read for, if
+// it was read with ReadStatusFor. Phase is how far along the game is, and
+// Result is how it ended, once it's finished.
+type GameStatus struct {
+	Tick     int             `json:"tick"`
+	Viewer   string          `json:"viewer,omitempty"`
+	Width    int             `json:"width"`
+	Height   int             `json:"height"`
+	Tiles    []string        `json:"tiles"` // one row of tile symbols per y
+	Cultures []CultureStatus `json:"cultures"`
+	Deposits []HouseStatus   `json:"deposits"`
+	Phase    Phase           `json:"phase"`
+	Result   *GameResult     `json:"result,omitempty"`
+}
+
+// houseTypeName returns the name houseType was added to game's catalog under,
+// or "" if it isn't in the catalog. A type added under more than one name is
+// always called by the name that sorts first.
+func houseTypeName(game *Game, houseType *HouseType) string {
+	ret := ""
+	for name, t := range game.houseTypes {
+		if t == houseType && (ret == "" || name < ret) {
+			ret = name
+		}
+	}
+	return ret
+}
+
+// characterTypeName is houseTypeName for character types.
+func characterTypeName(game *Game, characterType *CharacterType) string {
+	ret := ""
+	for name, t := range game.characterTypes {
+		if t == characterType && (ret == "" || name < ret) {
+			ret = name
+		}
+	}
+	return ret
+}
+
+func readCharacterStatus(game *Game, who *Character) CharacterStatus {
+	ret := CharacterStatus{
+		Name:     who.Name,
+		Type:     characterTypeName(game, who.Type),
+		Location: who.Location,
+		Width:    who.Type.Width,
+		Height:   who.Type.Height,
+		Carrying: who.Carrying,
+		Health:   who.Health,
+	}
+
+	switch target := who.Target.(type) {
+	case *House:
+		ret.Target = target.Name
+		if who.work != nil {
+			ret.Job = who.work.Name
+		}
+	case *Location:
+		ret.Marching = true
+		ret.Destination = *target
+	case *Character:
+		ret.Target = target.Name
+		ret.Attacking = true
+	}
+
+	return ret
+}
+
+// readHouseStatuses returns snapshots of houses, sorted by name so that
+// snapshots of an unchanged game are identical.
+func readHouseStatuses(game *Game, houses map[*House]bool) []HouseStatus {
+	ret := make([]HouseStatus, 0, len(houses))
+	for house := range houses {
+		ret = append(ret, HouseStatus{
+			Name:          house.Name,
+			Type:          houseTypeName(game, house.Type),
+			Location:      house.Location,
+			Width:         house.Type.Width,
+			Height:        house.Type.Height,
+			ResourcesLeft: house.ResourcesLeft,
+			MaxResources:  house.Type.MaxResources,
+			Depot:         house.Type.Depot,
+			Priority:      house.Priority,
+			Trains:        house.Type.Trains,
+			Queue:         house.queue,
+			Stock:         house.stock,
+			Progress:      house.progress,
+		})
+	}
+
+	sortHouseStatuses(ret)
+	return ret
+}
+
+func sortHouseStatuses(houses []HouseStatus) {
+	sort.Slice(houses, func(i, j int) bool {
+		return houses[i].Name < houses[j].Name
+	})
+}
+
+func readCultureStatus(game *Game, culture *Culture) CultureStatus {
+	characters := make([]CharacterStatus, len(culture.Characters))
+	for i, who := range culture.Characters {
+		characters[i] = readCharacterStatus(game, who)
+	}
+
+	return CultureStatus{
+		Name:          culture.Name,
+		Resources:     culture.Resources,
+		AutoJobs:      culture.AutoJobs,
+		Characters:    characters,
+		PlannedHouses: readHouseStatuses(game, culture.PlannedHouses),
+		BuiltHouses:   readHouseStatuses(game, culture.BuiltHouses),
+	}
+}
+
+// readDepositStatuses describes every deposit in game. Deposits are part of
+// the map, so every culture can see all of them.
+func readDepositStatuses(game *Game) []HouseStatus {
+	if game.neutral == nil {
+		return make([]HouseStatus, 0)
+	}
+	return readHouseStatuses(game, game.neutral.BuiltHouses)
+}
+
+// ReadStatus returns a snapshot of the current state of game.
+func ReadStatus(game *Game) GameStatus {
+	ret := GameStatus{
+		Tick:     game.tick,
+		Width:    game.terrain.Width,
+		Height:   game.terrain.Height,
+		Tiles:    tileRows(game.terrain),
+		Cultures: make([]CultureStatus, len(game.Cultures)),
+		Deposits: readDepositStatuses(game),
+		Phase:    game.phase,
+		Result:   FinalResult(game),
+	}
+
+	for i, culture := range game.Cultures {
+		ret.Cultures[i] = readCultureStatus(game, culture)
+	}
+
+	return ret
+}
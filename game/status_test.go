@@ -0,0 +1,135 @@
+package game
+
+import "testing"
+
+func TestReadStatus(t *testing.T) {
+	game := NewGame(16, 16)
+	AddCulture(game)
+	AddCulture(game)
+	AddHouseType(game, "house", houseType)
+
+	red, _ := AddCharacter(
+		game.terrain,
+		game.Cultures[0],
+		workerType,
+		loc0x0,
+	)
+	green, _ := AddCharacter(
+		game.terrain,
+		game.Cultures[1],
+		workerType,
+		Location{8, 8, 0.5},
+	)
+	planned := PlanHouse(game.Cultures[0], houseType, Location{4, 4, 0.0})
+	built := PlanHouse(game.Cultures[1], houseType, Location{12, 12, 0.0})
+	built.ResourcesLeft = 50
+	rerankHouse(game.terrain, built)
+
+	red.Carrying = 5
+	red.Target = planned
+	green.Target = &Location{2, 2, 0.0}
+
+	status := ReadStatus(game)
+
+	if status.Width != 16 || status.Height != 16 {
+		t.Errorf("Unexpected terrain size %dx%d", status.Width, status.Height)
+	}
+	if len(status.Cultures) != 2 {
+		t.Fatalf("Expected two cultures, got %d", len(status.Cultures))
+	}
+
+	redStatus := status.Cultures[0].Characters[0]
+	expectRed := CharacterStatus{
+		Name:     red.Name,
+		Location: loc0x0,
+		Width:    2,
+		Height:   2,
+		Carrying: 5,
+		Health:   defaultHealth,
+		Target:   planned.Name,
+	}
+	if redStatus != expectRed {
+		t.Errorf("Expected red status %v, got %v", expectRed, redStatus)
+	}
+
+	greenStatus := status.Cultures[1].Characters[0]
+	expectGreen := CharacterStatus{
+		Name:        green.Name,
+		Location:    Location{8, 8, 0.5},
+		Width:       2,
+		Height:      2,
+		Health:      defaultHealth,
+		Marching:    true,
+		Destination: loc2x2,
+	}
+	if greenStatus != expectGreen {
+		t.Errorf("Expected green status %v, got %v", expectGreen, greenStatus)
+	}
+
+	plannedStatuses := status.Cultures[0].PlannedHouses
+	if len(plannedStatuses) != 1 || plannedStatuses[0].Name != planned.Name {
+		t.Errorf("Unexpected planned houses %v", plannedStatuses)
+	}
+
+	builtStatuses := status.Cultures[1].BuiltHouses
+	if len(builtStatuses) != 1 {
+		t.Fatalf("Unexpected built houses %v", builtStatuses)
+	}
+	expectBuilt := HouseStatus{
+		Name:          built.Name,
+		Type:          "house",
+		Location:      Location{12, 12, 0.0},
+		Width:         1,
+		Height:        1,
+		ResourcesLeft: 50,
+		MaxResources:  100,
+	}
+	if builtStatuses[0] != expectBuilt {
+		t.Errorf("Expected built status %v, got %v", expectBuilt, builtStatuses[0])
+	}
+}
+
+func TestReadStatusIsASnapshot(t *testing.T) {
+	game := NewGame(16, 16)
+	AddCulture(game)
+
+	character, _ := AddCharacter(
+		game.terrain,
+		game.Cultures[0],
+		workerType,
+		loc0x0,
+	)
+	character.Target = &Location{6, 8, 0.0}
+
+	status := ReadStatus(game)
+	Tick(game, 1)
+	AddCharacter(game.terrain, game.Cultures[0], workerType, loc2x2)
+
+	if status.Tick != 0 {
+		t.Errorf("Snapshot tick changed to %d", status.Tick)
+	}
+	if status.Cultures[0].Characters[0].Location != loc0x0 {
+		t.Errorf("Snapshot location changed to %v",
+			status.Cultures[0].Characters[0].Location)
+	}
+	if len(status.Cultures[0].Characters) != 1 {
+		t.Errorf("Snapshot characters changed")
+	}
+}
+
+func TestReadStatusNamesSharedTypesConsistently(t *testing.T) {
+	game := NewGame(16, 16)
+	AddCulture(game)
+	for _, name := range []string{"hut", "cabin", "shack", "barn"} {
+		AddHouseType(game, name, houseType)
+	}
+	PlanHouse(game.Cultures[0], houseType, loc2x2)
+
+	for i := 0; i < 20; i++ {
+		planned := ReadStatus(game).Cultures[0].PlannedHouses
+		if planned[0].Type != "barn" {
+			t.Fatalf("Expected a type shared by several names to be called barn, got %q",
+				planned[0].Type)
+		}
+	}
+}
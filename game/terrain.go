@@ -0,0 +1,145 @@
+package game
+
+import "fmt"
+
+// TileKind is the ground a tile of Terrain is made of. The zero TileKind is
+// Grass.
+type TileKind int
+
+const (
+	Grass TileKind = iota
+	Rock
+	Water
+	Road
+	Forest
+)
+
+// tileKindInfo describes how characters get across a kind of tile. moveCost
+// is how much of a character's movement it takes to step onto the tile.
+type tileKindInfo struct {
+	name     string
+	symbol   byte
+	passable bool
+	moveCost float64
+}
+
+var tileKinds = [...]tileKindInfo{
+	Grass:  {"grass", '-', true, 1.0},
+	Rock:   {"rock", '#', false, 1.0},
+	Water:  {"water", '~', false, 1.0},
+	Road:   {"road", '=', true, 0.5},
+	Forest: {"forest", '^', true, 2.0},
+}
+
+func (k TileKind) String() string {
+	if k < 0 || int(k) >= len(tileKinds) {
+		return fmt.Sprintf("TileKind(%d)", int(k))
+	}
+	return tileKinds[k].name
+}
+
+// Passable reports whether characters and houses can stand on tiles of kind k.
+func (k TileKind) Passable() bool {
+	return k >= 0 && int(k) < len(tileKinds) && tileKinds[k].passable
+}
+
+// TileKindForSymbol finds the kind of tile DumpTerrain draws as symbol, which
+// is also how tiles appear in GameStatus.
+func TileKindForSymbol(symbol byte) (TileKind, bool) {
+	for kind, info := range tileKinds {
+		if info.symbol == symbol {
+			return TileKind(kind), true
+		}
+	}
+	return Grass, false
+}
+
+func tileAt(terrain Terrain, x, y int) TileKind {
+	if terrain.Tiles == nil {
+		return Grass
+	}
+	return terrain.Tiles[x][y]
+}
+
+// isPassable reports whether a footprint at x, y only covers ground that
+// characters and houses can stand on. The footprint must be in bounds.
+func isPassable(terrain Terrain, x, y, width, height int) bool {
+	for dx := 0; dx < width; dx++ {
+		for dy := 0; dy < height; dy++ {
+			if !tileKinds[tileAt(terrain, x+dx, y+dy)].passable {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// moveCost is the movement it takes to step a footprint onto x, y, which is
+// the average cost of all of the tiles under it.
+func moveCost(terrain Terrain, x, y, width, height int) float64 {
+	total := 0.0
+	for dx := 0; dx < width; dx++ {
+		for dy := 0; dy < height; dy++ {
+			total += tileKinds[tileAt(terrain, x+dx, y+dy)].moveCost
+		}
+	}
+	return total / float64(width*height)
+}
+
+// SetTile changes the ground at x, y. Impassable tiles can't be placed under
+// characters or houses.
+func SetTile(terrain Terrain, x, y int, kind TileKind) error {
+	if kind < 0 || int(kind) >= len(tileKinds) {
+		return fmt.Errorf("unknown tile kind %d", int(kind))
+	}
+	if !inBounds(terrain, x, y, 1, 1) {
+		return fmt.Errorf("tile %d, %d is out of bounds", x, y)
+	}
+	if !tileKinds[kind].passable && terrain.Board[x][y] != nil {
+		return fmt.Errorf("can't put %s under %v", kind, terrain.Board[x][y])
+	}
+	terrain.Tiles[x][y] = kind
+	return nil
+}
+
+// TileAt returns the ground at x, y.
+func TileAt(terrain Terrain, x, y int) TileKind {
+	return tileAt(terrain, x, y)
+}
+
+// tileRows draws the tiles of terrain as one string per row, using the same
+// symbols as DumpTerrain.
+func tileRows(terrain Terrain) []string {
+	rows := make([]string, terrain.Height)
+	for y := 0; y < terrain.Height; y++ {
+		row := make([]byte, terrain.Width)
+		for x := 0; x < terrain.Width; x++ {
+			row[x] = tileKinds[tileAt(terrain, x, y)].symbol
+		}
+		rows[y] = string(row)
+	}
+	return rows
+}
+
+// setTileRows is the inverse of tileRows.
+func setTileRows(terrain Terrain, rows []string) error {
+	if len(rows) != terrain.Height {
+		return fmt.Errorf("expected %d rows of tiles, got %d", terrain.Height, len(rows))
+	}
+	for y, row := range rows {
+		if len(row) != terrain.Width {
+			return fmt.Errorf("expected row %d to have %d tiles, got %d",
+				y, terrain.Width, len(row))
+		}
+		for x := 0; x < len(row); x++ {
+			kind, ok := TileKindForSymbol(row[x])
+			if !ok {
+				return fmt.Errorf("unknown tile %q at %d, %d", row[x], x, y)
+			}
+			if err := SetTile(terrain, x, y, kind); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package game
+
+import "testing"
+
+var walkerType = &CharacterType{
+	MovePerTick: 1,
+	Width:       1,
+	Height:      1,
+}
+
+func fillTiles(game *Game, kind TileKind, x, y, width, height int) {
+	for dx := 0; dx < width; dx++ {
+		for dy := 0; dy < height; dy++ {
+			SetTile(game.terrain, x+dx, y+dy, kind)
+		}
+	}
+}
+
+func TestImpassableTiles(t *testing.T) {
+	game := NewGame(8, 8)
+	SetTile(game.terrain, 2, 2, Rock)
+	SetTile(game.terrain, 5, 5, Water)
+
+	if isTerrainClear(nil, game.terrain, 2, 2, 1, 1) {
+		t.Errorf("Expected rock to be impassable")
+	}
+	if isTerrainClear(nil, game.terrain, 4, 4, 2, 2) {
+		t.Errorf("Expected water to be impassable")
+	}
+	if !isTerrainClear(nil, game.terrain, 3, 3, 1, 1) {
+		t.Errorf("Expected grass to be passable")
+	}
+
+	culture := AddCulture(game)
+	if _, err := AddCharacter(game.terrain, culture, walkerType, Location{2, 2, 0.0}); err == nil {
+		t.Errorf("Expected an error adding a character on rock")
+	}
+
+	who, _ := AddCharacter(game.terrain, culture, walkerType, loc0x0)
+	if err := SetTile(game.terrain, 0, 0, Water); err == nil {
+		t.Errorf("Expected an error flooding a character")
+	}
+	if TileAt(game.terrain, 0, 0) != Grass || who.Location != loc0x0 {
+		t.Errorf("Failed flood shouldn't change anything")
+	}
+}
+
+func TestRoadsAreFaster(t *testing.T) {
+	game := NewGame(16, 4)
+	culture := AddCulture(game)
+	fillTiles(game, Road, 0, 0, 16, 1)
+
+	who, _ := AddCharacter(game.terrain, culture, walkerType, loc0x0)
+	who.Target = &Location{15, 0, 0.0}
+	Tick(game, 1.0)
+	if who.Location.X != 2 || who.Location.Y != 0 {
+		t.Errorf("Expected to go two tiles down the road, got %v", who.Location)
+	}
+}
+
+func TestForestsAreSlower(t *testing.T) {
+	game := NewGame(16, 4)
+	culture := AddCulture(game)
+	fillTiles(game, Forest, 1, 0, 15, 4)
+
+	who, _ := AddCharacter(game.terrain, culture, walkerType, loc0x0)
+	who.Target = &Location{15, 0, 0.0}
+	Tick(game, 1.0)
+	if who.Location.X != 0 || who.Location.Offset != 0.5 {
+		t.Errorf("Expected to be half way into the forest, got %v", who.Location)
+	}
+	Tick(game, 1.0)
+	if who.Location.X != 1 || who.Location.Offset != 0.0 {
+		t.Errorf("Expected to be one tile into the forest, got %v", who.Location)
+	}
+	for i := 0; i < 4; i++ {
+		Tick(game, 1.0)
+	}
+	if who.Location.X != 3 {
+		t.Errorf("Expected to walk a tile every two ticks, got %v", who.Location)
+	}
+}
+
+func TestRouteAroundWater(t *testing.T) {
+	game := NewGame(32, 32)
+	culture := AddCulture(game)
+	fillTiles(game, Water, 10, 0, 2, 24)
+
+	who, _ := AddCharacter(game.terrain, culture, walkerType, loc0x0)
+	goal := Location{20, 0, 0.0}
+	moved := attemptMove(who, game.terrain, goal, 100)
+	if who.Location != goal {
+		DumpTerrain(game.terrain)
+		t.Fatalf("Couldn't walk around water, expected %v got %v", goal, who.Location)
+	}
+	if shortest := float64(2*24 + 20); moved != shortest {
+		t.Errorf("Expected to walk %v, walked %v", shortest, moved)
+	}
+}
+
+func TestRoutePrefersRoads(t *testing.T) {
+	game := NewGame(24, 8)
+	culture := AddCulture(game)
+	fillTiles(game, Forest, 0, 0, 24, 4)
+	fillTiles(game, Road, 0, 4, 24, 1)
+
+	who, _ := AddCharacter(game.terrain, culture, walkerType, loc0x0)
+	r := planRoute(who, game.terrain, Location{23, 0, 0.0})
+	onRoad := 0
+	for _, at := range r.tiles {
+		if TileAt(game.terrain, at.x, at.y) == Road {
+			onRoad++
+		}
+	}
+	if onRoad < 20 {
+		t.Errorf("Expected route to follow the road, only %d tiles were road", onRoad)
+	}
+}
@@ -0,0 +1,201 @@
+package game
+
+import "sort"
+
+// Phase is how far along a game is. Games are in the lobby until they're
+// started with StartGame, and are running until one of their win conditions
+// ends them.
+type Phase string
+
+const (
+	PhaseLobby    Phase = "lobby"
+	PhaseRunning  Phase = "running"
+	PhaseFinished Phase = "finished"
+)
+
+// Reasons a game can end, given in GameResult.
+const (
+	EndLastStanding   = "last culture standing"
+	EndResourceTarget = "resource target reached"
+	EndTimeLimit      = "time limit reached"
+)
+
+// GameResult is how a game ended. Winners are the names of the winning
+// cultures, and are empty if nobody won. Scores are the Score of every
+// culture when the game ended.
+type GameResult struct {
+	Tick    int                `json:"tick"`
+	Reason  string             `json:"reason"`
+	Winners []string           `json:"winners"`
+	Scores  map[string]float64 `json:"scores"`
+}
+
+// WinCondition decides when a game is over. Check is called after every Tick
+// of a running game, and returns the reason the game ended and its winners,
+// or an empty reason if the game should go on.
+type WinCondition interface {
+	Check(game *Game) (reason string, winners []string)
+}
+
+// LastCultureStanding ends a game once exactly one culture has any built
+// houses left, and that culture wins. Cultures that have never had a built
+// house aren't in the running yet, so the game only ends once at least two
+// cultures have built something.
+type LastCultureStanding struct{}
+
+// ResourceTarget ends a game once a culture has banked at least Resources.
+// Every culture past the target at the same tick wins.
+type ResourceTarget struct {
+	Resources float64
+}
+
+// TimeLimit ends a game once it has run for Ticks ticks. The cultures with
+// the highest Score win.
+type TimeLimit struct {
+	Ticks int
+}
+
+// Check looks for a culture that's the last with built houses.
+func (c LastCultureStanding) Check(game *Game) (string, []string) {
+	contenders := 0
+	var standing []string
+	for _, culture := range game.Cultures {
+		if culture.hasBuilt {
+			contenders++
+		}
+		if len(culture.BuiltHouses) > 0 {
+			standing = append(standing, culture.Name)
+		}
+	}
+	if contenders < 2 || len(standing) != 1 {
+		return "", nil
+	}
+	return EndLastStanding, standing
+}
+
+// Check looks for cultures that have banked enough.
+func (c ResourceTarget) Check(game *Game) (string, []string) {
+	var rich []string
+	for _, culture := range game.Cultures {
+		if culture.Resources >= c.Resources {
+			rich = append(rich, culture.Name)
+		}
+	}
+	if len(rich) == 0 {
+		return "", nil
+	}
+	return EndResourceTarget, rich
+}
+
+// Check looks at the clock, and picks the highest scoring cultures once time
+// is up.
+func (c TimeLimit) Check(game *Game) (string, []string) {
+	if game.tick < c.Ticks {
+		return "", nil
+	}
+	var best []string
+	bestScore := 0.0
+	for _, culture := range game.Cultures {
+		score := Score(culture)
+		switch {
+		case len(best) == 0 || score > bestScore:
+			best = []string{culture.Name}
+			bestScore = score
+		case score == bestScore:
+			best = append(best, culture.Name)
+		}
+	}
+	return EndTimeLimit, best
+}
+
+// Score is what a culture is worth: everything it has banked, everything its
+// characters are carrying, and everything built into its houses. Houses are
+// added up in order, so the same culture always gets exactly the same score.
+func Score(culture *Culture) float64 {
+	score := culture.Resources
+	for _, who := range culture.Characters {
+		score = score + who.Carrying
+	}
+	for _, house := range sortedHouses(culture.BuiltHouses) {
+		score = score + house.ResourcesLeft
+	}
+	return score
+}
+
+// AddWinCondition makes condition one of the ways game can end. Conditions
+// are checked in the order they were added, and the first to end the game
+// decides its result. Games without any conditions run forever.
+func AddWinCondition(game *Game, condition WinCondition) {
+	game.winConditions = append(game.winConditions, condition)
+}
+
+// StartGame moves game out of the lobby, and returns false if it had already
+// left it.
+func StartGame(game *Game) bool {
+	if game.phase != PhaseLobby {
+		return false
+	}
+	game.phase = PhaseRunning
+	game.started = game.tick
+	return true
+}
+
+// StartTick returns the tick game left the lobby at, or false if it's still
+// in the lobby.
+func StartTick(game *Game) (int, bool) {
+	return game.started, game.phase != PhaseLobby
+}
+
+// CurrentPhase returns how far along game is.
+func CurrentPhase(game *Game) Phase {
+	return game.phase
+}
+
+// FinalResult returns a copy of how game ended, or nil if it's still going.
+func FinalResult(game *Game) *GameResult {
+	return copyResult(game.result)
+}
+
+// copyResult returns a copy of result that shares no memory with it, or nil
+// if result is nil.
+func copyResult(result *GameResult) *GameResult {
+	if result == nil {
+		return nil
+	}
+	ret := *result
+	ret.Winners = append([]string{}, result.Winners...)
+	ret.Scores = make(map[string]float64)
+	for name, score := range result.Scores {
+		ret.Scores[name] = score
+	}
+	return &ret
+}
+
+// checkWinConditions finishes game if any of its win conditions say it's over.
+func checkWinConditions(game *Game) {
+	for _, condition := range game.winConditions {
+		reason, winners := condition.Check(game)
+		if reason == "" {
+			continue
+		}
+
+		scores := make(map[string]float64)
+		for _, culture := range game.Cultures {
+			scores[culture.Name] = Score(culture)
+		}
+		if winners == nil {
+			winners = []string{}
+		}
+		sort.Strings(winners)
+
+		game.phase = PhaseFinished
+		game.result = &GameResult{
+			Tick:    game.tick,
+			Reason:  reason,
+			Winners: winners,
+			Scores:  scores,
+		}
+		emit(game, Event{Type: EventGameOver})
+		return
+	}
+}
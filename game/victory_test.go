@@ -0,0 +1,214 @@
+package game
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+// addHouse adds an already built house for culture at loc.
+func addHouse(game *Game, culture *Culture, loc Location) *House {
+	house := PlanHouse(culture, houseType, loc)
+	house.ResourcesLeft = houseType.MaxResources
+	rerankHouse(game.terrain, house)
+	return house
+}
+
+func TestPhases(t *testing.T) {
+	game := NewGame(8, 8)
+	AddCulture(game)
+	if CurrentPhase(game) != PhaseLobby || ReadStatus(game).Phase != PhaseLobby {
+		t.Errorf("Expected a new game to be in the lobby")
+	}
+	Tick(game, 1.0)
+	if CurrentPhase(game) != PhaseLobby {
+		t.Errorf("Expected ticking not to start the game")
+	}
+	if !StartGame(game) || CurrentPhase(game) != PhaseRunning || FinalResult(game) != nil {
+		t.Errorf("Expected a started game to be running")
+	}
+	if StartGame(game) {
+		t.Errorf("Expected a game to only start once")
+	}
+}
+
+func TestLastCultureStanding(t *testing.T) {
+	game := NewGame(8, 8)
+	StartGame(game)
+	AddWinCondition(game, LastCultureStanding{})
+	red := AddCulture(game)
+	green := AddCulture(game)
+	addHouse(game, red, loc0x0)
+	greenHouse := addHouse(game, green, loc3x3)
+
+	Tick(game, 1.0)
+	if CurrentPhase(game) != PhaseRunning {
+		t.Fatalf("Expected the game to go on while both cultures have houses")
+	}
+
+	greenHouse.ResourcesLeft = 0
+	rerankHouse(game.terrain, greenHouse)
+	Tick(game, 1.0)
+
+	result := ReadStatus(game).Result
+	if CurrentPhase(game) != PhaseFinished || result == nil {
+		t.Fatalf("Expected the game to end when green lost its last house")
+	}
+	if result.Reason != EndLastStanding || !reflect.DeepEqual(result.Winners, []string{red.Name}) {
+		t.Errorf("Expected red to win, got %v", result)
+	}
+	if result.Tick != 2 || result.Scores[red.Name] != houseType.MaxResources {
+		t.Errorf("Unexpected result %v", result)
+	}
+
+	// Alone, a culture can't be the last one standing.
+	alone := NewGame(8, 8)
+	StartGame(alone)
+	AddWinCondition(alone, LastCultureStanding{})
+	addHouse(alone, AddCulture(alone), loc0x0)
+	Tick(alone, 1.0)
+	if CurrentPhase(alone) != PhaseRunning {
+		t.Errorf("Expected a game with one culture to go on")
+	}
+
+	// Nobody is standing before anybody has built anything, and the
+	// first to build hasn't outlasted anyone.
+	empty := NewGame(8, 8)
+	StartGame(empty)
+	AddWinCondition(empty, LastCultureStanding{})
+	first := AddCulture(empty)
+	AddCulture(empty)
+	Tick(empty, 1.0)
+	if CurrentPhase(empty) != PhaseRunning {
+		t.Errorf("Expected a game without any houses to go on")
+	}
+	addHouse(empty, first, loc0x0)
+	Tick(empty, 1.0)
+	if CurrentPhase(empty) != PhaseRunning {
+		t.Errorf("Expected a game where only one culture has built to go on")
+	}
+
+	// Losing every house at once doesn't leave anyone standing.
+	both := NewGame(8, 8)
+	StartGame(both)
+	AddWinCondition(both, LastCultureStanding{})
+	for i, culture := range []*Culture{AddCulture(both), AddCulture(both)} {
+		house := addHouse(both, culture, Location{4 * i, 0, 0.0})
+		house.ResourcesLeft = 0
+		rerankHouse(both.terrain, house)
+	}
+	Tick(both, 1.0)
+	if CurrentPhase(both) != PhaseRunning {
+		t.Errorf("Expected a game with nobody standing to go on")
+	}
+	if loaded := saveAndLoad(t, both); !loaded.Cultures[0].hasBuilt {
+		t.Errorf("Expected saves to remember who has built")
+	}
+}
+
+func TestResourceTarget(t *testing.T) {
+	game := NewGame(8, 8)
+	StartGame(game)
+	AddWinCondition(game, ResourceTarget{Resources: 50})
+	red := AddCulture(game)
+	green := AddCulture(game)
+	red.Resources = 49
+	green.Resources = 50
+
+	Tick(game, 1.0)
+	result := FinalResult(game)
+	if result == nil || result.Reason != EndResourceTarget ||
+		!reflect.DeepEqual(result.Winners, []string{green.Name}) {
+		t.Errorf("Expected green to reach the target, got %v", result)
+	}
+}
+
+func TestTimeLimit(t *testing.T) {
+	game := NewGame(16, 16)
+	StartGame(game)
+	AddWinCondition(game, TimeLimit{Ticks: 3})
+	red := AddCulture(game)
+	green := AddCulture(game)
+	blue := AddCulture(game)
+	who, _ := AddCharacter(game.terrain, red, workerType, loc0x0)
+	who.Carrying = 80
+	red.Resources = 20
+	addHouse(game, green, Location{8, 8, 0.0})
+	blue.Resources = 10
+
+	for i := 0; i < 5; i++ {
+		Tick(game, 1.0)
+	}
+
+	result := FinalResult(game)
+	if result == nil || result.Reason != EndTimeLimit || result.Tick != 3 {
+		t.Fatalf("Expected the game to end after 3 ticks, got %v", result)
+	}
+	expected := []string{red.Name, green.Name}
+	if red.Name > green.Name {
+		expected = []string{green.Name, red.Name}
+	}
+	if !reflect.DeepEqual(result.Winners, expected) {
+		t.Errorf("Expected red and green to tie, got %v", result.Winners)
+	}
+	if result.Scores[blue.Name] != 10 {
+		t.Errorf("Expected blue to score 10, got %v", result.Scores)
+	}
+}
+
+func TestFinishedGamesStop(t *testing.T) {
+	game := NewGame(16, 16)
+	StartGame(game)
+	AddWinCondition(game, TimeLimit{Ticks: 1})
+	culture := AddCulture(game)
+	who, _ := AddCharacter(game.terrain, culture, workerType, loc0x0)
+	who.Target = &Location{10, 10, 0.0}
+
+	Tick(game, 1.0)
+	where := who.Location
+	Tick(game, 1.0)
+	if CurrentTick(game) != 1 || who.Location != where {
+		t.Errorf("Expected nothing to happen after the game finished")
+	}
+
+	results := ApplyOrders(game, []Order{&MarchOrder{Character: who.Name, X: 1, Y: 1}})
+	err, ok := results[0].Err.(*OrderError)
+	if !ok || err.Reason != RejectGameOver {
+		t.Errorf("Expected orders to be rejected once the game is over, got %v", results[0].Err)
+	}
+}
+
+type neverEnding struct{}
+
+func (c neverEnding) Check(game *Game) (string, []string) {
+	return "", nil
+}
+
+func TestSaveKeepsResult(t *testing.T) {
+	game := NewGame(8, 8)
+	StartGame(game)
+	AddWinCondition(game, ResourceTarget{Resources: 50})
+	AddWinCondition(game, TimeLimit{Ticks: 2})
+	AddCulture(game)
+
+	Tick(game, 1.0)
+	loaded := saveAndLoad(t, game)
+	if CurrentPhase(loaded) != PhaseRunning {
+		t.Errorf("Expected the loaded game to be running, got %v", CurrentPhase(loaded))
+	}
+	if !reflect.DeepEqual(loaded.winConditions, game.winConditions) {
+		t.Errorf("Expected win conditions to be saved, got %v", loaded.winConditions)
+	}
+
+	Tick(game, 1.0)
+	loaded = saveAndLoad(t, game)
+	if CurrentPhase(loaded) != PhaseFinished ||
+		!reflect.DeepEqual(FinalResult(loaded), FinalResult(game)) {
+		t.Errorf("Expected the result to be saved, got %v", FinalResult(loaded))
+	}
+
+	AddWinCondition(game, neverEnding{})
+	if err := Save(game, &bytes.Buffer{}); err == nil {
+		t.Errorf("Expected an error saving an unknown win condition")
+	}
+}
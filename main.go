@@ -1,63 +1,143 @@
 package main
 
 import (
+	"encoding/json"
+	"flag"
+	"fmt"
 	"log"
 	"net/http"
+	"os"
 
 	"golang.org/x/net/websocket"
 
+	"github.com/joeatwork/world-of-strategery/bot"
 	"github.com/joeatwork/world-of-strategery/game"
+	"github.com/joeatwork/world-of-strategery/replay"
+	"github.com/joeatwork/world-of-strategery/server"
 )
 
-func notSupportedMarshal(v interface{}) (msg []byte, payloadType byte, err error) {
-	return nil, websocket.UnknownFrame, websocket.ErrNotSupported
-}
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "replay" {
+		dumpReplay(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "bot" {
+		playBot(os.Args[2:])
+		return
+	}
 
-func notSupportedUnmarshal(msg []byte, payloadType byte, v interface{}) (err error) {
-	return websocket.ErrNotSupported
-}
+	addr := flag.String("addr", ":8080", "address to serve games on")
+	replayDir := flag.String("replays", "", "directory to write replays of finished games to")
+	catalogFile := flag.String("catalog", "", "file of character and house types for new games")
+	mapDir := flag.String("maps", "", "directory of maps games can be created on")
+	flag.Parse()
 
-func statusMarshal(v interface{}) (msg []byte, payloadType byte, err error) {
-	return []byte("STATUS"), websocket.TextFrame, nil
+	s := server.NewServer(game.DefaultLoopConfig)
+	if *catalogFile != "" {
+		catalog, err := game.ReadCatalogFile(*catalogFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		s.UseCatalog(catalog)
+	}
+	if *replayDir != "" {
+		s.RecordReplays(*replayDir)
+	}
+	if *mapDir != "" {
+		s.UseMaps(*mapDir)
+	}
+	log.Fatal(http.ListenAndServe(*addr, s))
 }
 
-func ordersUnmarshal(msg []byte, payloadType byte, v interface{}) (err error) {
-	return nil
-}
+// dumpReplay plays a replay file and prints every tick of it, either as the
+// terrain or as GameStatus JSON.
+func dumpReplay(args []string) {
+	flags := flag.NewFlagSet("replay", flag.ExitOnError)
+	format := flags.String("format", "terrain", "how to print each tick, terrain or status")
+	from := flags.Int("from", -1, "first tick to print (default the start of the replay)")
+	to := flags.Int("to", -1, "last tick to print (default the end of the replay)")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s replay [flags] file\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 || (*format != "terrain" && *format != "status") {
+		flags.Usage()
+		os.Exit(2)
+	}
 
-func main() {
-	ordersCodec := websocket.Codec{notSupportedMarshal, ordersUnmarshal}
-	statusCodec := websocket.Codec{statusMarshal, notSupportedUnmarshal}
-
-	gameLoop := game.RunGameLoop()
-
-	handler := websocket.Handler(func(ws *websocket.Conn) {
-		go func() {
-			for !gameLoop.IsStopped() {
-				status := gameLoop.ReadLatestStatus()
-				if err := statusCodec.Send(ws, status); err != nil {
-					log.Printf("can't write, %v", err)
-					gameLoop.Stop()
-				}
-			}
+	f, err := os.Open(flags.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	recorded, err := replay.Read(f)
+	f.Close()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	player, err := replay.NewPlayer(recorded, replay.DefaultCheckpointInterval)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *from >= 0 {
+		if err := player.Seek(*from); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *to < 0 {
+		*to = recorded.End
+	}
 
-			log.Printf("writer terminated")
-		}()
-
-		go func() {
-			for !gameLoop.IsStopped() {
-				var orders []game.Order
-				if err := ordersCodec.Receive(ws, orders); err != nil {
-					log.Printf("can't read, %v", err)
-					gameLoop.Stop()
-				} else {
-					gameLoop.WriteOrders(orders)
-				}
+	encoder := json.NewEncoder(os.Stdout)
+	for player.Tick() <= *to {
+		switch *format {
+		case "terrain":
+			fmt.Printf("tick %d\n", player.Tick())
+			game.DumpTerrain(game.GameTerrain(player.Game()))
+		case "status":
+			if err := encoder.Encode(game.ReadStatus(player.Game())); err != nil {
+				log.Fatal(err)
 			}
+		}
+
+		more, err := player.Step()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if !more {
+			break
+		}
+	}
+}
+
+// playBot joins a game over a websocket and plays it with a bot until the
+// server hangs up.
+func playBot(args []string) {
+	flags := flag.NewFlagSet("bot", flag.ExitOnError)
+	origin := flags.String("origin", "http://localhost/", "origin to connect from")
+	houseType := flags.String("house", bot.DefaultConfig.HouseType, "type of house to build")
+	workerType := flags.String("worker", bot.DefaultConfig.WorkerType,
+		"type of character to put to work, or empty for every character")
+	raiders := flags.Int("raiders", 0, "workers to send raiding even when there's other work")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s bot [flags] ws://host/game/{id}\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
 
-			log.Printf("reader terminated")
-		}()
-	})
+	config := bot.DefaultConfig
+	config.HouseType = *houseType
+	config.WorkerType = *workerType
+	config.Raiders = *raiders
 
-	http.Handle("/game", handler)
+	ws, err := websocket.Dial(flags.Arg(0), "", *origin)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Print(bot.Play(ws, config))
 }
@@ -0,0 +1,304 @@
+package protocol
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/joeatwork/world-of-strategery/game"
+)
+
+// StatusDelta describes a status as its changes from an earlier status the
+// client already has. Seq numbers the statuses sent to a single client, and
+// Base is the Seq of the status the delta changes. Keyframes change an empty
+// status, so they describe everything, and clients need nothing else to
+// rebuild them. Tiles are only included when they've changed, and cultures
+// only when something about them has changed.
+type StatusDelta struct {
+	Seq      int              `json:"seq"`
+	Base     int              `json:"base,omitempty"`
+	Keyframe bool             `json:"keyframe,omitempty"`
+	Tick     int              `json:"tick"`
+	Viewer   string           `json:"viewer,omitempty"`
+	Width    int              `json:"width"`
+	Height   int              `json:"height"`
+	Tiles    []string         `json:"tiles,omitempty"`
+	Cultures []CultureDelta   `json:"cultures,omitempty"`
+	Deposits HouseChanges     `json:"deposits"`
+	Phase    game.Phase       `json:"phase"`
+	Result   *game.GameResult `json:"result,omitempty"`
+}
+
+// CultureDelta is the changes to the status of a single culture.
+type CultureDelta struct {
+	Name          string           `json:"name"`
+	Resources     float64          `json:"resources"`
+	AutoJobs      bool             `json:"autoJobs"`
+	Characters    CharacterChanges `json:"characters"`
+	PlannedHouses HouseChanges     `json:"plannedHouses"`
+	BuiltHouses   HouseChanges     `json:"builtHouses"`
+}
+
+// CharacterChanges lists the characters that are new or have changed. Names
+// is the name of every character, in order, and is null unless characters
+// have come, gone or moved around in the list.
+type CharacterChanges struct {
+	Names   []string               `json:"names"`
+	Changed []game.CharacterStatus `json:"changed,omitempty"`
+}
+
+// HouseChanges lists the houses that are new or have changed. Names is the
+// name of every house, in order, and is null unless houses have come, gone or
+// moved around in the list.
+type HouseChanges struct {
+	Names   []string           `json:"names"`
+	Changed []game.HouseStatus `json:"changed,omitempty"`
+}
+
+// Diff describes next as its changes from base. The returned delta has no
+// Seq or Base, which are up to the caller. Diffing from an empty
+// game.GameStatus makes a keyframe.
+func Diff(base, next game.GameStatus) StatusDelta {
+	ret := StatusDelta{
+		Tick:     next.Tick,
+		Viewer:   next.Viewer,
+		Width:    next.Width,
+		Height:   next.Height,
+		Deposits: diffHouses(base.Deposits, next.Deposits),
+		Phase:    next.Phase,
+		Result:   next.Result,
+	}
+	if !reflect.DeepEqual(base.Tiles, next.Tiles) {
+		ret.Tiles = next.Tiles
+	}
+
+	cultures := make(map[string]game.CultureStatus)
+	for _, culture := range base.Cultures {
+		cultures[culture.Name] = culture
+	}
+	for _, culture := range next.Cultures {
+		old, ok := cultures[culture.Name]
+		if ok && reflect.DeepEqual(old, culture) {
+			continue
+		}
+		ret.Cultures = append(ret.Cultures, CultureDelta{
+			Name:          culture.Name,
+			Resources:     culture.Resources,
+			AutoJobs:      culture.AutoJobs,
+			Characters:    diffCharacters(old.Characters, culture.Characters),
+			PlannedHouses: diffHouses(old.PlannedHouses, culture.PlannedHouses),
+			BuiltHouses:   diffHouses(old.BuiltHouses, culture.BuiltHouses),
+		})
+	}
+
+	return ret
+}
+
+func diffCharacters(base, next []game.CharacterStatus) CharacterChanges {
+	var ret CharacterChanges
+	indexes := make(map[string]int)
+	for i, who := range base {
+		indexes[who.Name] = i
+	}
+
+	reordered := len(base) != len(next)
+	for i, who := range next {
+		j, ok := indexes[who.Name]
+		if !ok || base[j] != who {
+			ret.Changed = append(ret.Changed, who)
+		}
+		if !ok || i != j {
+			reordered = true
+		}
+	}
+
+	if reordered {
+		ret.Names = make([]string, len(next))
+		for i, who := range next {
+			ret.Names[i] = who.Name
+		}
+	}
+	return ret
+}
+
+func diffHouses(base, next []game.HouseStatus) HouseChanges {
+	var ret HouseChanges
+	indexes := make(map[string]int)
+	for i, house := range base {
+		indexes[house.Name] = i
+	}
+
+	reordered := len(base) != len(next)
+	for i, house := range next {
+		j, ok := indexes[house.Name]
+		if !ok || base[j] != house {
+			ret.Changed = append(ret.Changed, house)
+		}
+		if !ok || i != j {
+			reordered = true
+		}
+	}
+
+	if reordered {
+		ret.Names = make([]string, len(next))
+		for i, house := range next {
+			ret.Names[i] = house.Name
+		}
+	}
+	return ret
+}
+
+// Apply rebuilds the status delta describes from base, which should be the
+// status with the delta's Base, or anything at all for keyframes. The result
+// may share tiles with base, so neither should be modified. Apply returns an
+// error if delta doesn't fit base.
+func Apply(base game.GameStatus, delta StatusDelta) (game.GameStatus, error) {
+	if delta.Keyframe {
+		base = game.GameStatus{}
+	}
+
+	ret := game.GameStatus{
+		Tick:     delta.Tick,
+		Viewer:   delta.Viewer,
+		Width:    delta.Width,
+		Height:   delta.Height,
+		Tiles:    base.Tiles,
+		Cultures: append(make([]game.CultureStatus, 0, len(base.Cultures)), base.Cultures...),
+		Phase:    delta.Phase,
+		Result:   delta.Result,
+	}
+	if delta.Tiles != nil {
+		ret.Tiles = delta.Tiles
+	}
+
+	var err error
+	if ret.Deposits, err = applyHouses(base.Deposits, delta.Deposits); err != nil {
+		return game.GameStatus{}, err
+	}
+
+	indexes := make(map[string]int)
+	for i, culture := range ret.Cultures {
+		indexes[culture.Name] = i
+	}
+	for _, change := range delta.Cultures {
+		i, ok := indexes[change.Name]
+		if !ok {
+			i = len(ret.Cultures)
+			ret.Cultures = append(ret.Cultures, game.CultureStatus{Name: change.Name})
+		}
+
+		culture := &ret.Cultures[i]
+		culture.Resources = change.Resources
+		culture.AutoJobs = change.AutoJobs
+		if culture.Characters, err = applyCharacters(culture.Characters, change.Characters); err != nil {
+			return game.GameStatus{}, err
+		}
+		if culture.PlannedHouses, err = applyHouses(culture.PlannedHouses, change.PlannedHouses); err != nil {
+			return game.GameStatus{}, err
+		}
+		if culture.BuiltHouses, err = applyHouses(culture.BuiltHouses, change.BuiltHouses); err != nil {
+			return game.GameStatus{}, err
+		}
+	}
+
+	return ret, nil
+}
+
+func applyCharacters(base []game.CharacterStatus, changes CharacterChanges) ([]game.CharacterStatus, error) {
+	characters := make(map[string]game.CharacterStatus)
+	for _, who := range base {
+		characters[who.Name] = who
+	}
+	for _, who := range changes.Changed {
+		characters[who.Name] = who
+	}
+
+	names := changes.Names
+	if names == nil {
+		if len(characters) != len(base) {
+			return nil, fmt.Errorf("delta adds characters without naming them all")
+		}
+		names = make([]string, len(base))
+		for i, who := range base {
+			names[i] = who.Name
+		}
+	}
+
+	ret := make([]game.CharacterStatus, len(names))
+	for i, name := range names {
+		who, ok := characters[name]
+		if !ok {
+			return nil, fmt.Errorf("delta names unknown character %q", name)
+		}
+		ret[i] = who
+	}
+	return ret, nil
+}
+
+func applyHouses(base []game.HouseStatus, changes HouseChanges) ([]game.HouseStatus, error) {
+	houses := make(map[string]game.HouseStatus)
+	for _, house := range base {
+		houses[house.Name] = house
+	}
+	for _, house := range changes.Changed {
+		houses[house.Name] = house
+	}
+
+	names := changes.Names
+	if names == nil {
+		if len(houses) != len(base) {
+			return nil, fmt.Errorf("delta adds houses without naming them all")
+		}
+		names = make([]string, len(base))
+		for i, house := range base {
+			names[i] = house.Name
+		}
+	}
+
+	ret := make([]game.HouseStatus, len(names))
+	for i, name := range names {
+		house, ok := houses[name]
+		if !ok {
+			return nil, fmt.Errorf("delta names unknown house %q", name)
+		}
+		ret[i] = house
+	}
+	return ret, nil
+}
+
+// StatusTracker rebuilds the statuses a client is sent as StatusDeltas. It
+// keeps every status that later deltas might be based on: servers never base
+// a delta on a status older than the base of a delta or keyframe they've
+// already sent. The zero StatusTracker is ready to use.
+type StatusTracker struct {
+	statuses map[int]game.GameStatus // by Seq
+}
+
+// Apply rebuilds the status delta describes, and remembers it. It returns an
+// error if the tracker doesn't have the delta's base, in which case the
+// client should send a Resync and wait for a keyframe.
+func (t *StatusTracker) Apply(delta StatusDelta) (game.GameStatus, error) {
+	oldest := delta.Base
+	if delta.Keyframe {
+		oldest = delta.Seq
+	}
+	base, ok := t.statuses[delta.Base]
+	if !ok && !delta.Keyframe {
+		return game.GameStatus{}, fmt.Errorf("don't have base status %d", delta.Base)
+	}
+
+	status, err := Apply(base, delta)
+	if err != nil {
+		return game.GameStatus{}, err
+	}
+
+	if t.statuses == nil {
+		t.statuses = make(map[int]game.GameStatus)
+	}
+	for seq := range t.statuses {
+		if seq < oldest {
+			delete(t.statuses, seq)
+		}
+	}
+	t.statuses[delta.Seq] = status
+	return status, nil
+}
@@ -0,0 +1,170 @@
+package protocol
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/joeatwork/world-of-strategery/game"
+)
+
+func character(name string, x int) game.CharacterStatus {
+	return game.CharacterStatus{
+		Name:     name,
+		Type:     "worker",
+		Location: game.Location{X: x},
+		Width:    1,
+		Height:   1,
+		Health:   10,
+	}
+}
+
+func house(name string, resources float64) game.HouseStatus {
+	return game.HouseStatus{
+		Name:          name,
+		Type:          "house",
+		Width:         2,
+		Height:        2,
+		ResourcesLeft: resources,
+		MaxResources:  100,
+	}
+}
+
+// statuses returns a status and a later one, where the reds have lost a
+// character, gained one, moved one and worked on a house, a deposit is gone,
+// and the greens haven't changed at all.
+func statuses() (game.GameStatus, game.GameStatus) {
+	base := game.GameStatus{
+		Tick:   3,
+		Width:  8,
+		Height: 2,
+		Tiles:  []string{"........", "........"},
+		Cultures: []game.CultureStatus{
+			{
+				Name:          "reds",
+				Resources:     5,
+				Characters:    []game.CharacterStatus{character("red1", 0), character("red2", 1)},
+				PlannedHouses: []game.HouseStatus{house("redPlan", 0)},
+				BuiltHouses:   []game.HouseStatus{house("redHouse", 10)},
+			},
+			{
+				Name:          "greens",
+				Characters:    []game.CharacterStatus{character("green1", 7)},
+				PlannedHouses: []game.HouseStatus{},
+				BuiltHouses:   []game.HouseStatus{},
+			},
+		},
+		Deposits: []game.HouseStatus{house("deposit1", 50), house("deposit2", 50)},
+		Phase:    game.PhaseRunning,
+	}
+
+	next := base
+	next.Tick = 4
+	next.Cultures = []game.CultureStatus{base.Cultures[0], base.Cultures[1]}
+	next.Cultures[0].Resources = 4
+	next.Cultures[0].Characters = []game.CharacterStatus{character("red1", 2), character("red3", 4)}
+	next.Cultures[0].BuiltHouses = []game.HouseStatus{house("redHouse", 11)}
+	next.Deposits = []game.HouseStatus{house("deposit1", 50)}
+	return base, next
+}
+
+func TestDiffRoundTrip(t *testing.T) {
+	base, next := statuses()
+	delta := Diff(base, next)
+
+	if delta.Tiles != nil || len(delta.Cultures) != 1 || delta.Cultures[0].Name != "reds" {
+		t.Errorf("Expected only the reds to have changed, got %v", delta)
+	}
+	reds := delta.Cultures[0]
+	if !reflect.DeepEqual(reds.Characters.Names, []string{"red1", "red3"}) ||
+		len(reds.Characters.Changed) != 2 {
+		t.Errorf("Unexpected character changes %v", reds.Characters)
+	}
+	if reds.PlannedHouses.Names != nil || reds.PlannedHouses.Changed != nil ||
+		reds.BuiltHouses.Names != nil || len(reds.BuiltHouses.Changed) != 1 {
+		t.Errorf("Expected only the built house to have changed, got %v", reds)
+	}
+	if !reflect.DeepEqual(delta.Deposits, HouseChanges{Names: []string{"deposit1"}}) {
+		t.Errorf("Expected a deposit to be gone, got %v", delta.Deposits)
+	}
+
+	msg, err := Encode(delta)
+	if err != nil {
+		t.Fatalf("Can't encode %v: %v", delta, err)
+	}
+	decoded, err := DecodeFromServer(msg)
+	if err != nil {
+		t.Fatalf("Can't decode %s: %v", msg, err)
+	}
+	applied, err := Apply(base, decoded.(StatusDelta))
+	if err != nil {
+		t.Fatalf("Can't apply %s: %v", msg, err)
+	}
+	if !reflect.DeepEqual(applied, next) {
+		t.Errorf("Expected %v, got %v", next, applied)
+	}
+}
+
+func TestKeyframe(t *testing.T) {
+	base, next := statuses()
+	keyframe := Diff(game.GameStatus{}, next)
+	keyframe.Keyframe = true
+
+	encoded, err := json.Marshal(keyframe)
+	if err != nil {
+		t.Fatalf("Can't encode keyframe: %v", err)
+	}
+	var decoded StatusDelta
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatalf("Can't decode keyframe %s: %v", encoded, err)
+	}
+
+	applied, err := Apply(base, decoded)
+	if err != nil {
+		t.Fatalf("Can't apply keyframe: %v", err)
+	}
+	if !reflect.DeepEqual(applied, next) {
+		t.Errorf("Expected %v, got %v", next, applied)
+	}
+}
+
+func TestApplyToWrongBase(t *testing.T) {
+	base, next := statuses()
+	if _, err := Apply(game.GameStatus{}, Diff(base, next)); err == nil {
+		t.Errorf("Expected an error applying a delta to the wrong status")
+	}
+}
+
+func TestStatusTracker(t *testing.T) {
+	base, next := statuses()
+	var tracker StatusTracker
+
+	keyframe := Diff(game.GameStatus{}, base)
+	keyframe.Seq = 1
+	keyframe.Keyframe = true
+	if status, err := tracker.Apply(keyframe); err != nil || !reflect.DeepEqual(status, base) {
+		t.Fatalf("Can't apply keyframe, got %v, %v", status, err)
+	}
+
+	delta := Diff(base, next)
+	delta.Seq = 2
+	delta.Base = 1
+	if status, err := tracker.Apply(delta); err != nil || !reflect.DeepEqual(status, next) {
+		t.Fatalf("Can't apply delta, got %v, %v", status, err)
+	}
+
+	later := Diff(next, next)
+	later.Seq = 3
+	later.Base = 2
+	if _, err := tracker.Apply(later); err != nil {
+		t.Fatalf("Can't apply delta based on a delta: %v", err)
+	}
+
+	// Once a delta is based on 2, nothing will be based on 1 again.
+	stale := Diff(base, next)
+	stale.Seq = 4
+	stale.Base = 1
+	if _, err := tracker.Apply(stale); err == nil {
+		t.Errorf("Expected an error applying a delta to a forgotten status")
+	}
+}
@@ -0,0 +1,428 @@
+// Package protocol describes the JSON messages passed between the server and
+// game clients. Every message is a JSON object with a "version" and a "type",
+// along with fields specific to that type. Clients begin by joining a game,
+// either as a player or as a spectator,
+//
+//	{"version": 1, "type": "join", "role": "player"}
+//
+// and the server replies with a joined message naming the player's culture.
+// Players can then send orders like
+//
+//	{"version": 1, "type": "march", "character": "...", "x": 3, "y": 4}
+//
+// and the server sends back statuses, the results of orders, errors, and
+// events as they happen. Once the game is finished, the server sends a
+// gameOver message with its result.
+//
+// Statuses of big games are big, so clients can join with "deltas": true to
+// be sent delta messages instead, which only describe what has changed since
+// an earlier status. Every delta has a seq number, and clients acknowledge
+// the statuses they've rebuilt with
+//
+//	{"version": 1, "type": "ack", "seq": 12}
+//
+// so that later deltas can be based on them. Deltas are otherwise based on
+// the most recent keyframe, a delta that describes the entire status, which
+// the server sends every so often, or whenever a client that has lost track
+// of things asks with a resync message.
+package protocol
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/joeatwork/world-of-strategery/game"
+)
+
+// Version is the only protocol version this package speaks.
+const Version = 1
+
+// Message types. Clients send join, target, march, plan, jobs, priority,
+// attack, train, ack and resync messages, the server sends joined, status,
+// delta, result, error, events and gameOver messages.
+const (
+	TypeJoin     = "join"
+	TypeJoined   = "joined"
+	TypeTarget   = "target"
+	TypeMarch    = "march"
+	TypePlan     = "plan"
+	TypeJobs     = "jobs"
+	TypePriority = "priority"
+	TypeAttack   = "attack"
+	TypeTrain    = "train"
+	TypeAck      = "ack"
+	TypeResync   = "resync"
+	TypeStatus   = "status"
+	TypeDelta    = "delta"
+	TypeResult   = "result"
+	TypeError    = "error"
+	TypeGameOver = "gameOver"
+	TypeEvents   = "events"
+)
+
+// Reasons a message from a client might be refused, in addition to the
+// game.Reject* reasons for orders the game won't accept.
+const (
+	ReasonMalformed          = "malformed message"
+	ReasonUnsupportedVersion = "unsupported version"
+	ReasonUnknownType        = "unknown message type"
+	ReasonInternal           = "internal error"
+	ReasonNotJoined          = "must join before giving orders"
+	ReasonAlreadyJoined      = "already joined"
+	ReasonUnknownRole        = "unknown role"
+	ReasonSpectator          = "spectators can't give orders"
+	ReasonGameFull           = "game is full"
+)
+
+// Roles a client can join a game as. Players control a culture of their own,
+// spectators can only watch.
+const (
+	RolePlayer    = "player"
+	RoleSpectator = "spectator"
+)
+
+// Join is a client's request to join a game. Clients that ask for Deltas are
+// sent StatusDeltas instead of full statuses.
+type Join struct {
+	Role   string `json:"role"`
+	Deltas bool   `json:"deltas,omitempty"`
+}
+
+// Ack tells the server that a client has rebuilt the status with sequence
+// number Seq, so that deltas can be based on it.
+type Ack struct {
+	Seq int `json:"seq"`
+}
+
+// Resync asks the server for a keyframe, for clients that have lost track of
+// their statuses.
+type Resync struct{}
+
+// Joined tells a client they've joined a game. Culture is the name of the
+// culture a player controls, and is empty for spectators.
+type Joined struct {
+	Role    string `json:"role"`
+	Culture string `json:"culture,omitempty"`
+}
+
+// Error is a structured explanation of why a message or order was refused,
+// suitable for sending back to the client that sent it.
+type Error struct {
+	Reason string `json:"reason"`
+	Name   string `json:"name,omitempty"`
+	Detail string `json:"detail,omitempty"`
+}
+
+func (e *Error) Error() string {
+	if e.Detail == "" {
+		return e.Reason
+	}
+	return fmt.Sprintf("%s: %s", e.Reason, e.Detail)
+}
+
+type header struct {
+	Version int    `json:"version"`
+	Type    string `json:"type"`
+}
+
+type statusMessage struct {
+	header
+	Status game.GameStatus `json:"status"`
+}
+
+type deltaMessage struct {
+	header
+	Delta StatusDelta `json:"delta"`
+}
+
+type resultMessage struct {
+	header
+	Tick      int        `json:"tick"`
+	OrderType string     `json:"orderType"`
+	Order     game.Order `json:"order"`
+	Accepted  bool       `json:"accepted"`
+	Error     *Error     `json:"error,omitempty"`
+}
+
+type joinMessage struct {
+	header
+	Join
+}
+
+type ackMessage struct {
+	header
+	Ack
+}
+
+type joinedMessage struct {
+	header
+	Joined
+}
+
+type errorMessage struct {
+	header
+	Error *Error `json:"error"`
+}
+
+type eventsMessage struct {
+	header
+	Events []game.Event `json:"events"`
+}
+
+type gameOverMessage struct {
+	header
+	Result game.GameResult `json:"result"`
+}
+
+func newOrder(messageType string) game.Order {
+	switch messageType {
+	case TypeTarget:
+		return &game.TargetOrder{}
+	case TypeMarch:
+		return &game.MarchOrder{}
+	case TypePlan:
+		return &game.PlanOrder{}
+	case TypeJobs:
+		return &game.JobsOrder{}
+	case TypePriority:
+		return &game.PriorityOrder{}
+	case TypeAttack:
+		return &game.AttackOrder{}
+	case TypeTrain:
+		return &game.TrainOrder{}
+	}
+	return nil
+}
+
+func orderType(order game.Order) string {
+	switch order.(type) {
+	case *game.TargetOrder:
+		return TypeTarget
+	case *game.MarchOrder:
+		return TypeMarch
+	case *game.PlanOrder:
+		return TypePlan
+	case *game.JobsOrder:
+		return TypeJobs
+	case *game.PriorityOrder:
+		return TypePriority
+	case *game.AttackOrder:
+		return TypeAttack
+	case *game.TrainOrder:
+		return TypeTrain
+	}
+	return ""
+}
+
+// Decode reads a single message sent by a client, which will be a *Join, an
+// *Ack, a *Resync or a game.Order. If the message can't be understood, the returned
+// error is an *Error.
+func Decode(msg []byte) (interface{}, error) {
+	var h header
+	if err := json.Unmarshal(msg, &h); err != nil {
+		return nil, &Error{Reason: ReasonMalformed, Detail: err.Error()}
+	}
+	if h.Version != Version {
+		return nil, &Error{
+			Reason: ReasonUnsupportedVersion,
+			Detail: fmt.Sprintf("got %d, want %d", h.Version, Version),
+		}
+	}
+
+	var ret interface{}
+	switch h.Type {
+	case TypeJoin:
+		ret = &Join{}
+	case TypeAck:
+		ret = &Ack{}
+	case TypeResync:
+		ret = &Resync{}
+	default:
+		ret = newOrder(h.Type)
+		if ret == nil {
+			return nil, &Error{Reason: ReasonUnknownType, Name: h.Type}
+		}
+	}
+
+	if err := json.Unmarshal(msg, ret); err != nil {
+		return nil, &Error{Reason: ReasonMalformed, Detail: err.Error()}
+	}
+
+	return ret, nil
+}
+
+// DecodeOrder reads a single order sent by a client. Messages that aren't
+// orders are refused with an *Error.
+func DecodeOrder(msg []byte) (game.Order, error) {
+	decoded, err := Decode(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	order, ok := decoded.(game.Order)
+	if !ok {
+		var h header
+		json.Unmarshal(msg, &h)
+		return nil, &Error{Reason: ReasonUnknownType, Name: h.Type}
+	}
+	return order, nil
+}
+
+// ToError converts any error into an *Error that can be sent to clients.
+func ToError(err error) *Error {
+	switch e := err.(type) {
+	case *Error:
+		return e
+	case *game.OrderError:
+		return &Error{Reason: e.Reason, Name: e.Name}
+	}
+	return &Error{Reason: ReasonInternal, Detail: err.Error()}
+}
+
+// EncodeOrder builds a message for the server from an order, for use by
+// clients.
+func EncodeOrder(order game.Order) ([]byte, error) {
+	t := orderType(order)
+	if t == "" {
+		return nil, fmt.Errorf("can't encode %T as a message", order)
+	}
+
+	// Orders are sent as their own fields alongside the header.
+	encoded, err := json.Marshal(order)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(encoded, &fields); err != nil {
+		return nil, err
+	}
+	fields["version"] = Version
+	fields["type"] = t
+	return json.Marshal(fields)
+}
+
+// Result is the outcome of an order, as received by a client.
+type Result struct {
+	Tick      int    `json:"tick"`
+	OrderType string `json:"orderType"`
+	Accepted  bool   `json:"accepted"`
+	Error     *Error `json:"error,omitempty"`
+}
+
+// DecodeFromServer reads a single message sent by the server, for use by
+// clients. The message will be a Joined, a game.GameStatus, a StatusDelta, a
+// Result, a []game.Event, a game.GameResult or an *Error. Errors sent by the
+// server are returned as the message, not as the error, which is only for
+// messages that can't be understood.
+func DecodeFromServer(msg []byte) (interface{}, error) {
+	var h header
+	if err := json.Unmarshal(msg, &h); err != nil {
+		return nil, &Error{Reason: ReasonMalformed, Detail: err.Error()}
+	}
+	if h.Version != Version {
+		return nil, &Error{
+			Reason: ReasonUnsupportedVersion,
+			Detail: fmt.Sprintf("got %d, want %d", h.Version, Version),
+		}
+	}
+
+	var ret interface{}
+	var err error
+	switch h.Type {
+	case TypeJoined:
+		var decoded joinedMessage
+		err = json.Unmarshal(msg, &decoded)
+		ret = decoded.Joined
+	case TypeStatus:
+		var decoded statusMessage
+		err = json.Unmarshal(msg, &decoded)
+		ret = decoded.Status
+	case TypeDelta:
+		var decoded deltaMessage
+		err = json.Unmarshal(msg, &decoded)
+		ret = decoded.Delta
+	case TypeResult:
+		var decoded Result
+		err = json.Unmarshal(msg, &decoded)
+		ret = decoded
+	case TypeError:
+		var decoded errorMessage
+		err = json.Unmarshal(msg, &decoded)
+		ret = decoded.Error
+	case TypeEvents:
+		var decoded eventsMessage
+		err = json.Unmarshal(msg, &decoded)
+		ret = decoded.Events
+	case TypeGameOver:
+		var decoded gameOverMessage
+		err = json.Unmarshal(msg, &decoded)
+		ret = decoded.Result
+	default:
+		return nil, &Error{Reason: ReasonUnknownType, Name: h.Type}
+	}
+	if err != nil {
+		return nil, &Error{Reason: ReasonMalformed, Detail: err.Error()}
+	}
+
+	return ret, nil
+}
+
+// Encode builds a message for clients from a game.GameStatus, a StatusDelta,
+// a game.OrderResult, a []game.Event, a game.GameResult, a Joined, or an
+// error. Join, Ack and Resync messages can also be encoded, for use by
+// clients.
+func Encode(v interface{}) ([]byte, error) {
+	switch value := v.(type) {
+	case Join:
+		return json.Marshal(joinMessage{header{Version, TypeJoin}, value})
+	case Ack:
+		return json.Marshal(ackMessage{header{Version, TypeAck}, value})
+	case Resync:
+		return json.Marshal(header{Version, TypeResync})
+	case Joined:
+		return json.Marshal(joinedMessage{header{Version, TypeJoined}, value})
+	case game.GameStatus:
+		return json.Marshal(statusMessage{
+			header: header{Version, TypeStatus},
+			Status: value,
+		})
+	case StatusDelta:
+		return json.Marshal(deltaMessage{
+			header: header{Version, TypeDelta},
+			Delta:  value,
+		})
+	case []game.Event:
+		return json.Marshal(eventsMessage{
+			header: header{Version, TypeEvents},
+			Events: value,
+		})
+	case game.GameResult:
+		return json.Marshal(gameOverMessage{
+			header: header{Version, TypeGameOver},
+			Result: value,
+		})
+	case game.OrderResult:
+		order := value.Order
+		if player, ok := order.(*game.PlayerOrder); ok {
+			order = player.Order
+		}
+		msg := resultMessage{
+			header:    header{Version, TypeResult},
+			Tick:      value.Tick,
+			OrderType: orderType(order),
+			Order:     order,
+			Accepted:  value.Err == nil,
+		}
+		if value.Err != nil {
+			msg.Error = ToError(value.Err)
+		}
+		return json.Marshal(msg)
+	case error:
+		return json.Marshal(errorMessage{
+			header: header{Version, TypeError},
+			Error:  ToError(value),
+		})
+	}
+
+	return nil, fmt.Errorf("can't encode %T as a message", v)
+}
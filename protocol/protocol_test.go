@@ -0,0 +1,273 @@
+package protocol
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/joeatwork/world-of-strategery/game"
+)
+
+func TestDecodeOrder(t *testing.T) {
+	orders := []struct {
+		msg   string
+		order game.Order
+	}{
+		{
+			`{"version":1,"type":"target","character":"red","target":"redHouse"}`,
+			&game.TargetOrder{Character: "red", Target: "redHouse"},
+		},
+		{
+			`{"version":1,"type":"march","character":"red","x":3,"y":4}`,
+			&game.MarchOrder{Character: "red", X: 3, Y: 4},
+		},
+		{
+			`{"version":1,"type":"plan","culture":"reds","houseType":"hut","x":5,"y":6}`,
+			&game.PlanOrder{Culture: "reds", HouseType: "hut", X: 5, Y: 6},
+		},
+		{
+			`{"version":1,"type":"jobs","culture":"reds","enabled":true}`,
+			&game.JobsOrder{Culture: "reds", Enabled: true},
+		},
+		{
+			`{"version":1,"type":"priority","house":"redHouse","priority":2}`,
+			&game.PriorityOrder{House: "redHouse", Priority: 2},
+		},
+		{
+			`{"version":1,"type":"attack","character":"red","target":"green"}`,
+			&game.AttackOrder{Character: "red", Target: "green"},
+		},
+		{
+			`{"version":1,"type":"train","house":"redHouse"}`,
+			&game.TrainOrder{House: "redHouse"},
+		},
+	}
+
+	for _, expected := range orders {
+		order, err := DecodeOrder([]byte(expected.msg))
+		if err != nil {
+			t.Errorf("Can't decode %s: %v", expected.msg, err)
+			continue
+		}
+		if !reflect.DeepEqual(order, expected.order) {
+			t.Errorf("Decoded %s as %v, expected %v",
+				expected.msg, order, expected.order)
+		}
+	}
+}
+
+func TestDecodeOrderRejected(t *testing.T) {
+	rejections := []struct {
+		msg    string
+		reason string
+	}{
+		{`not json`, ReasonMalformed},
+		{`{"version":1,"type":"march","x":"three"}`, ReasonMalformed},
+		{`{"type":"march","character":"red","x":3,"y":4}`, ReasonUnsupportedVersion},
+		{`{"version":2,"type":"march","character":"red","x":3,"y":4}`, ReasonUnsupportedVersion},
+		{`{"version":1,"type":"dance"}`, ReasonUnknownType},
+		{`{"version":1,"type":"status"}`, ReasonUnknownType},
+	}
+
+	for _, rejection := range rejections {
+		_, err := DecodeOrder([]byte(rejection.msg))
+		protocolErr, ok := err.(*Error)
+		if !ok || protocolErr.Reason != rejection.reason {
+			t.Errorf("Expected %s to fail with %q, got %v",
+				rejection.msg, rejection.reason, err)
+		}
+	}
+}
+
+func TestEncodeStatus(t *testing.T) {
+	status := game.GameStatus{Tick: 12, Width: 16, Height: 8}
+	msg, err := Encode(status)
+	if err != nil {
+		t.Fatalf("Can't encode status: %v", err)
+	}
+
+	var decoded struct {
+		Version int             `json:"version"`
+		Type    string          `json:"type"`
+		Status  game.GameStatus `json:"status"`
+	}
+	if err := json.Unmarshal(msg, &decoded); err != nil {
+		t.Fatalf("Can't decode status message %s: %v", msg, err)
+	}
+	if decoded.Version != Version || decoded.Type != TypeStatus {
+		t.Errorf("Unexpected status header in %s", msg)
+	}
+	if !reflect.DeepEqual(decoded.Status, status) {
+		t.Errorf("Status didn't round trip, got %v", decoded.Status)
+	}
+}
+
+func TestEncodeRejectedResult(t *testing.T) {
+	result := game.OrderResult{
+		Tick:  3,
+		Order: &game.MarchOrder{Character: "nobody", X: 1, Y: 2},
+		Err:   &game.OrderError{Reason: game.RejectUnknownCharacter, Name: "nobody"},
+	}
+	msg, err := Encode(result)
+	if err != nil {
+		t.Fatalf("Can't encode result: %v", err)
+	}
+
+	var decoded struct {
+		Type      string `json:"type"`
+		Tick      int    `json:"tick"`
+		OrderType string `json:"orderType"`
+		Accepted  bool   `json:"accepted"`
+		Error     *Error `json:"error"`
+	}
+	if err := json.Unmarshal(msg, &decoded); err != nil {
+		t.Fatalf("Can't decode result message %s: %v", msg, err)
+	}
+	if decoded.Type != TypeResult || decoded.Tick != 3 ||
+		decoded.OrderType != TypeMarch || decoded.Accepted {
+		t.Errorf("Unexpected result message %s", msg)
+	}
+	expectErr := &Error{Reason: game.RejectUnknownCharacter, Name: "nobody"}
+	if !reflect.DeepEqual(decoded.Error, expectErr) {
+		t.Errorf("Expected error %v, got %v", expectErr, decoded.Error)
+	}
+}
+
+func TestEncodeError(t *testing.T) {
+	msg, err := Encode(errors.New("oops"))
+	if err != nil {
+		t.Fatalf("Can't encode error: %v", err)
+	}
+
+	var decoded struct {
+		Type  string `json:"type"`
+		Error *Error `json:"error"`
+	}
+	if err := json.Unmarshal(msg, &decoded); err != nil {
+		t.Fatalf("Can't decode error message %s: %v", msg, err)
+	}
+	if decoded.Type != TypeError || decoded.Error.Reason != ReasonInternal {
+		t.Errorf("Unexpected error message %s", msg)
+	}
+}
+
+func TestDecodeJoin(t *testing.T) {
+	decoded, err := Decode([]byte(`{"version":1,"type":"join","role":"spectator"}`))
+	if err != nil {
+		t.Fatalf("Can't decode join: %v", err)
+	}
+	if join, ok := decoded.(*Join); !ok || join.Role != RoleSpectator {
+		t.Errorf("Unexpected join %v", decoded)
+	}
+
+	_, err = DecodeOrder([]byte(`{"version":1,"type":"join","role":"player"}`))
+	if protocolErr, ok := err.(*Error); !ok || protocolErr.Reason != ReasonUnknownType {
+		t.Errorf("Join was decoded as an order: %v", err)
+	}
+}
+
+func TestEncodeAckAndResync(t *testing.T) {
+	for _, message := range []interface{}{Ack{Seq: 12}, Resync{}} {
+		msg, err := Encode(message)
+		if err != nil {
+			t.Fatalf("Can't encode %v: %v", message, err)
+		}
+		decoded, err := Decode(msg)
+		if err != nil {
+			t.Fatalf("Can't decode %s: %v", msg, err)
+		}
+		if reflect.ValueOf(decoded).Elem().Interface() != message {
+			t.Errorf("Decoded %s as %v, expected %v", msg, decoded, message)
+		}
+	}
+}
+
+func TestEncodeResultUnwrapsPlayerOrders(t *testing.T) {
+	march := &game.MarchOrder{Character: "red", X: 1, Y: 2}
+	msg, err := Encode(game.OrderResult{
+		Order: &game.PlayerOrder{Culture: "reds", Order: march},
+	})
+	if err != nil {
+		t.Fatalf("Can't encode result: %v", err)
+	}
+
+	var decoded struct {
+		OrderType string          `json:"orderType"`
+		Order     game.MarchOrder `json:"order"`
+	}
+	if err := json.Unmarshal(msg, &decoded); err != nil {
+		t.Fatalf("Can't decode result message %s: %v", msg, err)
+	}
+	if decoded.OrderType != TypeMarch || decoded.Order != *march {
+		t.Errorf("Unexpected result message %s", msg)
+	}
+}
+
+func TestEncodeOrderRoundTrip(t *testing.T) {
+	orders := []game.Order{
+		&game.TargetOrder{Character: "red", Target: "redHouse"},
+		&game.PlanOrder{Culture: "reds", HouseType: "hut", X: 5, Y: 6},
+		&game.PriorityOrder{House: "redHouse", Priority: 2},
+	}
+	for _, order := range orders {
+		msg, err := EncodeOrder(order)
+		if err != nil {
+			t.Fatalf("Can't encode %v: %v", order, err)
+		}
+		decoded, err := DecodeOrder(msg)
+		if err != nil {
+			t.Fatalf("Can't decode %s: %v", msg, err)
+		}
+		if !reflect.DeepEqual(decoded, order) {
+			t.Errorf("Decoded %s as %v, expected %v", msg, decoded, order)
+		}
+	}
+
+	if _, err := EncodeOrder(&game.CultureOrder{}); err == nil {
+		t.Errorf("Expected an error encoding an order clients can't send")
+	}
+}
+
+func TestDecodeFromServer(t *testing.T) {
+	messages := []interface{}{
+		Joined{Role: RolePlayer, Culture: "reds"},
+		game.GameStatus{Tick: 3, Viewer: "reds", Width: 4, Height: 4},
+		game.OrderResult{Tick: 2, Order: &game.MarchOrder{Character: "red"}},
+		&Error{Reason: ReasonSpectator},
+		game.GameResult{Tick: 9, Reason: game.EndTimeLimit, Winners: []string{"reds"},
+			Scores: map[string]float64{"reds": 4, "greens": 2}},
+		[]game.Event{{Tick: 4, Type: game.EventHouseCompleted, Culture: "reds", Subject: "redHouse"}},
+		StatusDelta{Seq: 2, Base: 1, Tick: 5, Width: 4, Height: 4,
+			Deposits: HouseChanges{Names: []string{}}},
+	}
+	expected := []interface{}{
+		Joined{Role: RolePlayer, Culture: "reds"},
+		game.GameStatus{Tick: 3, Viewer: "reds", Width: 4, Height: 4},
+		Result{Tick: 2, OrderType: TypeMarch, Accepted: true},
+		&Error{Reason: ReasonSpectator},
+		game.GameResult{Tick: 9, Reason: game.EndTimeLimit, Winners: []string{"reds"},
+			Scores: map[string]float64{"reds": 4, "greens": 2}},
+		[]game.Event{{Tick: 4, Type: game.EventHouseCompleted, Culture: "reds", Subject: "redHouse"}},
+		StatusDelta{Seq: 2, Base: 1, Tick: 5, Width: 4, Height: 4,
+			Deposits: HouseChanges{Names: []string{}}},
+	}
+
+	for i, message := range messages {
+		msg, err := Encode(message)
+		if err != nil {
+			t.Fatalf("Can't encode %v: %v", message, err)
+		}
+		decoded, err := DecodeFromServer(msg)
+		if err != nil {
+			t.Fatalf("Can't decode %s: %v", msg, err)
+		}
+		if !reflect.DeepEqual(decoded, expected[i]) {
+			t.Errorf("Decoded %s as %#v, expected %#v", msg, decoded, expected[i])
+		}
+	}
+
+	if _, err := DecodeFromServer([]byte(`{"version":1,"type":"march"}`)); err == nil {
+		t.Errorf("Expected an error decoding a client message")
+	}
+}
@@ -0,0 +1,359 @@
+// Package replay records games as a starting state plus the orders applied to
+// them, and plays those recordings back through game.Tick. Since games are
+// deterministic, a replay reproduces every tick of the original game exactly.
+package replay
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/joeatwork/world-of-strategery/game"
+)
+
+// Version is the version of the replay files written by Write. Read also
+// reads version 1 files, which were written before replays recorded when
+// their game started.
+const Version = 2
+
+// DefaultCheckpointInterval is how many ticks a Player plays between
+// checkpoints, if it isn't told otherwise.
+const DefaultCheckpointInterval = 100
+
+// Names for orders in replay files.
+const (
+	typeTarget   = "target"
+	typeMarch    = "march"
+	typePlan     = "plan"
+	typeCulture  = "culture"
+	typeJobs     = "jobs"
+	typePriority = "priority"
+	typeAttack   = "attack"
+	typeTrain    = "train"
+)
+
+// Entry is an order, and the tick it was applied after.
+type Entry struct {
+	Tick  int
+	Order game.Order
+}
+
+// Replay is a recorded game. Initial is the game as written by game.Save
+// when recording started, which includes the seed for fair scheduling.
+// Orders are applied in sequence, each after the Entry's Tick has run, and
+// the recording ends with the orders applied after the tick numbered End.
+// Games recorded from the lobby leave it after the orders for the tick
+// numbered Started, which is negative if they never did.
+type Replay struct {
+	Initial json.RawMessage
+	Orders  []Entry
+	End     int
+	Started int
+}
+
+type savedEntry struct {
+	Tick  int             `json:"tick"`
+	Type  string          `json:"type"`
+	Order json.RawMessage `json:"order"`
+}
+
+type savedReplay struct {
+	Version int             `json:"version"`
+	Initial json.RawMessage `json:"initial"`
+	Orders  []savedEntry    `json:"orders"`
+	End     int             `json:"end"`
+	Started *int            `json:"started,omitempty"`
+}
+
+func newOrder(orderType string) game.Order {
+	switch orderType {
+	case typeTarget:
+		return &game.TargetOrder{}
+	case typeMarch:
+		return &game.MarchOrder{}
+	case typePlan:
+		return &game.PlanOrder{}
+	case typeCulture:
+		return &game.CultureOrder{}
+	case typeJobs:
+		return &game.JobsOrder{}
+	case typePriority:
+		return &game.PriorityOrder{}
+	case typeAttack:
+		return &game.AttackOrder{}
+	case typeTrain:
+		return &game.TrainOrder{}
+	}
+	return nil
+}
+
+func orderType(order game.Order) string {
+	switch order.(type) {
+	case *game.TargetOrder:
+		return typeTarget
+	case *game.MarchOrder:
+		return typeMarch
+	case *game.PlanOrder:
+		return typePlan
+	case *game.CultureOrder:
+		return typeCulture
+	case *game.JobsOrder:
+		return typeJobs
+	case *game.PriorityOrder:
+		return typePriority
+	case *game.AttackOrder:
+		return typeAttack
+	case *game.TrainOrder:
+		return typeTrain
+	}
+	return ""
+}
+
+// Write writes r to w as JSON.
+func (r *Replay) Write(w io.Writer) error {
+	doc := savedReplay{
+		Version: Version,
+		Initial: r.Initial,
+		Orders:  make([]savedEntry, len(r.Orders)),
+		End:     r.End,
+	}
+	if r.Started >= 0 {
+		started := r.Started
+		doc.Started = &started
+	}
+	for i, entry := range r.Orders {
+		t := orderType(entry.Order)
+		if t == "" {
+			return fmt.Errorf("can't record orders of type %T", entry.Order)
+		}
+		encoded, err := json.Marshal(entry.Order)
+		if err != nil {
+			return err
+		}
+		doc.Orders[i] = savedEntry{entry.Tick, t, encoded}
+	}
+	return json.NewEncoder(w).Encode(doc)
+}
+
+// Read reads a replay written by Write.
+func Read(r io.Reader) (*Replay, error) {
+	var doc savedReplay
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("can't read replay: %v", err)
+	}
+	if doc.Version != Version && doc.Version != 1 {
+		return nil, fmt.Errorf("replay version %d isn't supported", doc.Version)
+	}
+
+	ret := &Replay{
+		Initial: doc.Initial,
+		Orders:  make([]Entry, len(doc.Orders)),
+		End:     doc.End,
+		Started: -1,
+	}
+	if doc.Started != nil {
+		ret.Started = *doc.Started
+	}
+	for i, saved := range doc.Orders {
+		order := newOrder(saved.Type)
+		if order == nil {
+			return nil, fmt.Errorf("replay has unknown order type %q", saved.Type)
+		}
+		if err := json.Unmarshal(saved.Order, order); err != nil {
+			return nil, fmt.Errorf("can't read replay: %v", err)
+		}
+		ret.Orders[i] = Entry{saved.Tick, order}
+	}
+	return ret, nil
+}
+
+// Recorder records a game from the moment the Recorder was created.
+type Recorder struct {
+	initial []byte
+	start   int
+	lobby   bool // whether the game was in the lobby when recording started
+}
+
+// NewRecorder starts recording g. Like everything else that touches a game,
+// it must be called between Ticks.
+func NewRecorder(g *game.Game) (*Recorder, error) {
+	var buf bytes.Buffer
+	if err := game.Save(g, &buf); err != nil {
+		return nil, err
+	}
+	return &Recorder{
+		initial: buf.Bytes(),
+		start:   len(game.Journal(g)),
+		lobby:   game.CurrentPhase(g) == game.PhaseLobby,
+	}, nil
+}
+
+// Replay builds a replay of everything that has happened to g since
+// recording started, using the orders in g's journal. Rejected orders had no
+// effect on the game, so they're left out. Orders given by players are
+// recorded without the player, since they've already been checked.
+func (r *Recorder) Replay(g *game.Game) *Replay {
+	ret := &Replay{
+		Initial: r.initial,
+		End:     game.CurrentTick(g),
+		Started: -1,
+	}
+	if started, ok := game.StartTick(g); ok && r.lobby {
+		ret.Started = started
+	}
+	for _, result := range game.Journal(g)[r.start:] {
+		if result.Err != nil {
+			continue
+		}
+		order := result.Order
+		if player, ok := order.(*game.PlayerOrder); ok {
+			order = player.Order
+		}
+		ret.Orders = append(ret.Orders, Entry{result.Tick, order})
+	}
+	return ret
+}
+
+type checkpoint struct {
+	tick  int
+	next  int // index of the first order that hasn't been applied
+	saved []byte
+}
+
+// Player plays a replay, one tick at a time. It saves a checkpoint every so
+// often as it goes, so that it can seek backwards without starting over.
+type Player struct {
+	replay      *Replay
+	game        *game.Game
+	next        int
+	interval    int
+	checkpoints []checkpoint
+}
+
+// NewPlayer sets up a player for r at the start of the recording, which
+// saves a checkpoint every interval ticks.
+func NewPlayer(r *Replay, interval int) (*Player, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("checkpoint interval must be positive, got %d", interval)
+	}
+	g, err := game.Load(bytes.NewReader(r.Initial))
+	if err != nil {
+		return nil, err
+	}
+	p := &Player{
+		replay:   r,
+		game:     g,
+		interval: interval,
+	}
+	if err := p.applyOrders(); err != nil {
+		return nil, err
+	}
+	if err := p.checkpoint(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// applyOrders applies every order recorded for the current tick, and starts
+// the game if that's when it started.
+func (p *Player) applyOrders() error {
+	tick := p.Tick()
+	var orders []game.Order
+	for p.next < len(p.replay.Orders) && p.replay.Orders[p.next].Tick <= tick {
+		orders = append(orders, p.replay.Orders[p.next].Order)
+		p.next++
+	}
+	for _, result := range game.ApplyOrders(p.game, orders) {
+		if result.Err != nil {
+			return fmt.Errorf("replayed order at tick %d was rejected: %v",
+				tick, result.Err)
+		}
+	}
+	if tick == p.replay.Started {
+		game.StartGame(p.game)
+	}
+	return nil
+}
+
+func (p *Player) checkpoint() error {
+	var buf bytes.Buffer
+	if err := game.Save(p.game, &buf); err != nil {
+		return err
+	}
+	p.checkpoints = append(p.checkpoints, checkpoint{p.Tick(), p.next, buf.Bytes()})
+	return nil
+}
+
+// Game is the game as of the player's current tick. It's replaced, rather
+// than updated, when the player seeks backwards.
+func (p *Player) Game() *game.Game {
+	return p.game
+}
+
+// Tick is the number of the tick the player is on.
+func (p *Player) Tick() int {
+	return game.CurrentTick(p.game)
+}
+
+// Start is the first tick of the recording.
+func (p *Player) Start() int {
+	return p.checkpoints[0].tick
+}
+
+// Step runs the next tick, and then applies the orders recorded for it. It
+// returns false if the recording is over. If the replay doesn't play out as
+// it was recorded, Step returns an error.
+func (p *Player) Step() (bool, error) {
+	if p.Tick() >= p.replay.End {
+		return false, nil
+	}
+
+	game.Tick(p.game, game.TickDuration)
+	if err := p.applyOrders(); err != nil {
+		return false, err
+	}
+
+	tick := p.Tick()
+	last := p.checkpoints[len(p.checkpoints)-1]
+	if tick%p.interval == 0 && tick > last.tick {
+		if err := p.checkpoint(); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// Seek moves the player to the given tick, after the orders recorded for that
+// tick have been applied. Seeking starts from the latest checkpoint before the
+// tick, so seeking to ticks the player has already passed is cheap.
+func (p *Player) Seek(tick int) error {
+	if tick < p.Start() || tick > p.replay.End {
+		return fmt.Errorf("tick %d is outside of the recording, %d to %d",
+			tick, p.Start(), p.replay.End)
+	}
+
+	var from checkpoint
+	for _, c := range p.checkpoints {
+		if c.tick <= tick {
+			from = c
+		}
+	}
+	// Playing on from where we are is cheaper than loading a checkpoint,
+	// as long as we don't have to go backwards to do it.
+	if tick < p.Tick() || from.tick > p.Tick() {
+		g, err := game.Load(bytes.NewReader(from.saved))
+		if err != nil {
+			return err
+		}
+		p.game = g
+		p.next = from.next
+	}
+
+	for p.Tick() < tick {
+		if _, err := p.Step(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
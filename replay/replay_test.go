@@ -0,0 +1,176 @@
+package replay
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/joeatwork/world-of-strategery/game"
+)
+
+var workerType = &game.CharacterType{
+	MovePerTick: 1.0,
+	WorkPerTick: 1.0,
+	MaxCarry:    10.0,
+	Width:       1,
+	Height:      1,
+}
+
+var houseType = &game.HouseType{
+	MaxResources: 20.0,
+	Width:        2,
+	Height:       2,
+}
+
+const recordedTicks = 60
+
+// startedTick is when the recorded game leaves the lobby.
+const startedTick = 5
+
+// recordGame plays a short game with a recorder running, and returns the
+// recording along with the status of the game at every tick.
+func recordGame(t *testing.T) (*Replay, map[int]game.GameStatus) {
+	g := game.NewGame(24, 24)
+	game.SetScheduling(g, game.FairScheduling, 7)
+	game.AddHouseType(g, "house", houseType)
+	red := game.AddCulture(g)
+	green := game.AddCulture(g)
+	builder, _ := game.AddCharacter(game.GameTerrain(g), red, workerType,
+		game.Location{X: 1, Y: 1})
+	marcher, _ := game.AddCharacter(game.GameTerrain(g), green, workerType,
+		game.Location{X: 20, Y: 20})
+	builder.Carrying = 10
+
+	recorder, err := NewRecorder(g)
+	if err != nil {
+		t.Fatalf("Unexpected error starting recorder: %v", err)
+	}
+
+	statuses := make(map[int]game.GameStatus)
+	for tick := 0; tick < recordedTicks; tick++ {
+		var orders []game.Order
+		switch tick {
+		case 2:
+			orders = []game.Order{
+				&game.PlanOrder{Culture: red.Name, HouseType: "house", X: 10, Y: 4},
+				&game.MarchOrder{Character: marcher.Name, X: 2, Y: 20},
+			}
+		case 3:
+			planned := statuses[2].Cultures[0].PlannedHouses[0].Name
+			orders = []game.Order{
+				&game.TargetOrder{Character: builder.Name, Target: planned},
+				&game.MarchOrder{Character: "nobody", X: 2, Y: 2},
+			}
+		case 30:
+			orders = []game.Order{
+				&game.CultureOrder{},
+				&game.MarchOrder{Character: marcher.Name, X: 20, Y: 2},
+			}
+		}
+		game.ApplyOrders(g, orders)
+		if tick == startedTick {
+			game.StartGame(g)
+		}
+		statuses[tick] = game.ReadStatus(g)
+		game.Tick(g, game.TickDuration)
+	}
+	statuses[recordedTicks] = game.ReadStatus(g)
+
+	return recorder.Replay(g), statuses
+}
+
+func TestRecorderSkipsRejectedOrders(t *testing.T) {
+	recorded, _ := recordGame(t)
+	if len(recorded.Orders) != 5 {
+		t.Errorf("Expected 5 recorded orders, got %d", len(recorded.Orders))
+	}
+	if recorded.End != recordedTicks {
+		t.Errorf("Expected recording to end at %d, got %d", recordedTicks, recorded.End)
+	}
+	if recorded.Started != startedTick {
+		t.Errorf("Expected recording to start at %d, got %d", startedTick, recorded.Started)
+	}
+}
+
+func TestPlayerReproducesGame(t *testing.T) {
+	recorded, statuses := recordGame(t)
+
+	var buf bytes.Buffer
+	if err := recorded.Write(&buf); err != nil {
+		t.Fatalf("Unexpected error writing replay: %v", err)
+	}
+	read, err := Read(&buf)
+	if err != nil {
+		t.Fatalf("Unexpected error reading replay: %v", err)
+	}
+
+	player, err := NewPlayer(read, 10)
+	if err != nil {
+		t.Fatalf("Unexpected error starting player: %v", err)
+	}
+	for {
+		tick := player.Tick()
+		if !reflect.DeepEqual(statuses[tick], game.ReadStatus(player.Game())) {
+			t.Fatalf("Replay differs from original at tick %d", tick)
+		}
+		more, err := player.Step()
+		if err != nil {
+			t.Fatalf("Unexpected error at tick %d: %v", tick, err)
+		}
+		if !more {
+			break
+		}
+	}
+	if player.Tick() != recordedTicks {
+		t.Errorf("Expected player to stop at %d, stopped at %d", recordedTicks, player.Tick())
+	}
+	if len(game.ReadStatus(player.Game()).Cultures) != 3 {
+		t.Errorf("Expected the culture added mid game to be replayed")
+	}
+}
+
+func TestPlayerSeek(t *testing.T) {
+	recorded, statuses := recordGame(t)
+	player, err := NewPlayer(recorded, 10)
+	if err != nil {
+		t.Fatalf("Unexpected error starting player: %v", err)
+	}
+
+	for _, tick := range []int{45, 12, 31, 31, 0, recordedTicks, 29} {
+		if err := player.Seek(tick); err != nil {
+			t.Fatalf("Unexpected error seeking to %d: %v", tick, err)
+		}
+		if player.Tick() != tick {
+			t.Errorf("Expected to seek to %d, got %d", tick, player.Tick())
+		}
+		if !reflect.DeepEqual(statuses[tick], game.ReadStatus(player.Game())) {
+			t.Errorf("Seeking to %d doesn't reproduce the original game", tick)
+		}
+	}
+
+	if err := player.Seek(recordedTicks + 1); err == nil {
+		t.Errorf("Expected an error seeking past the end of the recording")
+	}
+}
+
+func TestReadVersion1(t *testing.T) {
+	recorded, _ := recordGame(t)
+	var buf bytes.Buffer
+	if err := recorded.Write(&buf); err != nil {
+		t.Fatalf("Unexpected error writing replay: %v", err)
+	}
+	var doc map[string]interface{}
+	json.Unmarshal(buf.Bytes(), &doc)
+	doc["version"] = 1
+	delete(doc, "started")
+	old, _ := json.Marshal(doc)
+
+	read, err := Read(bytes.NewReader(old))
+	if err != nil {
+		t.Fatalf("Unexpected error reading version 1 replay: %v", err)
+	}
+	if read.Started >= 0 {
+		t.Errorf("Expected a version 1 replay to never start, got %d", read.Started)
+	}
+}
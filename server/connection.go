@@ -0,0 +1,281 @@
+package server
+
+import (
+	"log"
+	"time"
+
+	"golang.org/x/net/websocket"
+
+	"github.com/joeatwork/world-of-strategery/game"
+	"github.com/joeatwork/world-of-strategery/protocol"
+)
+
+// statusInterval is how often connected players are sent the game status.
+const statusInterval = 50 * time.Millisecond
+
+func notSupportedMarshal(v interface{}) (msg []byte, payloadType byte, err error) {
+	return nil, websocket.UnknownFrame, websocket.ErrNotSupported
+}
+
+func notSupportedUnmarshal(msg []byte, payloadType byte, v interface{}) (err error) {
+	return websocket.ErrNotSupported
+}
+
+func messageMarshal(v interface{}) (msg []byte, payloadType byte, err error) {
+	msg, err = protocol.Encode(v)
+	return msg, websocket.TextFrame, err
+}
+
+func clientUnmarshal(msg []byte, payloadType byte, v interface{}) (err error) {
+	decoded, err := protocol.Decode(msg)
+	if err != nil {
+		return err
+	}
+	*v.(*interface{}) = decoded
+	return nil
+}
+
+var clientCodec = websocket.Codec{
+	Marshal:   notSupportedMarshal,
+	Unmarshal: clientUnmarshal,
+}
+
+var messageCodec = websocket.Codec{
+	Marshal:   messageMarshal,
+	Unmarshal: notSupportedUnmarshal,
+}
+
+// connection is a single client connected to a game. Players have a culture
+// of their own, spectators don't. Clients that asked for deltas are sent
+// StatusDeltas instead of full statuses.
+type connection struct {
+	ws         *websocket.Conn
+	loop       *game.GameLoop
+	addCulture func() (string, error)
+	role       string
+	culture    string
+	deltas     bool
+	sync       statusSync
+}
+
+// serveConnection waits for a client to join the game, and then relays orders
+// from them into loop and sends them the status of the game and the events
+// they're allowed to know about, until either the connection fails or the
+// game stops. Players get the culture made for them by addCulture. Problems
+// with one connection never stop the game for anyone else.
+func serveConnection(ws *websocket.Conn, loop *game.GameLoop,
+	addCulture func() (string, error)) {
+	conn := &connection{ws: ws, loop: loop, addCulture: addCulture}
+	if !conn.join() {
+		return
+	}
+
+	events, cancel := loop.Subscribe()
+	defer cancel()
+	done := make(chan struct{})
+	defer close(done)
+
+	go conn.writeStatuses(done, events)
+	conn.readOrders()
+}
+
+// join waits for the client to send a join message, and returns false if the
+// client goes away or the game stops before that happens.
+func (c *connection) join() bool {
+	for !c.loop.IsStopped() {
+		var msg interface{}
+		err := clientCodec.Receive(c.ws, &msg)
+		if protocolErr, ok := err.(*protocol.Error); ok {
+			c.send(protocolErr)
+			continue
+		}
+		if err != nil {
+			log.Printf("can't read, %v", err)
+			return false
+		}
+
+		join, ok := msg.(*protocol.Join)
+		if !ok {
+			c.send(&protocol.Error{Reason: protocol.ReasonNotJoined})
+			continue
+		}
+
+		switch join.Role {
+		case protocol.RolePlayer:
+			culture, err := c.addCulture()
+			if err != nil && c.loop.IsStopped() {
+				return false
+			}
+			if err != nil {
+				// Finished and full games can still be watched
+				c.send(joinError(err))
+				continue
+			}
+			c.culture = culture
+		case protocol.RoleSpectator:
+		default:
+			c.send(&protocol.Error{
+				Reason: protocol.ReasonUnknownRole,
+				Name:   join.Role,
+			})
+			continue
+		}
+
+		c.role = join.Role
+		c.deltas = join.Deltas
+		return c.send(protocol.Joined{Role: c.role, Culture: c.culture})
+	}
+
+	return false
+}
+
+// joinError describes why a player couldn't join.
+func joinError(err error) *protocol.Error {
+	if orderErr, ok := err.(*game.OrderError); ok {
+		return &protocol.Error{Reason: orderErr.Reason, Name: orderErr.Name}
+	}
+	if err == errGameFull {
+		return &protocol.Error{Reason: protocol.ReasonGameFull}
+	}
+	return &protocol.Error{Reason: protocol.ReasonInternal}
+}
+
+func (c *connection) send(v interface{}) bool {
+	if err := messageCodec.Send(c.ws, v); err != nil {
+		log.Printf("can't write, %v", err)
+		return false
+	}
+	return true
+}
+
+// readStatus returns the latest status of the game, as this client is allowed
+// to see it. Spectators see everything.
+func (c *connection) readStatus() game.GameStatus {
+	if c.role != protocol.RolePlayer {
+		return c.loop.ReadLatestStatus()
+	}
+
+	status, ok := c.loop.ReadLatestStatusFor(c.culture)
+	if !ok {
+		return game.GameStatus{Viewer: c.culture}
+	}
+	return status
+}
+
+// canSee reports whether the client is allowed to know about event. Players
+// only hear about their own culture, deposits, and the game as a whole.
+// Spectators hear about everything.
+func (c *connection) canSee(event game.Event) bool {
+	if c.role != protocol.RolePlayer {
+		return true
+	}
+	switch event.Culture {
+	case c.culture, game.NeutralCulture, "":
+		return true
+	}
+	return false
+}
+
+// sendEvents sends the client the events in batch that it can see.
+func (c *connection) sendEvents(batch []game.Event) bool {
+	var visible []game.Event
+	for _, event := range batch {
+		if c.canSee(event) {
+			visible = append(visible, event)
+		}
+	}
+	if len(visible) == 0 {
+		return true
+	}
+	return c.send(visible)
+}
+
+// writeStatuses sends the client the status of the game every
+// statusInterval, either in full or as a delta, and events as they arrive.
+// Once the game is finished, the
+// client is sent the final status and the game's result, and no more
+// statuses after that.
+func (c *connection) writeStatuses(done <-chan struct{}, events <-chan []game.Event) {
+	ticker := time.NewTicker(statusInterval)
+	defer ticker.Stop()
+
+	over := false
+	sendStatus := func() bool {
+		if over {
+			return true
+		}
+		status := c.readStatus()
+		var msg interface{} = status
+		if c.deltas {
+			msg = c.sync.next(status)
+		}
+		if !c.send(msg) {
+			return false
+		}
+		if status.Result != nil {
+			over = true
+			return c.send(*status.Result)
+		}
+		return true
+	}
+
+	ok := sendStatus()
+	for ok && !c.loop.IsStopped() {
+		select {
+		case <-ticker.C:
+			ok = sendStatus()
+		case batch := <-events:
+			ok = c.sendEvents(batch)
+		case <-done:
+			return
+		}
+	}
+
+	// Closing the connection ends the reader, too
+	c.ws.Close()
+	log.Printf("writer terminated")
+}
+
+func (c *connection) readOrders() {
+	for !c.loop.IsStopped() {
+		var msg interface{}
+		err := clientCodec.Receive(c.ws, &msg)
+		if protocolErr, ok := err.(*protocol.Error); ok {
+			// The client sent something we can't understand,
+			// but the connection is still fine.
+			c.send(protocolErr)
+			continue
+		}
+		if err != nil {
+			log.Printf("can't read, %v", err)
+			break
+		}
+
+		switch m := msg.(type) {
+		case *protocol.Ack:
+			c.sync.ack(m.Seq)
+			continue
+		case *protocol.Resync:
+			c.sync.requestResync()
+			continue
+		}
+
+		order, ok := msg.(game.Order)
+		if !ok {
+			c.send(&protocol.Error{Reason: protocol.ReasonAlreadyJoined})
+			continue
+		}
+		if c.role != protocol.RolePlayer {
+			c.send(&protocol.Error{Reason: protocol.ReasonSpectator})
+			continue
+		}
+
+		// Players can only give orders on behalf of their own culture
+		owned := &game.PlayerOrder{Culture: c.culture, Order: order}
+		for _, result := range c.loop.WriteOrders([]game.Order{owned}) {
+			c.send(result)
+		}
+	}
+
+	log.Printf("reader terminated")
+}
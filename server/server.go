@@ -1,31 +1,640 @@
+// Package server hosts many games at once, each running in its own
+// game.GameLoop, and connects websocket clients to them.
+//
+//	GET  /games       lists the games being hosted
+//	POST /games       creates a new game, optionally with bots playing in it
+//	                  and conditions for winning it
+//	POST /games/{id}/start
+//	                  starts a game without waiting for more players
+//	GET  /game/{id}   joins a game over a websocket
+//
+// Games are played on a map, either one of the server's maps or one generated
+// for the game, and every player or bot that joins takes one of its starts.
+// Games wait in the lobby until enough players have joined, one unless the
+// game was created asking for more, or until they're started.
+//
+// Games are torn down when the last player connected to them leaves, or if
+// nobody joins them for a while after they're created. Bots don't count as
+// players. If the server is recording replays, each game's replay is written
+// out then.
 package server
 
-// Commands a character to target coordinates. Character will try to
-// get there, and then go idle.
-func CommandGo(s *Server, you *game.Character, where *game.Location) error {
-	you.Target = where
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/websocket"
+
+	"github.com/joeatwork/world-of-strategery/bot"
+	"github.com/joeatwork/world-of-strategery/game"
+	"github.com/joeatwork/world-of-strategery/replay"
+)
+
+// Games created without dimensions are this size
+const defaultWidth, defaultHeight = 64, 64
+
+// Games can be no bigger than this
+const maxWidth, maxHeight = 256, 256
+
+// Servers host at most defaultMaxGames games at once, and tear down games
+// nobody has joined after defaultIdleTimeout.
+const (
+	defaultMaxGames    = 64
+	defaultIdleTimeout = 5 * time.Minute
+)
+
+// errTooManyGames is returned by CreateGame when the server is full.
+var errTooManyGames = errors.New("server is hosting as many games as it can")
+
+// errNoSuchGame is returned for IDs of games the server isn't hosting.
+var errNoSuchGame = errors.New("no such game")
+
+// errGameFull is returned when a culture joins a game whose map has no starts
+// left.
+var errGameFull = errors.New("every start is taken")
+
+// defaultMapConfig is how maps are generated for games created without a map,
+// unless they ask for something else. The types come from the default
+// catalog.
+var defaultMapConfig = game.MapConfig{
+	WorkerType:        "worker",
+	Workers:           3,
+	DepositType:       "house",
+	DepositsPerPlayer: 3,
+	DepotType:         "depot",
+	Resources:         20,
+}
+
+// Server is an http.Handler that hosts a collection of games.
+type Server struct {
+	config      game.LoopConfig
+	games       map[string]*hostedGame
+	nextID      int
+	maxGames    int
+	idleTimeout time.Duration
+	replayDir   string
+	mapDir      string
+	catalog     *game.Catalog
+	lock        sync.Mutex
+}
+
+// hostedGame is a running game and the number of players and bots connected
+// to it. players, bots, closing and starts are guarded by the Server lock.
+// Closing games are being torn down, and can't be joined. Games played on a
+// map keep the starts nobody has taken yet. recorder is nil unless the server
+// is recording replays, into replayDir.
+type hostedGame struct {
+	id        string
+	loop      *game.GameLoop
+	players   int
+	bots      int
+	closing   bool
+	onMap     bool
+	starts    []game.MapStart
+	recorder  *replay.Recorder
+	replayDir string
+}
+
+// GameInfo describes a hosted game, for players looking for a game to join.
+type GameInfo struct {
+	ID      string     `json:"id"`
+	Players int        `json:"players"`
+	Bots    int        `json:"bots"`
+	Tick    int        `json:"tick"`
+	Phase   game.Phase `json:"phase"`
+}
+
+// GameOptions describes a game for CreateGame. Games are played on Map, and
+// each culture that joins takes the next of its starts. Games without a map
+// are empty Width by Height boards, and their cultures start with nothing.
+// The game waits in the lobby until it has Players cultures, counting bots,
+// and ends when any of its Conditions are met.
+type GameOptions struct {
+	Map        *game.Map
+	Width      int
+	Height     int
+	Players    int
+	Conditions []game.WinCondition
+}
+
+// createRequest describes a new game. Games are played on the server map
+// named by Map, or else on a Width by Height map generated with the settings
+// in Generate, in place of the ones in defaultMapConfig. Games start once
+// Players people have joined, along with the bots. Games end when the last
+// culture with houses is standing if LastStanding is set, when a culture
+// banks ResourceTarget resources, or after TimeLimit ticks, whichever comes
+// first. Games without any of these run until everyone leaves.
+type createRequest struct {
+	Width          int             `json:"width"`
+	Height         int             `json:"height"`
+	Map            string          `json:"map"`
+	Generate       *game.MapConfig `json:"generate"`
+	Bots           int             `json:"bots"`
+	Players        int             `json:"players"`
+	LastStanding   bool            `json:"lastStanding"`
+	ResourceTarget float64         `json:"resourceTarget"`
+	TimeLimit      int             `json:"timeLimit"`
+}
+
+func (r createRequest) winConditions() []game.WinCondition {
+	var ret []game.WinCondition
+	if r.LastStanding {
+		ret = append(ret, game.LastCultureStanding{})
+	}
+	if r.ResourceTarget > 0 {
+		ret = append(ret, game.ResourceTarget{Resources: r.ResourceTarget})
+	}
+	if r.TimeLimit > 0 {
+		ret = append(ret, game.TimeLimit{Ticks: r.TimeLimit})
+	}
+	return ret
+}
+
+// NewServer creates a server with no games. Every game it hosts will run with
+// the given config, with defaults in place of any unusable settings.
+func NewServer(config game.LoopConfig) *Server {
+	return &Server{
+		config:      config.WithDefaults(),
+		games:       make(map[string]*hostedGame),
+		maxGames:    defaultMaxGames,
+		idleTimeout: defaultIdleTimeout,
+	}
+}
+
+// RecordReplays makes the server record every game it creates from now on,
+// and write a replay of each game into dir when the game is torn down.
+func (s *Server) RecordReplays(dir string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.replayDir = dir
+}
+
+// UseCatalog makes the types in catalog available in every game the server
+// creates from now on. Games can only be played on maps once the server has
+// a catalog.
+func (s *Server) UseCatalog(catalog *game.Catalog) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.catalog = catalog
+}
+
+// UseMaps lets games be created on the maps in dir, named by their file
+// names without the .json.
+func (s *Server) UseMaps(dir string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.mapDir = dir
+}
+
+// requestedMap reads or generates the map a create request asks for, or
+// returns nil if the server has no catalog to play maps with.
+func (s *Server) requestedMap(req createRequest) (*game.Map, error) {
+	s.lock.Lock()
+	catalog, mapDir := s.catalog, s.mapDir
+	s.lock.Unlock()
+
+	if req.Map != "" {
+		if mapDir == "" || strings.ContainsAny(req.Map, `/\`) || strings.HasPrefix(req.Map, ".") {
+			return nil, fmt.Errorf("no map named %q", req.Map)
+		}
+		m, err := game.ReadMapFile(filepath.Join(mapDir, req.Map+".json"))
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("no map named %q", req.Map)
+		}
+		return m, err
+	}
+	if catalog == nil {
+		return nil, nil
+	}
+
+	config := defaultMapConfig
+	if req.Generate != nil {
+		config = *req.Generate
+		if config.WorkerType == "" {
+			config.WorkerType = defaultMapConfig.WorkerType
+		}
+		if config.Workers == 0 {
+			config.Workers = defaultMapConfig.Workers
+		}
+		if config.DepositType == "" {
+			config.DepositType = defaultMapConfig.DepositType
+		}
+		if config.DepositsPerPlayer == 0 {
+			config.DepositsPerPlayer = defaultMapConfig.DepositsPerPlayer
+		}
+		if config.DepotType == "" {
+			config.DepotType = defaultMapConfig.DepotType
+		}
+		if config.Resources == 0 {
+			config.Resources = defaultMapConfig.Resources
+		}
+	}
+	if config.Seed == 0 {
+		config.Seed = time.Now().UnixNano()
+	}
+	config.Width, config.Height = req.Width, req.Height
+	if config.Width > maxWidth || config.Height > maxHeight {
+		return nil, fmt.Errorf("games can be at most %d by %d", maxWidth, maxHeight)
+	}
+	// Maps are generated for 2 or 4 players
+	config.Players = 2
+	if req.Bots+req.Players > 2 {
+		config.Players = 4
+	}
+	return game.GenerateMap(config, catalog)
+}
+
+// CreateGame starts running a new game described by options, and returns its
+// ID. It returns an error if the game is too big or too small, has more
+// players than its map has starts, or if the server is already hosting as
+// many games as it can.
+func (s *Server) CreateGame(options GameOptions) (string, error) {
+	if options.Map != nil {
+		if len(options.Map.Tiles) > maxHeight ||
+			(len(options.Map.Tiles) > 0 && len(options.Map.Tiles[0]) > maxWidth) {
+			return "", fmt.Errorf("games can be at most %d by %d", maxWidth, maxHeight)
+		}
+		if options.Players > len(options.Map.Starts) {
+			return "", fmt.Errorf("map only has %d starts", len(options.Map.Starts))
+		}
+	} else {
+		if options.Width <= 0 || options.Height <= 0 {
+			return "", errors.New("games must have a positive size")
+		}
+		if options.Width > maxWidth || options.Height > maxHeight {
+			return "", fmt.Errorf("games can be at most %d by %d", maxWidth, maxHeight)
+		}
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if len(s.games) >= s.maxGames {
+		return "", errTooManyGames
+	}
+
+	var g *game.Game
+	hosted := &hostedGame{}
+	if options.Map != nil {
+		if s.catalog == nil {
+			return "", errors.New("maps can't be played without a catalog")
+		}
+		// Starts are handed out as cultures join
+		board := *options.Map
+		board.Starts = nil
+		var err error
+		if g, err = game.NewGameFromMap(&board, s.catalog); err != nil {
+			return "", err
+		}
+		hosted.onMap = true
+		hosted.starts = options.Map.Starts
+	} else {
+		g = game.NewGame(options.Width, options.Height)
+		if s.catalog != nil {
+			if err := game.AddCatalog(g, s.catalog); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	s.nextID++
+	id := strconv.Itoa(s.nextID)
+	hosted.id = id
+	for _, condition := range options.Conditions {
+		game.AddWinCondition(g, condition)
+	}
+	if s.replayDir != "" {
+		recorder, err := replay.NewRecorder(g)
+		if err != nil {
+			log.Printf("can't record game %s, %v", id, err)
+		}
+		hosted.recorder = recorder
+		hosted.replayDir = s.replayDir
+	}
+	config := s.config
+	config.MinPlayers = options.Players
+	hosted.loop = game.RunGameLoop(g, config)
+	s.games[id] = hosted
+	time.AfterFunc(s.idleTimeout, func() { s.reapIdle(hosted) })
+	return id, nil
+}
+
+// writeReplay writes the replay of a game that's about to be torn down.
+func writeReplay(hosted *hostedGame) error {
+	var recorded *replay.Replay
+	ok := hosted.loop.Call(func(g *game.Game) {
+		recorded = hosted.recorder.Replay(g)
+	})
+	if !ok {
+		return fmt.Errorf("game stopped before it could be recorded")
+	}
+
+	path := filepath.Join(hosted.replayDir, fmt.Sprintf("game-%s.json", hosted.id))
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := recorded.Write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// AddBot adds a new culture to the game with the given ID, played by a bot
+// running inside the server, and returns the culture's name. It returns an
+// error if there is no such game, the game can't take another culture, or
+// config doesn't fit the game's types.
+func (s *Server) AddBot(id string, config bot.Config) (string, error) {
+	s.lock.Lock()
+	hosted, ok := s.games[id]
+	s.lock.Unlock()
+	if !ok {
+		return "", errNoSuchGame
+	}
+
+	var err error
+	if !hosted.loop.Call(func(g *game.Game) {
+		err = config.Check(g)
+	}) {
+		return "", game.ErrStopped
+	}
+	if err != nil {
+		return "", err
+	}
+	culture, err := s.addCulture(hosted)
+	if err != nil {
+		return "", err
+	}
+	s.lock.Lock()
+	hosted.bots++
+	s.lock.Unlock()
+	poll := time.Second / time.Duration(s.config.TicksPerSecond)
+	go bot.Run(hosted.loop, bot.New(culture, config), poll)
+	return culture, nil
+}
+
+// addCulture adds a culture to a hosted game, for a player or a bot. Games
+// played on a map give each culture the next of the map's starts, and can't
+// be joined by more cultures than the map has starts.
+func (s *Server) addCulture(hosted *hostedGame) (string, error) {
+	s.lock.Lock()
+	var start *game.MapStart
+	if hosted.onMap {
+		if len(hosted.starts) == 0 {
+			s.lock.Unlock()
+			return "", errGameFull
+		}
+		next := hosted.starts[0]
+		start = &next
+		hosted.starts = hosted.starts[1:]
+	}
+	s.lock.Unlock()
+
+	return hosted.loop.AddCultureAt(start)
+}
+
+// StartGame starts the game with the given ID without waiting for more
+// players. It returns false if there is no such game, or it has already
+// started.
+func (s *Server) StartGame(id string) bool {
+	s.lock.Lock()
+	hosted, ok := s.games[id]
+	s.lock.Unlock()
+	return ok && hosted.loop.Start()
+}
+
+// ListGames describes every game the server is hosting, ordered by ID.
+func (s *Server) ListGames() []GameInfo {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	ret := make([]GameInfo, 0, len(s.games))
+	for _, hosted := range s.games {
+		status := hosted.loop.ReadLatestStatus()
+		ret = append(ret, GameInfo{
+			ID:      hosted.id,
+			Players: hosted.players,
+			Bots:    hosted.bots,
+			Tick:    status.Tick,
+			Phase:   status.Phase,
+		})
+	}
+
+	sort.Slice(ret, func(i, j int) bool {
+		a, _ := strconv.Atoi(ret[i].ID)
+		b, _ := strconv.Atoi(ret[j].ID)
+		return a < b
+	})
+	return ret
+}
+
+func (s *Server) hasGame(id string) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	_, ok := s.games[id]
+	return ok
+}
+
+// join adds a player to the game with the given ID, or returns nil if there
+// is no such game, or it's being torn down.
+func (s *Server) join(id string) *hostedGame {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	hosted, ok := s.games[id]
+	if !ok || hosted.closing {
+		return nil
+	}
+	hosted.players++
+	return hosted
+}
+
+// leave removes a player from a game, and tears the game down if nobody is
+// left playing it.
+func (s *Server) leave(hosted *hostedGame) {
+	s.lock.Lock()
+	hosted.players--
+	empty := hosted.players <= 0 && !hosted.closing
+	if empty {
+		hosted.closing = true
+	}
+	s.lock.Unlock()
+
+	if empty {
+		s.tearDown(hosted)
+	}
 }
 
-// Commands a character to got to work mining or building
-// what. Character will try to get there, do as much work as it can,
-// and then go idle
-func CommandWork(s *Server, you *game.Character, what *game.House) error {
-	you.Target = what
+// reapIdle tears down a game if nobody is playing it, which is only the case
+// for games that nobody has joined since they were created: games are torn
+// down as soon as their last player leaves. Games played only by bots are
+// reaped too.
+func (s *Server) reapIdle(hosted *hostedGame) {
+	s.lock.Lock()
+	idle := s.games[hosted.id] == hosted && hosted.players <= 0 && !hosted.closing
+	if idle {
+		hosted.closing = true
+	}
+	s.lock.Unlock()
+
+	if idle {
+		log.Printf("game %s is idle", hosted.id)
+		s.tearDown(hosted)
+	}
 }
 
-// Propose adding a house. The proposed house (if unblocked) will be
-// eligible for building.
-func CommandStartHouse(s *Server, us *game.Culture,
-	kind HouseKind, where *game.Location) {
-	house := game.House{
-		Location: *where,
-		Kind:     kind,
-		Culture:  us,
+// dropGame tears down a game that couldn't be set up the way it was asked
+// for.
+func (s *Server) dropGame(id string) {
+	s.lock.Lock()
+	hosted, ok := s.games[id]
+	drop := ok && !hosted.closing
+	if drop {
+		hosted.closing = true
+	}
+	s.lock.Unlock()
+
+	if drop {
+		s.tearDown(hosted)
 	}
-	us.PlannedHouses[house] = true
 }
 
-// Abandon the plan to build a house.
-func AbandonPlannedHouse(s *Server, us *game.Culture, what *game.House) error {
-	delete(us.PlannedHouses, what)
+// tearDown writes the replay of a closing game, stops it and stops hosting
+// it. It's called without the Server lock, since writing the replay waits
+// on the game loop.
+func (s *Server) tearDown(hosted *hostedGame) {
+	if hosted.recorder != nil {
+		if err := writeReplay(hosted); err != nil {
+			log.Printf("can't write replay of game %s, %v", hosted.id, err)
+		}
+	}
+	hosted.loop.Stop()
+
+	s.lock.Lock()
+	delete(s.games, hosted.id)
+	s.lock.Unlock()
+	log.Printf("game %s torn down", hosted.id)
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/games":
+		s.serveGames(w, r)
+	case strings.HasPrefix(r.URL.Path, "/games/") && strings.HasSuffix(r.URL.Path, "/start"):
+		id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/games/"), "/start")
+		s.serveStart(w, r, id)
+	case strings.HasPrefix(r.URL.Path, "/game/"):
+		s.serveGame(w, r, strings.TrimPrefix(r.URL.Path, "/game/"))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("can't write response, %v", err)
+	}
+}
+
+func (s *Server) serveGames(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, s.ListGames())
+	case http.MethodPost:
+		req := createRequest{Width: defaultWidth, Height: defaultHeight, Players: 1}
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "can't read game request", http.StatusBadRequest)
+				return
+			}
+		}
+		if req.Bots < 0 || req.Players < 0 {
+			http.Error(w, "games can't have negative bots or players",
+				http.StatusBadRequest)
+			return
+		}
+		if req.ResourceTarget < 0 || req.TimeLimit < 0 {
+			http.Error(w, "games can't have negative targets or limits",
+				http.StatusBadRequest)
+			return
+		}
+
+		m, err := s.requestedMap(req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		id, err := s.CreateGame(GameOptions{
+			Map:        m,
+			Width:      req.Width,
+			Height:     req.Height,
+			Players:    req.Bots + req.Players,
+			Conditions: req.winConditions(),
+		})
+		if err == errTooManyGames {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for i := 0; i < req.Bots; i++ {
+			if _, err := s.AddBot(id, bot.DefaultConfig); err != nil {
+				s.dropGame(id)
+				http.Error(w, "can't add bots, "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		writeJSON(w, GameInfo{ID: id, Bots: req.Bots, Phase: game.PhaseLobby})
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) serveStart(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.hasGame(id) {
+		http.NotFound(w, r)
+		return
+	}
+	if !s.StartGame(id) {
+		http.Error(w, "game has already started", http.StatusConflict)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) serveGame(w http.ResponseWriter, r *http.Request, id string) {
+	if !s.hasGame(id) {
+		http.NotFound(w, r)
+		return
+	}
+
+	websocket.Handler(func(ws *websocket.Conn) {
+		hosted := s.join(id)
+		if hosted == nil {
+			return // the game ended while we were connecting
+		}
+		defer s.leave(hosted)
+
+		serveConnection(ws, hosted.loop, func() (string, error) {
+			return s.addCulture(hosted)
+		})
+	}).ServeHTTP(w, r)
 }
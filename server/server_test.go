@@ -0,0 +1,833 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+
+	"github.com/joeatwork/world-of-strategery/bot"
+	"github.com/joeatwork/world-of-strategery/game"
+	"github.com/joeatwork/world-of-strategery/protocol"
+	"github.com/joeatwork/world-of-strategery/replay"
+)
+
+var testConfig = game.LoopConfig{TicksPerSecond: 100, MaxCatchUpTicks: 5}
+
+// testMap has two starts, each with a worker, a depot and resources to build
+// with, and a deposit between them. Its types are from the default catalog.
+const testMap = `{
+	"tiles": [
+		"----------------",
+		"----------------",
+		"----------------",
+		"----------------",
+		"----------------",
+		"----------------",
+		"----------------",
+		"----------------"
+	],
+	"deposits": [{"type": "house", "x": 7, "y": 0, "resources": 100}],
+	"starts": [
+		{"characters": [{"type": "worker", "x": 1, "y": 1}],
+		 "houses": [{"type": "depot", "x": 1, "y": 3}],
+		 "resources": 100},
+		{"characters": [{"type": "worker", "x": 14, "y": 6}],
+		 "houses": [{"type": "depot", "x": 13, "y": 3}],
+		 "resources": 100}
+	]
+}`
+
+type message struct {
+	Type     string                `json:"type"`
+	Culture  string                `json:"culture"`
+	Accepted bool                  `json:"accepted"`
+	Error    *protocol.Error       `json:"error"`
+	Result   *game.GameResult      `json:"result"`
+	Events   []game.Event          `json:"events"`
+	Delta    *protocol.StatusDelta `json:"delta"`
+}
+
+// receiveUntil reads messages from ws until one has the given type.
+func receiveUntil(t *testing.T, ws *websocket.Conn, messageType string) message {
+	ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		var data []byte
+		if err := websocket.Message.Receive(ws, &data); err != nil {
+			t.Fatalf("Can't read %s message: %v", messageType, err)
+		}
+		var msg message
+		if err := json.Unmarshal(data, &msg); err != nil {
+			t.Fatalf("Can't decode message %s: %v", data, err)
+		}
+		if msg.Type == messageType {
+			return msg
+		}
+	}
+}
+
+func dial(t *testing.T, ts *httptest.Server, path string) *websocket.Conn {
+	url := "ws" + strings.TrimPrefix(ts.URL, "http") + path
+	ws, err := websocket.Dial(url, "", ts.URL)
+	if err != nil {
+		t.Fatalf("Can't connect to %s: %v", path, err)
+	}
+	return ws
+}
+
+// joinAs joins the game on ws, and returns the name of the player's culture.
+func joinAs(t *testing.T, ws *websocket.Conn, role string) string {
+	websocket.Message.Send(ws, fmt.Sprintf(`{"version":1,"type":"join","role":%q}`, role))
+	joined := receiveUntil(t, ws, protocol.TypeJoined)
+	if role == protocol.RolePlayer && joined.Culture == "" {
+		t.Fatalf("Player joined without a culture")
+	}
+	return joined.Culture
+}
+
+func createGame(t *testing.T, s *Server, options GameOptions) string {
+	id, err := s.CreateGame(options)
+	if err != nil {
+		t.Fatalf("Can't create game: %v", err)
+	}
+	return id
+}
+
+// postGame asks ts to create a game as described by body, and returns the
+// game along with the response's status code.
+func postGame(t *testing.T, ts *httptest.Server, body string) (GameInfo, int) {
+	resp, err := http.Post(ts.URL+"/games", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("Can't create game: %v", err)
+	}
+	defer resp.Body.Close()
+	var info GameInfo
+	if resp.StatusCode == http.StatusOK {
+		json.NewDecoder(resp.Body).Decode(&info)
+	}
+	return info, resp.StatusCode
+}
+
+func readDefaultCatalog(t *testing.T) *game.Catalog {
+	catalog, err := game.ReadCatalogFile("../catalogs/default.json")
+	if err != nil {
+		t.Fatalf("Can't read default catalog: %v", err)
+	}
+	return catalog
+}
+
+// newMapServer returns a server with the default catalog, and the ID of a
+// game it's hosting on testMap.
+func newMapServer(t *testing.T) (*Server, string) {
+	m, err := game.ReadMap(strings.NewReader(testMap))
+	if err != nil {
+		t.Fatalf("Can't read test map: %v", err)
+	}
+	s := NewServer(testConfig)
+	s.UseCatalog(readDefaultCatalog(t))
+	return s, createGame(t, s, GameOptions{Map: m, Players: 1})
+}
+
+// ownCulture returns the status of the culture a player sees as its own.
+func ownCulture(status game.GameStatus) game.CultureStatus {
+	for _, culture := range status.Cultures {
+		if culture.Name == status.Viewer {
+			return culture
+		}
+	}
+	return game.CultureStatus{}
+}
+
+func TestCreateAndListGames(t *testing.T) {
+	s := NewServer(testConfig)
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	for i := 0; i < 2; i++ {
+		resp, err := http.Post(ts.URL+"/games", "application/json",
+			strings.NewReader(`{"width": 8, "height": 8}`))
+		if err != nil {
+			t.Fatalf("Can't create game: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Creating game failed with %s", resp.Status)
+		}
+	}
+
+	resp, err := http.Get(ts.URL + "/games")
+	if err != nil {
+		t.Fatalf("Can't list games: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var games []GameInfo
+	if err := json.NewDecoder(resp.Body).Decode(&games); err != nil {
+		t.Fatalf("Can't read game list: %v", err)
+	}
+	if len(games) != 2 || games[0].ID != "1" || games[1].ID != "2" {
+		t.Errorf("Unexpected game list %v", games)
+	}
+}
+
+func TestCreateGameBadSize(t *testing.T) {
+	s := NewServer(testConfig)
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	for _, body := range []string{
+		`{"width": -1, "height": 8}`,
+		`{"width": 8, "height": 100000}`,
+	} {
+		resp, err := http.Post(ts.URL+"/games", "application/json",
+			strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("Can't create game: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("Expected bad request for %s, got %s", body, resp.Status)
+		}
+	}
+	if games := s.ListGames(); len(games) != 0 {
+		t.Errorf("Expected no games, got %v", games)
+	}
+}
+
+func TestTooManyGames(t *testing.T) {
+	s := NewServer(testConfig)
+	s.maxGames = 1
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	createGame(t, s, GameOptions{Width: 8, Height: 8})
+	resp, err := http.Post(ts.URL+"/games", "application/json", nil)
+	if err != nil {
+		t.Fatalf("Can't create game: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Expected the server to be full, got %s", resp.Status)
+	}
+}
+
+func TestIdleGamesAreReaped(t *testing.T) {
+	s := NewServer(testConfig)
+	s.idleTimeout = 10 * time.Millisecond
+
+	s.UseCatalog(readDefaultCatalog(t))
+
+	id := createGame(t, s, GameOptions{Width: 16, Height: 16})
+	if _, err := s.AddBot(id, bot.DefaultConfig); err != nil {
+		t.Fatalf("Can't add bot to game %s: %v", id, err)
+	}
+	loop := s.games[id].loop
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(s.ListGames()) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Game with only a bot wasn't torn down")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if !loop.IsStopped() {
+		t.Errorf("Expected idle game to be stopped")
+	}
+}
+
+func TestAddBotToStoppedGame(t *testing.T) {
+	s := NewServer(testConfig)
+	id := createGame(t, s, GameOptions{Width: 8, Height: 8})
+	s.games[id].loop.Stop()
+
+	if _, err := s.AddBot(id, bot.DefaultConfig); err == nil {
+		t.Errorf("Expected a stopped game not to take bots")
+	}
+	if games := s.ListGames(); len(games) != 1 || games[0].Bots != 0 {
+		t.Errorf("Expected no bots to be counted, got %v", games)
+	}
+}
+
+func TestCreateGameWithBots(t *testing.T) {
+	s := NewServer(testConfig)
+	s.UseCatalog(readDefaultCatalog(t))
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	info, code := postGame(t, ts, `{"width": 32, "height": 32, "bots": 2}`)
+	if code != http.StatusOK {
+		t.Fatalf("Can't create game with bots, got %d", code)
+	}
+
+	if games := s.ListGames(); len(games) != 1 || games[0].Bots != 2 || games[0].Players != 0 {
+		t.Errorf("Expected a game with two bots, got %v", games)
+	}
+	status := s.games[info.ID].loop.ReadLatestStatus()
+	if len(status.Cultures) != 2 {
+		t.Fatalf("Expected a culture for each bot, got %d", len(status.Cultures))
+	}
+	for _, culture := range status.Cultures {
+		if len(culture.Characters) != 3 {
+			t.Errorf("Expected %s to take a start, got %v", culture.Name, culture.Characters)
+		}
+	}
+
+	if _, code := postGame(t, ts, `{"bots": -1}`); code != http.StatusBadRequest {
+		t.Errorf("Expected bad request for negative bots, got %d", code)
+	}
+}
+
+func TestBotsNeedTheirTypes(t *testing.T) {
+	s := NewServer(testConfig)
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	if _, code := postGame(t, ts, `{"bots": 1}`); code != http.StatusBadRequest {
+		t.Errorf("Expected bad request for bots without a catalog, got %d", code)
+	}
+	if games := s.ListGames(); len(games) != 0 {
+		t.Errorf("Expected the game to be dropped, got %v", games)
+	}
+}
+
+func TestBotPlaysOverWebsocket(t *testing.T) {
+	s, id := newMapServer(t)
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	ws := dial(t, ts, "/game/"+id)
+	played := make(chan error, 1)
+	go func() {
+		played <- bot.Play(ws, bot.DefaultConfig)
+	}()
+
+	loop := s.games[id].loop
+	deadline := time.Now().Add(5 * time.Second)
+	for len(loop.ReadLatestStatus().Cultures) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Bot never joined")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// The bot's start has a worker, and a deposit to mine.
+	for loop.ReadLatestStatus().Cultures[0].Characters[0].Target == "" {
+		if time.Now().After(deadline) {
+			t.Fatalf("Bot never put its worker to work")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	ws.Close()
+	select {
+	case <-played:
+	case <-time.After(5 * time.Second):
+		t.Errorf("Expected Play to return once the connection closed")
+	}
+}
+
+func TestLobby(t *testing.T) {
+	s := NewServer(testConfig)
+	s.UseCatalog(readDefaultCatalog(t))
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	info, code := postGame(t, ts, `{"width": 32, "height": 32, "bots": 1, "players": 2}`)
+	if code != http.StatusOK {
+		t.Fatalf("Can't create game, got %d", code)
+	}
+
+	ws := dial(t, ts, "/game/"+info.ID)
+	defer ws.Close()
+	joinAs(t, ws, protocol.RolePlayer)
+	time.Sleep(20 * time.Millisecond)
+	if games := s.ListGames(); games[0].Phase != game.PhaseLobby || games[0].Tick != 0 {
+		t.Fatalf("Expected the game to wait for another player, got %v", games)
+	}
+
+	start := func() int {
+		resp, err := http.Post(ts.URL+"/games/"+info.ID+"/start", "application/json", nil)
+		if err != nil {
+			t.Fatalf("Can't start game: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	if code := start(); code != http.StatusNoContent {
+		t.Fatalf("Expected the game to start, got %d", code)
+	}
+	if games := s.ListGames(); games[0].Phase != game.PhaseRunning {
+		t.Errorf("Expected the started game to be running, got %v", games)
+	}
+	if code := start(); code != http.StatusConflict {
+		t.Errorf("Expected a conflict starting a running game, got %d", code)
+	}
+}
+
+func TestCreateGameOnMap(t *testing.T) {
+	s := NewServer(testConfig)
+	s.UseCatalog(readDefaultCatalog(t))
+	s.UseMaps("../maps")
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	info, code := postGame(t, ts, `{"map": "crossroads", "bots": 1}`)
+	if code != http.StatusOK {
+		t.Fatalf("Can't create game on a map, got %d", code)
+	}
+	status := s.games[info.ID].loop.ReadLatestStatus()
+	if status.Width != 16 || len(status.Cultures) != 1 ||
+		len(status.Cultures[0].Characters) != 3 {
+		t.Errorf("Expected the bot to take the first start, got %v", status.Cultures)
+	}
+
+	ws := dial(t, ts, "/game/"+info.ID)
+	defer ws.Close()
+	culture := joinAs(t, ws, protocol.RolePlayer)
+	status, _ = s.games[info.ID].loop.ReadLatestStatusFor(culture)
+	mine := status.Cultures[len(status.Cultures)-1]
+	if mine.Name != culture || len(mine.Characters) != 3 || len(mine.BuiltHouses) != 1 ||
+		mine.Characters[0].Location != (game.Location{X: 14, Y: 10}) {
+		t.Errorf("Expected the player to take the second start, got %v", mine)
+	}
+
+	late := dial(t, ts, "/game/"+info.ID)
+	defer late.Close()
+	websocket.Message.Send(late, `{"version":1,"type":"join","role":"player"}`)
+	if msg := receiveUntil(t, late, protocol.TypeError); msg.Error.Reason != protocol.ReasonGameFull {
+		t.Errorf("Expected a full game, got %v", msg.Error)
+	}
+
+	for _, body := range []string{
+		`{"map": "nowhere"}`,
+		`{"map": "../maps/crossroads"}`,
+		`{"map": "crossroads", "players": 3}`,
+	} {
+		if _, code := postGame(t, ts, body); code != http.StatusBadRequest {
+			t.Errorf("Expected bad request for %s, got %d", body, code)
+		}
+	}
+}
+
+func TestCreateGameOnGeneratedMap(t *testing.T) {
+	s := NewServer(testConfig)
+	s.UseCatalog(readDefaultCatalog(t))
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	info, code := postGame(t, ts,
+		`{"width": 32, "height": 32, "generate": {"seed": 7, "workers": 2}}`)
+	if code != http.StatusOK {
+		t.Fatalf("Can't create game on a generated map, got %d", code)
+	}
+	ws := dial(t, ts, "/game/"+info.ID)
+	defer ws.Close()
+	culture := joinAs(t, ws, protocol.RolePlayer)
+	status, _ := s.games[info.ID].loop.ReadLatestStatusFor(culture)
+	if len(status.Cultures) != 1 || len(status.Cultures[0].Characters) != 2 ||
+		len(status.Cultures[0].BuiltHouses) != 1 || len(status.Deposits) == 0 {
+		t.Errorf("Expected the player to take a generated start, got %v", status.Cultures)
+	}
+
+	for _, body := range []string{
+		`{"width": 32, "height": 32, "generate": {"workerType": "ghost"}}`,
+		`{"width": 8, "height": 8}`,
+		`{"width": 32, "height": 32, "players": 5}`,
+	} {
+		if _, code := postGame(t, ts, body); code != http.StatusBadRequest {
+			t.Errorf("Expected bad request for %s, got %d", body, code)
+		}
+	}
+}
+
+func TestJoinUnknownGame(t *testing.T) {
+	s := NewServer(testConfig)
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/game/12")
+	if err != nil {
+		t.Fatalf("Can't request game: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected not found, got %s", resp.Status)
+	}
+}
+
+func TestPlayGame(t *testing.T) {
+	s := NewServer(testConfig)
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	id := createGame(t, s, GameOptions{Width: 8, Height: 8})
+	ws := dial(t, ts, "/game/"+id)
+	joinAs(t, ws, protocol.RolePlayer)
+	receiveUntil(t, ws, protocol.TypeStatus)
+
+	websocket.Message.Send(ws, `not json`)
+	errMsg := receiveUntil(t, ws, protocol.TypeError)
+	if errMsg.Error == nil || errMsg.Error.Reason != protocol.ReasonMalformed {
+		t.Errorf("Unexpected error for malformed message %v", errMsg.Error)
+	}
+
+	websocket.Message.Send(ws,
+		`{"version":1,"type":"march","character":"nobody","x":1,"y":1}`)
+	result := receiveUntil(t, ws, protocol.TypeResult)
+	if result.Accepted || result.Error.Reason != game.RejectUnknownCharacter {
+		t.Errorf("Unexpected result for bad march %v", result)
+	}
+
+	if games := s.ListGames(); len(games) != 1 || games[0].Players != 1 {
+		t.Errorf("Expected one player in one game, got %v", games)
+	}
+	ws.Close()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(s.ListGames()) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Game wasn't torn down after the last player left")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestGameOver(t *testing.T) {
+	s := NewServer(testConfig)
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	resp, err := http.Post(ts.URL+"/games", "application/json",
+		strings.NewReader(`{"width": 8, "height": 8, "timeLimit": 5}`))
+	if err != nil {
+		t.Fatalf("Can't create game: %v", err)
+	}
+	var info GameInfo
+	json.NewDecoder(resp.Body).Decode(&info)
+	resp.Body.Close()
+
+	ws := dial(t, ts, "/game/"+info.ID)
+	defer ws.Close()
+	culture := joinAs(t, ws, protocol.RolePlayer)
+
+	over := receiveUntil(t, ws, protocol.TypeGameOver)
+	if over.Result == nil || over.Result.Reason != game.EndTimeLimit ||
+		len(over.Result.Winners) != 1 || over.Result.Winners[0] != culture {
+		t.Errorf("Expected %s to win when time ran out, got %v", culture, over.Result)
+	}
+	if games := s.ListGames(); len(games) != 1 || games[0].Phase != game.PhaseFinished {
+		t.Errorf("Expected the game to be listed as finished, got %v", games)
+	}
+
+	// Latecomers can only watch
+	late := dial(t, ts, "/game/"+info.ID)
+	defer late.Close()
+	websocket.Message.Send(late, `{"version":1,"type":"join","role":"player"}`)
+	errMsg := receiveUntil(t, late, protocol.TypeError)
+	if errMsg.Error == nil || errMsg.Error.Reason != game.RejectGameOver {
+		t.Errorf("Expected joining a finished game to fail, got %v", errMsg.Error)
+	}
+	joinAs(t, late, protocol.RoleSpectator)
+	receiveUntil(t, late, protocol.TypeGameOver)
+}
+
+func TestPlayersHearAboutTheirOwnEvents(t *testing.T) {
+	s, id := newMapServer(t)
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	// Both players build a tower with their worker and their depot.
+	ws := dial(t, ts, "/game/"+id)
+	defer ws.Close()
+	culture := joinAs(t, ws, protocol.RolePlayer)
+	other := dial(t, ts, "/game/"+id)
+	defer other.Close()
+	otherCulture := joinAs(t, other, protocol.RolePlayer)
+	players := []struct {
+		conn    *websocket.Conn
+		culture string
+	}{{ws, culture}, {other, otherCulture}}
+	for i, p := range players {
+		websocket.Message.Send(p.conn, fmt.Sprintf(
+			`{"version":1,"type":"plan","culture":%q,"houseType":"tower","x":%d,"y":6}`,
+			p.culture, 4+6*i))
+		websocket.Message.Send(p.conn, fmt.Sprintf(
+			`{"version":1,"type":"jobs","culture":%q,"enabled":true}`, p.culture))
+		for j := 0; j < 2; j++ {
+			if result := receiveUntil(t, p.conn, protocol.TypeResult); !result.Accepted {
+				t.Fatalf("Expected %s's orders to be accepted, got %v", p.culture, result.Error)
+			}
+		}
+	}
+
+	for completed := false; !completed; {
+		for _, event := range receiveUntil(t, ws, protocol.TypeEvents).Events {
+			if event.Culture != culture {
+				t.Fatalf("Expected to only hear about %s, got %v", culture, event)
+			}
+			completed = completed || event.Type == game.EventHouseCompleted
+		}
+	}
+}
+
+func TestStatusDeltas(t *testing.T) {
+	s, id := newMapServer(t)
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	ws := dial(t, ts, "/game/"+id)
+	defer ws.Close()
+	websocket.Message.Send(ws, `{"version":1,"type":"join","role":"player","deltas":true}`)
+	receiveUntil(t, ws, protocol.TypeJoined)
+
+	var tracker protocol.StatusTracker
+	keyframe := receiveUntil(t, ws, protocol.TypeDelta).Delta
+	if !keyframe.Keyframe || len(keyframe.Tiles) != 8 {
+		t.Fatalf("Expected to start with a keyframe, got %v", keyframe)
+	}
+	status, err := tracker.Apply(*keyframe)
+	if err != nil {
+		t.Fatalf("Can't apply keyframe: %v", err)
+	}
+	worker := ownCulture(status).Characters[0]
+
+	// Deltas are based on acknowledged statuses, and leave out the tiles.
+	acked := receiveUntil(t, ws, protocol.TypeDelta).Delta
+	if _, err := tracker.Apply(*acked); err != nil {
+		t.Fatalf("Can't apply delta: %v", err)
+	}
+	websocket.Message.Send(ws, fmt.Sprintf(`{"version":1,"type":"ack","seq":%d}`, acked.Seq))
+	websocket.Message.Send(ws, fmt.Sprintf(
+		`{"version":1,"type":"march","character":%q,"x":5,"y":1}`, worker.Name))
+	for {
+		delta := receiveUntil(t, ws, protocol.TypeDelta).Delta
+		if delta.Keyframe || delta.Tiles != nil {
+			t.Fatalf("Expected a delta without tiles, got %v", delta)
+		}
+		status, err := tracker.Apply(*delta)
+		if err != nil {
+			t.Fatalf("Can't apply delta: %v", err)
+		}
+		moved := ownCulture(status).Characters[0].Location != worker.Location
+		if delta.Base == acked.Seq && moved {
+			break
+		}
+	}
+
+	websocket.Message.Send(ws, `{"version":1,"type":"resync"}`)
+	for {
+		delta := receiveUntil(t, ws, protocol.TypeDelta).Delta
+		if delta.Keyframe {
+			break
+		}
+	}
+}
+
+func TestStatusSyncKeyframes(t *testing.T) {
+	var sync statusSync
+	status := game.GameStatus{Tick: 1, Tiles: []string{"."}}
+	if delta := sync.next(status); !delta.Keyframe || delta.Seq != 1 {
+		t.Fatalf("Expected the first status to be a keyframe, got %v", delta)
+	}
+
+	sync.ack(1) // already the base
+	sync.ack(7) // never sent
+	for i := 0; i < keyframeInterval; i++ {
+		delta := sync.next(status)
+		if delta.Keyframe || delta.Base != 1 {
+			t.Fatalf("Expected a delta based on the keyframe, got %v", delta)
+		}
+	}
+	sync.ack(5)
+	if delta := sync.next(status); !delta.Keyframe {
+		t.Errorf("Expected a keyframe every %d statuses, got %v", keyframeInterval, delta)
+	}
+	sync.ack(5) // from before the keyframe
+	if delta := sync.next(status); delta.Base != keyframeInterval+2 {
+		t.Errorf("Expected a delta based on the keyframe, got %v", delta)
+	}
+}
+
+func TestCreateGameBadConditions(t *testing.T) {
+	s := NewServer(testConfig)
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	resp, err := http.Post(ts.URL+"/games", "application/json",
+		strings.NewReader(`{"timeLimit": -1}`))
+	if err != nil {
+		t.Fatalf("Can't create game: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected bad request for a negative time limit, got %s", resp.Status)
+	}
+}
+
+func TestOnePlayerLeaving(t *testing.T) {
+	s := NewServer(testConfig)
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	id := createGame(t, s, GameOptions{Width: 8, Height: 8})
+	staying := dial(t, ts, "/game/"+id)
+	defer staying.Close()
+	leaving := dial(t, ts, "/game/"+id)
+	joinAs(t, staying, protocol.RolePlayer)
+	joinAs(t, leaving, protocol.RoleSpectator)
+	receiveUntil(t, staying, protocol.TypeStatus)
+	receiveUntil(t, leaving, protocol.TypeStatus)
+	leaving.Close()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		games := s.ListGames()
+		if len(games) != 1 {
+			t.Fatalf("Game ended when one of two players left")
+		}
+		if games[0].Players == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Player never left game")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	receiveUntil(t, staying, protocol.TypeStatus)
+}
+
+func TestRecordReplays(t *testing.T) {
+	dir, err := ioutil.TempDir("", "replays")
+	if err != nil {
+		t.Fatalf("Can't make replay directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	s := NewServer(testConfig)
+	s.RecordReplays(dir)
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	id := createGame(t, s, GameOptions{Width: 8, Height: 8})
+	ws := dial(t, ts, "/game/"+id)
+	culture := joinAs(t, ws, protocol.RolePlayer)
+	receiveUntil(t, ws, protocol.TypeStatus)
+	ws.Close()
+
+	path := filepath.Join(dir, "game-"+id+".json")
+	deadline := time.Now().Add(5 * time.Second)
+	for len(s.ListGames()) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Game wasn't torn down after the last player left")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Can't open replay: %v", err)
+	}
+	defer f.Close()
+	recorded, err := replay.Read(f)
+	if err != nil {
+		t.Fatalf("Can't read replay: %v", err)
+	}
+
+	player, err := replay.NewPlayer(recorded, replay.DefaultCheckpointInterval)
+	if err != nil {
+		t.Fatalf("Can't play replay: %v", err)
+	}
+	if err := player.Seek(recorded.End); err != nil {
+		t.Fatalf("Can't play replay to the end: %v", err)
+	}
+	status := game.ReadStatus(player.Game())
+	if len(status.Cultures) != 1 || status.Cultures[0].Name != culture {
+		t.Errorf("Expected replay to include player culture %s, got %v",
+			culture, status.Cultures)
+	}
+}
+
+func TestOrdersBeforeJoining(t *testing.T) {
+	s := NewServer(testConfig)
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	ws := dial(t, ts, "/game/"+createGame(t, s, GameOptions{Width: 8, Height: 8}))
+	defer ws.Close()
+
+	websocket.Message.Send(ws,
+		`{"version":1,"type":"march","character":"nobody","x":1,"y":1}`)
+	errMsg := receiveUntil(t, ws, protocol.TypeError)
+	if errMsg.Error.Reason != protocol.ReasonNotJoined {
+		t.Errorf("Unexpected error for order before join %v", errMsg.Error)
+	}
+
+	websocket.Message.Send(ws, `{"version":1,"type":"join","role":"king"}`)
+	errMsg = receiveUntil(t, ws, protocol.TypeError)
+	if errMsg.Error.Reason != protocol.ReasonUnknownRole {
+		t.Errorf("Unexpected error for unknown role %v", errMsg.Error)
+	}
+}
+
+func TestSpectatorsCantGiveOrders(t *testing.T) {
+	s := NewServer(testConfig)
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	ws := dial(t, ts, "/game/"+createGame(t, s, GameOptions{Width: 8, Height: 8}))
+	defer ws.Close()
+	if culture := joinAs(t, ws, protocol.RoleSpectator); culture != "" {
+		t.Errorf("Spectator was given culture %q", culture)
+	}
+	receiveUntil(t, ws, protocol.TypeStatus)
+
+	websocket.Message.Send(ws,
+		`{"version":1,"type":"march","character":"nobody","x":1,"y":1}`)
+	errMsg := receiveUntil(t, ws, protocol.TypeError)
+	if errMsg.Error.Reason != protocol.ReasonSpectator {
+		t.Errorf("Unexpected error for spectator order %v", errMsg.Error)
+	}
+}
+
+func TestPlayersOnlyOrderTheirOwnCulture(t *testing.T) {
+	s := NewServer(testConfig)
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	id := createGame(t, s, GameOptions{Width: 8, Height: 8})
+	red := dial(t, ts, "/game/"+id)
+	defer red.Close()
+	green := dial(t, ts, "/game/"+id)
+	defer green.Close()
+	redCulture := joinAs(t, red, protocol.RolePlayer)
+	greenCulture := joinAs(t, green, protocol.RolePlayer)
+
+	if redCulture == greenCulture {
+		t.Fatalf("Two players share culture %q", redCulture)
+	}
+
+	websocket.Message.Send(red, fmt.Sprintf(
+		`{"version":1,"type":"plan","culture":%q,"houseType":"hut","x":1,"y":1}`,
+		greenCulture))
+	result := receiveUntil(t, red, protocol.TypeResult)
+	if result.Accepted || result.Error.Reason != game.RejectWrongCulture {
+		t.Errorf("Unexpected result planning for another culture %v", result.Error)
+	}
+
+	websocket.Message.Send(red, fmt.Sprintf(
+		`{"version":1,"type":"plan","culture":%q,"houseType":"hut","x":1,"y":1}`,
+		redCulture))
+	result = receiveUntil(t, red, protocol.TypeResult)
+	if result.Accepted || result.Error.Reason != game.RejectUnknownHouseType {
+		t.Errorf("Unexpected result planning for own culture %v", result.Error)
+	}
+}
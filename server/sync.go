@@ -0,0 +1,78 @@
+package server
+
+import (
+	"sync"
+
+	"github.com/joeatwork/world-of-strategery/game"
+	"github.com/joeatwork/world-of-strategery/protocol"
+)
+
+// keyframeInterval is how many statuses a client that asked for deltas is
+// sent between keyframes.
+const keyframeInterval = 100
+
+// sentStatus is a status sent to a client, along with its sequence number.
+type sentStatus struct {
+	seq    int
+	status game.GameStatus
+}
+
+// statusSync keeps track of the statuses a client has, so that it can be sent
+// only what has changed. Deltas are based on whichever is newer, the last
+// keyframe or the last status the client acknowledged, so that a client that
+// never acknowledges anything still only needs its last keyframe.
+type statusSync struct {
+	lock          sync.Mutex
+	seq           int          // of the last status sent
+	base          sentStatus   // what the next delta is based on
+	unacked       []sentStatus // sent since base, oldest first
+	sinceKeyframe int
+	resync        bool
+}
+
+// next describes status for the client, as a keyframe if one is due and
+// otherwise as a delta.
+func (s *statusSync) next(status game.GameStatus) protocol.StatusDelta {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.seq++
+	var delta protocol.StatusDelta
+	if s.seq == 1 || s.resync || s.sinceKeyframe >= keyframeInterval {
+		delta = protocol.Diff(game.GameStatus{}, status)
+		delta.Keyframe = true
+		s.base = sentStatus{s.seq, status}
+		s.unacked = nil
+		s.sinceKeyframe = 0
+		s.resync = false
+	} else {
+		delta = protocol.Diff(s.base.status, status)
+		delta.Base = s.base.seq
+		s.unacked = append(s.unacked, sentStatus{s.seq, status})
+		s.sinceKeyframe++
+	}
+	delta.Seq = s.seq
+	return delta
+}
+
+// ack bases future deltas on the status with sequence number seq. Statuses
+// from before the current base are already forgotten, and are ignored.
+func (s *statusSync) ack(seq int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for i, sent := range s.unacked {
+		if sent.seq == seq {
+			s.base = sent
+			s.unacked = append([]sentStatus(nil), s.unacked[i+1:]...)
+			return
+		}
+	}
+}
+
+// requestResync makes the next status a keyframe.
+func (s *statusSync) requestResync() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.resync = true
+}